@@ -1,45 +1,458 @@
 package config
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
 
+// DefaultJWTSecret 默认的JWT密钥，仅作占位，禁止在实际部署中使用
+const DefaultJWTSecret = "your-secret-key"
+
+// EnvPrefix 环境变量前缀，例如 USERADMIN_MYSQL_DSN
+const EnvPrefix = "USERADMIN_"
+
 type Config struct {
-	MySQL MySQLConfig
-	JWT   JWTConfig
-	Server ServerConfig
+	MySQL    MySQLConfig    `yaml:"mysql" toml:"mysql"`
+	JWT      JWTConfig      `yaml:"jwt" toml:"jwt"`
+	Server   ServerConfig   `yaml:"server" toml:"server"`
+	Security SecurityConfig `yaml:"security" toml:"security"`
+	Password PasswordConfig `yaml:"password" toml:"password"`
+	Log      LogConfig      `yaml:"log" toml:"log"`
 }
 
+// MySQLConfig 数据库连接，连接串只能通过配置文件、环境变量或命令行参数提供，没有默认值
 type MySQLConfig struct {
-	DSN string
+	DSN string `yaml:"dsn" toml:"dsn"`
 }
 
 type JWTConfig struct {
-	Secret string
-	Expire int // token过期时间(小时)
+	Secret        string `yaml:"secret" toml:"secret"`
+	AccessExpire  int    `yaml:"access_expire" toml:"access_expire"`   // 访问token过期时间(分钟)
+	RefreshExpire int    `yaml:"refresh_expire" toml:"refresh_expire"` // 刷新token过期时间(小时)
 }
 
 type ServerConfig struct {
-	Port int
-	Mode string // gin mode: debug, release, test
+	Port int    `yaml:"port" toml:"port"`
+	Mode string `yaml:"mode" toml:"mode"` // gin mode: debug, release, test
+	// 收到退出信号后等待进行中的请求和日志写入完成的最长时间(秒)
+	ShutdownTimeout int `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
 }
 
-func GetConfig() *Config {
+// SecurityConfig 登录安全相关配置
+type SecurityConfig struct {
+	LoginMaxFailures   int `yaml:"login_max_failures" toml:"login_max_failures"`       // 同一用户名连续失败多少次后锁定
+	LoginIPMaxFailures int `yaml:"login_ip_max_failures" toml:"login_ip_max_failures"` // 同一IP连续失败多少次后锁定
+	LoginLockout       int `yaml:"login_lockout" toml:"login_lockout"`                 // 锁定时长(分钟)
+	LoginFailureWindow int `yaml:"login_failure_window" toml:"login_failure_window"`   // 失败计数在无失败多久后清零(分钟)
+	LoginBackoffBase   int `yaml:"login_backoff_base" toml:"login_backoff_base"`       // 失败后等待的初始时长(秒)，每次失败翻倍
+	LoginBackoffMax    int `yaml:"login_backoff_max" toml:"login_backoff_max"`         // 失败后等待的最长时长(秒)
+
+	MFAIssuer       string `yaml:"mfa_issuer" toml:"mfa_issuer"`               // 身份验证器中显示的发行方名称
+	AdminRequireMFA bool   `yaml:"admin_require_mfa" toml:"admin_require_mfa"` // 超级管理员角色是否强制两步验证
+
+	// 权限缓存有效期(秒)，数据变更时会主动失效；多节点部署时其它节点最多延迟该时长生效
+	PermissionCacheTTL int `yaml:"permission_cache_ttl" toml:"permission_cache_ttl"`
+	// 清理到期临时授权的间隔(秒)，0表示不清理；未清理的到期授权也不会生效
+	GrantExpiryInterval int `yaml:"grant_expiry_interval" toml:"grant_expiry_interval"`
+}
+
+// PasswordConfig 密码策略
+type PasswordConfig struct {
+	MinLength     int      `yaml:"min_length" toml:"min_length"`
+	RequireUpper  bool     `yaml:"require_upper" toml:"require_upper"`   // 必须包含大写字母
+	RequireLower  bool     `yaml:"require_lower" toml:"require_lower"`   // 必须包含小写字母
+	RequireDigit  bool     `yaml:"require_digit" toml:"require_digit"`   // 必须包含数字
+	RequireSymbol bool     `yaml:"require_symbol" toml:"require_symbol"` // 必须包含特殊字符
+	Banned        []string `yaml:"banned" toml:"banned"`                 // 禁止使用的常见密码，不区分大小写
+	HistorySize   int      `yaml:"history_size" toml:"history_size"`     // 不能与最近N次使用过的密码相同
+	MaxAge        int      `yaml:"max_age" toml:"max_age"`               // 密码有效期(天)，0表示不过期
+}
+
+// LogConfig 请求日志记录内容的配置
+type LogConfig struct {
+	// 需要脱敏的 JSON 字段路径，以 . 分隔，* 匹配一层任意字段，** 匹配任意多层（包括零层），
+	// 数组元素沿用数组所在的路径，字段名不区分大小写。例如 token、*.password、**.secret
+	RedactFields []string `yaml:"redact_fields" toml:"redact_fields"`
+	// 请求体、响应体最多记录的字节数，超出部分截断并标注原始大小；0 表示不记录
+	MaxBodySize int `yaml:"max_body_size" toml:"max_body_size"`
+	// 不记录内容的 Content-Type 前缀，例如文件上传和图片等二进制内容
+	SkipContentTypes []string `yaml:"skip_content_types" toml:"skip_content_types"`
+
+	// 日志由后台按批写入，请求只放入队列
+	QueueSize     int    `yaml:"queue_size" toml:"queue_size"`         // 队列容量(条)
+	BatchSize     int    `yaml:"batch_size" toml:"batch_size"`         // 每批最多写入的条数
+	FlushInterval int    `yaml:"flush_interval" toml:"flush_interval"` // 不足一批时的写入间隔(毫秒)
+	Overflow      string `yaml:"overflow" toml:"overflow"`             // 队列已满时: drop 丢弃新日志, drop_oldest 丢弃最早的日志, block 等待 block_timeout
+	BlockTimeout  int    `yaml:"block_timeout" toml:"block_timeout"`   // overflow 为 block 时最多等待的时长(毫秒)
+
+	// 日志同时写入的目标: database, file, syslog
+	Sinks  []string        `yaml:"sinks" toml:"sinks"`
+	File   LogFileConfig   `yaml:"file" toml:"file"`
+	Syslog LogSyslogConfig `yaml:"syslog" toml:"syslog"`
+
+	Retention LogRetentionConfig `yaml:"retention" toml:"retention"`
+}
+
+// LogRetentionConfig logs 表的保留策略，过期的日志先归档为 gzip 压缩的 NDJSON 文件再删除
+type LogRetentionConfig struct {
+	Days       int    `yaml:"days" toml:"days"`             // 日志保留天数，0表示不清理
+	ErrorDays  int    `yaml:"error_days" toml:"error_days"` // 状态码 >= 400 的日志保留天数，不能少于 days
+	Archive    bool   `yaml:"archive" toml:"archive"`       // 删除前是否归档
+	ArchiveDir string `yaml:"archive_dir" toml:"archive_dir"`
+	Interval   int    `yaml:"interval" toml:"interval"`     // 定时清理的间隔(秒)，0表示只能手动执行
+	BatchSize  int    `yaml:"batch_size" toml:"batch_size"` // 每次查询、删除的条数
+}
+
+// LogFileConfig JSON Lines 日志文件，超过大小后轮转
+type LogFileConfig struct {
+	Path       string `yaml:"path" toml:"path"`
+	MaxSize    int    `yaml:"max_size" toml:"max_size"`       // 单个文件的最大大小(MB)
+	MaxBackups int    `yaml:"max_backups" toml:"max_backups"` // 保留的轮转文件个数，0表示全部保留
+}
+
+// LogSyslogConfig RFC 5424 syslog 输出
+type LogSyslogConfig struct {
+	Network  string `yaml:"network" toml:"network"`   // udp 或 tcp
+	Address  string `yaml:"address" toml:"address"`   // 例如 127.0.0.1:514
+	Facility int    `yaml:"facility" toml:"facility"` // 0-23，默认 16 (local0)
+	AppName  string `yaml:"app_name" toml:"app_name"`
+}
+
+var (
+	current  *Config
+	loadOnce sync.Once
+)
+
+// Default 返回默认配置
+func Default() *Config {
 	return &Config{
-		MySQL: MySQLConfig{
-			DSN: "rayuser:123456@tcp(192.168.0.107:3306)/jstoremini?charset=utf8&parseTime=true",
-		},
 		JWT: JWTConfig{
-			Secret: "your-secret-key",
-			Expire: 24, // 24小时
+			Secret:        DefaultJWTSecret,
+			AccessExpire:  15,     // 15分钟
+			RefreshExpire: 24 * 7, // 7天
 		},
 		Server: ServerConfig{
-			Port: 8080,
-			Mode: "debug",
+			Port:            8080,
+			Mode:            "debug",
+			ShutdownTimeout: 15,
+		},
+		Security: SecurityConfig{
+			LoginMaxFailures:    5,
+			LoginIPMaxFailures:  20,
+			LoginLockout:        15,
+			LoginFailureWindow:  15,
+			LoginBackoffBase:    1,
+			LoginBackoffMax:     30,
+			MFAIssuer:           "useradmin",
+			PermissionCacheTTL:  60,
+			GrantExpiryInterval: 60,
 		},
+		Password: PasswordConfig{
+			MinLength:    8,
+			RequireLower: true,
+			RequireDigit: true,
+			Banned: []string{
+				"password", "password1", "12345678", "123456789", "1234567890",
+				"qwerty123", "11111111", "88888888", "abc12345", "admin123",
+			},
+			HistorySize: 5,
+			MaxAge:      90,
+		},
+		Log: LogConfig{
+			RedactFields: []string{
+				"**.password", "**.old_password", "**.new_password", "**.admin_password",
+				"**.token", "**.refresh_token", "**.mfa_token",
+				"**.recovery_code", "**.recovery_codes",
+				"**.secret", "**.otpauth_uri",
+				// 两步验证的验证码在请求体顶层；权限代码等嵌套的 code 字段不脱敏
+				"code",
+			},
+			MaxBodySize: 4096,
+			SkipContentTypes: []string{
+				"multipart/", "image/", "audio/", "video/",
+				"application/octet-stream", "application/zip", "application/pdf",
+			},
+			QueueSize:     10000,
+			BatchSize:     100,
+			FlushInterval: 500,
+			Overflow:      "drop",
+			BlockTimeout:  50,
+			Sinks:         []string{"database"},
+			File: LogFileConfig{
+				Path:       "logs/access.log",
+				MaxSize:    100,
+				MaxBackups: 7,
+			},
+			Syslog: LogSyslogConfig{
+				Network:  "udp",
+				Address:  "127.0.0.1:514",
+				Facility: 16,
+				AppName:  "useradmin",
+			},
+			Retention: LogRetentionConfig{
+				Days:       30,
+				ErrorDays:  180,
+				Archive:    true,
+				ArchiveDir: "logs/archive",
+				Interval:   3600,
+				BatchSize:  1000,
+			},
+		},
+	}
+}
+
+// Load 按 默认值 -> 配置文件 -> 环境变量 -> 命令行参数 的顺序加载配置，并进行校验
+// 配置文件路径可通过 -config 参数或 USERADMIN_CONFIG 环境变量指定，支持 yaml 和 toml
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("useradmin", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv(EnvPrefix+"CONFIG"), "配置文件路径(yaml/toml)")
+	dsn := fs.String("mysql-dsn", "", "MySQL 连接串")
+	secret := fs.String("jwt-secret", "", "JWT 签名密钥")
+	accessExpire := fs.Int("jwt-access-expire", 0, "访问token过期时间(分钟)")
+	refreshExpire := fs.Int("jwt-refresh-expire", 0, "刷新token过期时间(小时)")
+	port := fs.Int("port", 0, "服务监听端口")
+	mode := fs.String("mode", "", "gin 模式: debug, release, test")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// 配置文件
+	if *configFile != "" {
+		if err := loadFile(cfg, *configFile); err != nil {
+			return nil, err
+		}
+	}
+
+	// 环境变量
+	if err := loadEnv(cfg, EnvPrefix, reflect.ValueOf(cfg).Elem()); err != nil {
+		return nil, err
+	}
+
+	// 命令行参数，只覆盖显式传入的参数
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "mysql-dsn":
+			cfg.MySQL.DSN = *dsn
+		case "jwt-secret":
+			cfg.JWT.Secret = *secret
+		case "jwt-access-expire":
+			cfg.JWT.AccessExpire = *accessExpire
+		case "jwt-refresh-expire":
+			cfg.JWT.RefreshExpire = *refreshExpire
+		case "port":
+			cfg.Server.Port = *port
+		case "mode":
+			cfg.Server.Mode = *mode
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate 校验必填配置项
+func (c *Config) Validate() error {
+	var errs []string
+	if c.MySQL.DSN == "" {
+		errs = append(errs, "mysql.dsn 不能为空")
+	}
+	if c.JWT.Secret == "" || c.JWT.Secret == DefaultJWTSecret {
+		errs = append(errs, "jwt.secret 未配置或仍为默认值")
+	}
+	if c.JWT.AccessExpire <= 0 {
+		errs = append(errs, "jwt.access_expire 必须大于0")
+	}
+	if c.JWT.RefreshExpire <= 0 {
+		errs = append(errs, "jwt.refresh_expire 必须大于0")
+	}
+	if c.Server.Port <= 0 || c.Server.Port > 65535 {
+		errs = append(errs, "server.port 无效")
+	}
+	if c.Security.LoginMaxFailures <= 0 || c.Security.LoginIPMaxFailures <= 0 {
+		errs = append(errs, "security.login_max_failures 和 security.login_ip_max_failures 必须大于0")
+	}
+	if c.Security.LoginLockout <= 0 || c.Security.LoginFailureWindow <= 0 {
+		errs = append(errs, "security.login_lockout 和 security.login_failure_window 必须大于0")
+	}
+	if c.Password.MinLength <= 0 {
+		errs = append(errs, "password.min_length 必须大于0")
+	}
+	if c.Password.HistorySize < 0 || c.Password.MaxAge < 0 {
+		errs = append(errs, "password.history_size 和 password.max_age 不能为负数")
+	}
+	if c.Log.MaxBodySize < 0 {
+		errs = append(errs, "log.max_body_size 不能为负数")
+	}
+	if c.Log.QueueSize <= 0 || c.Log.BatchSize <= 0 || c.Log.FlushInterval <= 0 {
+		errs = append(errs, "log.queue_size、log.batch_size 和 log.flush_interval 必须大于0")
+	}
+	switch c.Log.Overflow {
+	case "drop", "drop_oldest":
+	case "block":
+		if c.Log.BlockTimeout <= 0 {
+			errs = append(errs, "log.overflow 为 block 时 log.block_timeout 必须大于0")
+		}
+	default:
+		errs = append(errs, "log.overflow 只能是 drop, drop_oldest 或 block")
+	}
+	for _, sink := range c.Log.Sinks {
+		switch sink {
+		case "database":
+		case "file":
+			if c.Log.File.Path == "" || c.Log.File.MaxSize <= 0 || c.Log.File.MaxBackups < 0 {
+				errs = append(errs, "log.file.path 不能为空，log.file.max_size 必须大于0，log.file.max_backups 不能为负数")
+			}
+		case "syslog":
+			if c.Log.Syslog.Network != "udp" && c.Log.Syslog.Network != "tcp" {
+				errs = append(errs, "log.syslog.network 只能是 udp 或 tcp")
+			}
+			if c.Log.Syslog.Address == "" || c.Log.Syslog.Facility < 0 || c.Log.Syslog.Facility > 23 {
+				errs = append(errs, "log.syslog.address 不能为空，log.syslog.facility 必须在 0-23 之间")
+			}
+		default:
+			errs = append(errs, "log.sinks 只能包含 database, file 或 syslog")
+		}
+	}
+	if r := c.Log.Retention; r.Days < 0 || r.Interval < 0 || r.BatchSize <= 0 || (r.Days > 0 && r.ErrorDays < r.Days) {
+		errs = append(errs, "log.retention.days、log.retention.interval 不能为负数，log.retention.error_days 不能少于 days，log.retention.batch_size 必须大于0")
+	}
+	if c.Log.Retention.Archive && c.Log.Retention.ArchiveDir == "" {
+		errs = append(errs, "log.retention.archive_dir 不能为空")
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, "server.shutdown_timeout 必须大于0")
+	}
+	switch c.Server.Mode {
+	case "debug", "release", "test":
+	default:
+		errs = append(errs, "server.mode 只能是 debug, release 或 test")
+	}
+	if len(errs) > 0 {
+		return errors.New("配置校验失败: " + strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Addr 返回服务监听地址
+func (s ServerConfig) Addr() string {
+	return fmt.Sprintf(":%d", s.Port)
+}
+
+// loadFile 从 yaml 或 toml 文件加载配置
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("不支持的配置文件格式: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("解析配置文件失败: %w", err)
+	}
+	return nil
+}
+
+// loadEnv 按字段的 yaml 标签从环境变量覆盖配置，嵌套字段用下划线连接，例如 USERADMIN_SERVER_PORT
+func loadEnv(cfg *Config, prefix string, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := prefix + strings.ToUpper(strings.Split(field.Tag.Get("yaml"), ",")[0])
+		fv := v.Field(i)
+
+		if fv.Kind() == reflect.Struct {
+			if err := loadEnv(cfg, name+"_", fv); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setValue(fv, value); err != nil {
+			return fmt.Errorf("环境变量 %s 无效: %w", name, err)
+		}
 	}
+	return nil
+}
+
+// setValue 将字符串转换为字段对应的类型
+func setValue(fv reflect.Value, value string) error {
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(value)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("不支持的类型 %s", fv.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		fv.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("不支持的类型 %s", fv.Type())
+	}
+	return nil
+}
+
+// SetConfig 设置当前生效的配置
+func SetConfig(cfg *Config) {
+	loadOnce.Do(func() {})
+	current = cfg
+}
+
+// GetConfig 获取当前生效的配置，首次调用时从命令行参数、环境变量和配置文件加载
+func GetConfig() *Config {
+	loadOnce.Do(func() {
+		cfg, err := Load(os.Args[1:])
+		if err != nil {
+			log.Fatal("加载配置失败:", err)
+		}
+		current = cfg
+	})
+	return current
 }
 
 // InitDB 初始化数据库连接
@@ -50,4 +463,4 @@ func InitDB(db *gorm.DB) {
 // GetDB 获取数据库连接
 func GetDB() *gorm.DB {
 	return DB
-} 
\ No newline at end of file
+}
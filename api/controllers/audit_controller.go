@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"useradmin/api/dto"
+	"useradmin/api/models"
+	"useradmin/api/services"
+)
+
+// GetAuditEvents 查询审计事件。type 以 .* 结尾时按前缀匹配（例如 user.*），
+// 可按操作人、目标、请求ID和时间范围过滤
+func GetAuditEvents(c *gin.Context) {
+	pageNum, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if pageNum < 1 {
+		pageNum = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	query := tenantDB(c).Model(&models.AuditEvent{})
+	if eventType := c.Query("type"); eventType != "" {
+		if prefix, ok := strings.CutSuffix(eventType, "*"); ok {
+			query = query.Where("type LIKE ?", prefix+"%")
+		} else {
+			query = query.Where("type = ?", eventType)
+		}
+	}
+	if actor := c.Query("actor"); actor != "" {
+		query = query.Where("actor_name = ?", actor)
+	}
+	if actorID := c.Query("actor_id"); actorID != "" {
+		query = query.Where("actor_id = ?", actorID)
+	}
+	if targetType := c.Query("target_type"); targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+	if targetID := c.Query("target_id"); targetID != "" {
+		query = query.Where("target_id = ?", targetID)
+	}
+	if requestID := c.Query("request_id"); requestID != "" {
+		query = query.Where("request_id = ?", requestID)
+	}
+	if startTime := c.Query("start_time"); startTime != "" {
+		query = query.Where("created_at >= ?", startTime)
+	}
+	if endTime := c.Query("end_time"); endTime != "" {
+		query = query.Where("created_at <= ?", endTime)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(500, gin.H{"error": "获取审计记录失败"})
+		return
+	}
+
+	var events []models.AuditEvent
+	if err := query.Order("id DESC").Limit(limit).Offset((pageNum - 1) * limit).Find(&events).Error; err != nil {
+		c.JSON(500, gin.H{"error": "获取审计记录失败"})
+		return
+	}
+
+	c.JSON(200, dto.Page{
+		Total:    total,
+		Page:     pageNum,
+		PageSize: limit,
+		Data:     dto.NewAuditEvents(events),
+	})
+}
+
+// lockForAudit 在事务 tx 中锁定要修改的记录（SELECT ... FOR UPDATE），之后再加载变更前的状态，
+// 并发修改同一记录时，审计事件的变更前状态就是本次修改所覆盖的状态
+func lockForAudit(tx *gorm.DB, dest any, id uint) error {
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(dest, id).Error
+}
+
+// recordAudit 在业务事务 tx 中记录当前请求产生的审计事件，变更与审计记录一起提交或回滚，
+// 审计写入失败时返回错误使事务回滚。before、after 为变更前后的响应结构（dto），
+// 创建时 before 为 nil，删除时 after 为 nil；修改前后没有差异时不记录
+func recordAudit(c *gin.Context, tx *gorm.DB, eventType, targetType string, targetID uint, before, after any) error {
+	changes := services.DiffFields(before, after)
+	if before != nil && after != nil && len(changes) == 0 {
+		return nil
+	}
+
+	event := services.AuditEvent{
+		RequestID:  c.GetString("request_id"),
+		Type:       eventType,
+		ActorName:  c.GetString("username"),
+		TargetType: targetType,
+		TargetID:   targetID,
+		IP:         c.ClientIP(),
+		Changes:    changes,
+	}
+	if subject := currentSubject(c); subject != nil {
+		event.ActorID = subject.UserID
+	}
+	if err := services.RecordAudit(tx, event); err != nil {
+		log.Printf("记录审计事件 %s 失败: %v", eventType, err)
+		return err
+	}
+	return nil
+}
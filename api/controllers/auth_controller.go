@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"useradmin/api/middleware"
+)
+
+// RefreshTokenRequest 刷新token请求结构
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RefreshToken 使用刷新token换取新的访问token
+func RefreshToken(c *gin.Context) {
+	var req RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	tokens, err := middleware.RefreshTokens(req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, middleware.ErrRefreshTokenInvalid),
+			errors.Is(err, middleware.ErrRefreshTokenExpired),
+			errors.Is(err, middleware.ErrRefreshTokenReused),
+			errors.Is(err, middleware.ErrUserDisabled):
+			c.JSON(401, gin.H{"error": err.Error()})
+		default:
+			log.Printf("刷新token失败: %v", err)
+			c.JSON(500, gin.H{"error": "刷新token失败"})
+		}
+		return
+	}
+
+	c.JSON(200, tokens)
+}
+
+// Logout 登出，吊销当前访问token及其刷新token
+func Logout(c *gin.Context) {
+	claims, ok := c.MustGet("claims").(*middleware.Claims)
+	if !ok {
+		c.JSON(401, gin.H{"error": "未授权"})
+		return
+	}
+
+	if err := middleware.RevokeSession(claims); err != nil {
+		log.Printf("登出失败: %v", err)
+		c.JSON(500, gin.H{"error": "登出失败"})
+		return
+	}
+
+	c.JSON(200, gin.H{"message": "已退出登录"})
+}
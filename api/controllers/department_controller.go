@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"useradmin/api/dto"
+	"useradmin/api/models"
+	"useradmin/api/services"
+)
+
+// DepartmentRequest 创建、更新部门请求结构
+type DepartmentRequest struct {
+	Name     string `json:"name" binding:"required"`
+	ParentID uint   `json:"parent_id"`
+	Sort     int    `json:"sort"`
+	Status   *int   `json:"status"`
+}
+
+// GetDepartments 获取部门树，flat=1 时返回平铺列表
+func GetDepartments(c *gin.Context) {
+	var departments []models.Department
+	if err := tenantDB(c).Order("sort, id").Find(&departments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取部门列表失败"})
+		return
+	}
+
+	if c.Query("flat") == "1" {
+		c.JSON(http.StatusOK, gin.H{"data": dto.NewDepartments(departments)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": dto.NewDepartmentTree(departments)})
+}
+
+// CreateDepartment 创建部门
+func CreateDepartment(c *gin.Context) {
+	var req DepartmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	dept := models.Department{Name: req.Name, ParentID: req.ParentID, Sort: req.Sort, Status: 1}
+	if req.Status != nil {
+		dept.Status = *req.Status
+	}
+	// 只能在数据范围内的部门下创建，顶级部门只有全部数据范围可以创建
+	if err := services.GuardDepartmentAccess(currentSubject(c), req.ParentID); err != nil {
+		respondGuardError(c, err)
+		return
+	}
+
+	err := tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := services.CreateDepartment(tx, &dept); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, services.AuditDepartmentCreated, services.AuditTargetDepartment, dept.ID, nil, dto.NewDepartment(dept))
+	})
+	if respondDepartmentError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建部门失败"})
+		return
+	}
+	services.InvalidateAllPermissions()
+
+	c.JSON(http.StatusOK, gin.H{"data": dto.NewDepartment(dept)})
+}
+
+// UpdateDepartment 更新部门，修改上级部门时同时移动其下级部门
+func UpdateDepartment(c *gin.Context) {
+	var dept models.Department
+	if err := tenantDB(c).First(&dept, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "部门不存在"})
+		return
+	}
+
+	var req DepartmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+	// 部门本身和移动后的上级部门都必须在数据范围内，否则可以把其它部门连同用户移入自己的范围
+	subject := currentSubject(c)
+	if err := services.GuardDepartmentAccess(subject, dept.ID); err != nil {
+		respondGuardError(c, err)
+		return
+	}
+	if req.ParentID != dept.ParentID {
+		if err := services.GuardDepartmentAccess(subject, req.ParentID); err != nil {
+			respondGuardError(c, err)
+			return
+		}
+	}
+	before := dto.NewDepartment(dept)
+
+	err := tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := services.MoveDepartment(tx, &dept, req.ParentID); err != nil {
+			return err
+		}
+		dept.Name = req.Name
+		dept.Sort = req.Sort
+		if req.Status != nil {
+			dept.Status = *req.Status
+		}
+		if err := tx.Save(&dept).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, services.AuditDepartmentUpdated, services.AuditTargetDepartment, dept.ID, before, dto.NewDepartment(dept))
+	})
+	if respondDepartmentError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新部门失败"})
+		return
+	}
+	services.InvalidateAllPermissions()
+
+	c.JSON(http.StatusOK, gin.H{"data": dto.NewDepartment(dept)})
+}
+
+// DeleteDepartment 删除部门
+func DeleteDepartment(c *gin.Context) {
+	var dept models.Department
+	if err := tenantDB(c).First(&dept, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "部门不存在"})
+		return
+	}
+	if err := services.GuardDepartmentAccess(currentSubject(c), dept.ID); err != nil {
+		respondGuardError(c, err)
+		return
+	}
+
+	err := tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := services.DeleteDepartment(tx, &dept); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, services.AuditDepartmentDeleted, services.AuditTargetDepartment, dept.ID, dto.NewDepartment(dept), nil)
+	})
+	if respondDepartmentError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除部门失败"})
+		return
+	}
+	services.InvalidateAllPermissions()
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// departmentFilter 解析 department_id 查询参数，include_children=0 时只匹配该部门，否则包含下级部门
+func departmentFilter(c *gin.Context) ([]uint, bool, error) {
+	raw := c.Query("department_id")
+	if raw == "" {
+		return nil, false, nil
+	}
+
+	var dept models.Department
+	if err := tenantDB(c).First(&dept, raw).Error; err != nil {
+		return []uint{}, true, nil
+	}
+	if c.DefaultQuery("include_children", "1") == "0" {
+		return []uint{dept.ID}, true, nil
+	}
+	ids, err := services.DepartmentSubtreeIDs(tenantDB(c), []uint{dept.ID})
+	return ids, true, err
+}
+
+// respondDepartmentError 部门相关的业务错误返回 400，返回 true 表示已处理
+func respondDepartmentError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, services.ErrDepartmentNotFound),
+		errors.Is(err, services.ErrDepartmentCycle),
+		errors.Is(err, services.ErrDepartmentInUse):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return true
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"useradmin/api/config"
+)
+
+func TestMain(m *testing.M) {
+	// config.GetConfig 会解析命令行参数并校验必填配置，去掉 go test 的参数
+	os.Args = os.Args[:1]
+	os.Setenv(config.EnvPrefix+"MYSQL_DSN", "test")
+	os.Setenv(config.EnvPrefix+"JWT_SECRET", "test-secret")
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// fakeDB 测试用的数据库，查询按 FROM 后的表名返回预置的全部行，不解析 WHERE 条件；
+// 写入语句都视为成功。err 不为空时所有语句都返回该错误
+type fakeDB struct {
+	mu     sync.Mutex
+	tables map[string][]map[string]driver.Value
+	err    error
+}
+
+// useFakeDB 将 config.DB 替换为 fakeDB，测试结束后恢复
+func useFakeDB(t *testing.T, db *fakeDB) {
+	t.Helper()
+	sqlDB := sql.OpenDB(db)
+	gdb, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := config.DB
+	config.DB = gdb
+	t.Cleanup(func() {
+		config.DB = old
+		sqlDB.Close()
+	})
+}
+
+func (db *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: db}, nil }
+func (db *fakeDB) Driver() driver.Driver                        { return nil }
+
+var fakeTablePattern = regexp.MustCompile("(?i)FROM `?(\\w+)`?")
+
+func (db *fakeDB) query(query string) (driver.Rows, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.err != nil {
+		return nil, db.err
+	}
+	rows := &fakeRows{}
+	if m := fakeTablePattern.FindStringSubmatch(query); m != nil {
+		rows.rows = db.tables[m[1]]
+	}
+	seen := make(map[string]bool)
+	for _, row := range rows.rows {
+		for column := range row {
+			if !seen[column] {
+				seen[column] = true
+				rows.columns = append(rows.columns, column)
+			}
+		}
+	}
+	sort.Strings(rows.columns)
+	return rows, nil
+}
+
+func (db *fakeDB) exec() (driver.Result, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.err != nil {
+		return nil, db.err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (c *fakeConn) Close() error                        { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)           { return fakeTx{}, nil }
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	return c.db.query(query)
+}
+
+func (c *fakeConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return c.db.exec()
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	columns []string
+	rows    []map[string]driver.Value
+	next    int
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+	for i, column := range r.columns {
+		dest[i] = r.rows[r.next][column]
+	}
+	r.next++
+	return nil
+}
@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"useradmin/api/dto"
+	"useradmin/api/services"
+)
+
+// GetExpiringGrants 列出即将到期的临时角色和临时权限，hours 为查询范围(小时)，默认 7 天
+func GetExpiringGrants(c *gin.Context) {
+	hours, err := strconv.Atoi(c.DefaultQuery("hours", "168"))
+	if err != nil || hours <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的查询范围"})
+		return
+	}
+
+	now := time.Now()
+	grants, err := services.ListExpiringGrants(tenantDB(c), now, now.Add(time.Duration(hours)*time.Hour))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取即将到期的授权失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": dto.NewTimedGrants(grants)})
+}
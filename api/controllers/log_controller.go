@@ -1,29 +1,188 @@
 package controllers
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
 	"github.com/gin-gonic/gin"
-	"useradmin/api/config"
+	"gorm.io/gorm"
+	"useradmin/api/dto"
 	"useradmin/api/models"
-	"strconv"
+	"useradmin/api/services"
 )
 
-// GetLogs 获取日志列表
+// GetLogs 获取日志列表。默认按 page、page_size 分页并返回总数；
+// 传入 cursor 参数（第一页传空值）时按游标翻页，不统计总数，适合翻阅大量日志
 func GetLogs(c *gin.Context) {
+	// 构建查询
+	query, err := filterLogs(c)
+	if err != nil {
+		respondLogFilterError(c, err, "获取日志列表失败")
+		return
+	}
+	sort, err := parseLogSort(c.DefaultQuery("sort", "-created_at"))
+	if err != nil {
+		respondLogFilterError(c, err, "获取日志列表失败")
+		return
+	}
+
+	if cursor, ok := c.GetQuery("cursor"); ok {
+		getLogsByCursor(c, query, sort, cursor)
+		return
+	}
+
 	// 获取查询参数
 	page := c.DefaultQuery("page", "1")
 	pageSize := c.DefaultQuery("page_size", "10")
-	username := c.Query("username")
-	action := c.Query("action")
-	startTime := c.Query("start_time")
-	endTime := c.Query("end_time")
 
 	// 转换分页参数
 	pageNum, _ := strconv.Atoi(page)
 	limit, _ := strconv.Atoi(pageSize)
 	offset := (pageNum - 1) * limit
 
-	// 构建查询
-	query := config.DB.Model(&models.Log{})
+	// 获取总数
+	var total int64
+	query.Count(&total)
+
+	// 获取分页数据
+	var logs []models.Log
+	if err := sort.order(query).Limit(limit).Offset(offset).Find(&logs).Error; err != nil {
+		c.JSON(500, gin.H{"error": "获取日志列表失败"})
+		return
+	}
+
+	// 返回结果
+	c.JSON(200, dto.Page{
+		Total:    total,
+		Page:     pageNum,
+		PageSize: limit,
+		Data:     dto.NewLogs(logs),
+	})
+}
+
+// getLogsByCursor 按 (排序字段, id) 翻页，查询走以 tenant_id 开头的索引（见 models.MigrateLogs），翻到后面也不会变慢
+func getLogsByCursor(c *gin.Context, query *gorm.DB, sort logSort, cursor string) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if limit <= 0 {
+		limit = 10
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	if cursor != "" {
+		after, err := decodeLogCursor(cursor, sort)
+		if err != nil {
+			respondLogFilterError(c, err, "获取日志列表失败")
+			return
+		}
+		op := "<"
+		if !sort.desc {
+			op = ">"
+		}
+		// 行构造器比较可以直接在 (tenant_id, 排序字段, id) 索引上定位
+		query = query.Where(fmt.Sprintf("(%s, id) %s (?, ?)", sort.column, op), after.value, after.ID)
+	}
+
+	// 多查一条判断是否还有下一页
+	var logs []models.Log
+	if err := sort.order(query).Limit(limit + 1).Find(&logs).Error; err != nil {
+		c.JSON(500, gin.H{"error": "获取日志列表失败"})
+		return
+	}
+	next := ""
+	if len(logs) > limit {
+		logs = logs[:limit]
+		next = encodeLogCursor(logs[limit-1], sort)
+	}
+
+	c.JSON(200, dto.CursorPage{
+		PageSize:   limit,
+		NextCursor: next,
+		Data:       dto.NewLogs(logs),
+	})
+}
+
+// ExportLogs 按 GetLogs 的筛选条件导出日志，format 为 csv（默认）、xlsx 或 ndjson。
+// 按ID分批查询并边查边写，不会把全部日志读入内存
+func ExportLogs(c *gin.Context) {
+	query, err := filterLogs(c)
+	if err != nil {
+		respondLogFilterError(c, err, "导出日志失败")
+		return
+	}
+	// 创建导出不会写入响应，响应头在第一次写入时才发出
+	exporter, err := services.NewLogExporter(c.DefaultQuery("format", "csv"), c.Writer)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Type", exporter.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, services.LogExportFilename(exporter, time.Now())))
+	c.Header("Cache-Control", "no-store")
+	c.Status(200)
+
+	// 响应头在写入第一批日志时才发出，之后出错只能中断下载，客户端会收到不完整的文件
+	var logs []models.Log
+	result := query.FindInBatches(&logs, logExportBatchSize, func(tx *gorm.DB, batch int) error {
+		if err := exporter.WriteLogs(logs); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return c.Request.Context().Err()
+	})
+	if result.Error != nil {
+		if !c.Writer.Written() {
+			// 还没有写入任何内容，撤销下载的响应头，返回错误
+			c.Writer.Header().Del("Content-Type")
+			c.Writer.Header().Del("Content-Disposition")
+			c.Writer.Header().Del("Cache-Control")
+			c.JSON(500, gin.H{"error": "导出日志失败"})
+			return
+		}
+		c.Error(result.Error)
+		c.Abort()
+		return
+	}
+	if err := exporter.Close(); err != nil {
+		c.Error(err)
+	}
+}
+
+// logExportBatchSize 导出时每次查询的条数
+const logExportBatchSize = 1000
+
+// logFilterError 日志筛选参数无效
+type logFilterError string
+
+func (e logFilterError) Error() string { return string(e) }
+
+// filterLogs 按查询参数筛选日志，GetLogs 和 ExportLogs 共用：
+//   - username、user_agent：模糊匹配；action：请求方法
+//   - status：逗号分隔的状态码 404、状态类 4xx 或范围 400-499
+//   - path：请求路径前缀；ip：IP 地址或 CIDR，如 10.0.0.0/8
+//   - q：在响应内容中搜索，至少 2 个字符，最好同时限定时间范围
+//   - start_time、end_time、department_id
+func filterLogs(c *gin.Context) (*gorm.DB, error) {
+	username := c.Query("username")
+	action := c.Query("action")
+	status := c.Query("status")
+	path := c.Query("path")
+	ip := c.Query("ip")
+	userAgent := c.Query("user_agent")
+	text := strings.TrimSpace(c.Query("q"))
+	startTime := c.Query("start_time")
+	endTime := c.Query("end_time")
+
+	query := tenantDB(c).Model(&models.Log{})
 
 	// 添加搜索条件
 	if username != "" {
@@ -32,37 +191,213 @@ func GetLogs(c *gin.Context) {
 	if action != "" {
 		query = query.Where("action = ?", action)
 	}
+	if status != "" {
+		ranges, err := parseStatusRanges(status)
+		if err != nil {
+			return nil, err
+		}
+		conds := make([]string, len(ranges))
+		args := make([]interface{}, 0, len(ranges)*2)
+		for i, r := range ranges {
+			conds[i] = "status BETWEEN ? AND ?"
+			args = append(args, r[0], r[1])
+		}
+		query = query.Where("("+strings.Join(conds, " OR ")+")", args...)
+	}
+	if path != "" {
+		query = query.Where("resource LIKE ?", escapeLike(path)+"%")
+	}
+	if ip != "" {
+		var err error
+		if query, err = filterLogIP(query, ip); err != nil {
+			return nil, err
+		}
+	}
+	if userAgent != "" {
+		query = query.Where("user_agent LIKE ?", "%"+escapeLike(userAgent)+"%")
+	}
+	if text != "" {
+		// 响应内容没有索引，在租户、时间等条件筛选后的结果中逐条匹配
+		if utf8.RuneCountInString(text) < 2 {
+			return nil, logFilterError("q 至少需要 2 个字符")
+		}
+		query = query.Where("response LIKE ?", "%"+escapeLike(text)+"%")
+	}
 	if startTime != "" {
 		query = query.Where("created_at >= ?", startTime)
 	}
 	if endTime != "" {
 		query = query.Where("created_at <= ?", endTime)
 	}
+	// 按操作人所属部门筛选，默认包含下级部门
+	departmentIDs, ok, err := departmentFilter(c)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		query = query.Where("username IN (SELECT username FROM users WHERE department_id IN ? AND deleted_at IS NULL)", departmentIDs)
+	}
+	return query, nil
+}
 
-	// 获取总数
-	var total int64
-	query.Count(&total)
+// parseStatusRanges 解析 status 参数，返回闭区间列表
+func parseStatusRanges(raw string) ([][2]int, error) {
+	invalid := logFilterError("status 格式错误，应为逗号分隔的状态码、状态类或范围，如 200,4xx,500-599")
+	var ranges [][2]int
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		var from, to int
+		var err error
+		switch {
+		case len(item) == 3 && strings.HasSuffix(item, "xx"):
+			from, err = strconv.Atoi(item[:1])
+			from *= 100
+			to = from + 99
+		case strings.Contains(item, "-"):
+			lo, hi, _ := strings.Cut(item, "-")
+			if from, err = strconv.Atoi(strings.TrimSpace(lo)); err == nil {
+				to, err = strconv.Atoi(strings.TrimSpace(hi))
+			}
+		default:
+			from, err = strconv.Atoi(item)
+			to = from
+		}
+		if err != nil || from < 100 || to > 599 || from > to {
+			return nil, invalid
+		}
+		ranges = append(ranges, [2]int{from, to})
+	}
+	return ranges, nil
+}
 
-	// 获取分页数据
-	var logs []models.Log
-	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&logs).Error; err != nil {
-		c.JSON(500, gin.H{"error": "获取日志列表失败"})
-		return
+// filterLogIP 按 IP 精确匹配，或按 CIDR 匹配网段。网段用 INET6_ATON 转成二进制后比较，
+// IPv4、IPv6 地址转换后长度不同，需要同时比较长度
+func filterLogIP(query *gorm.DB, raw string) (*gorm.DB, error) {
+	if !strings.Contains(raw, "/") {
+		addr := net.ParseIP(raw)
+		if addr == nil {
+			return nil, logFilterError("ip 必须是 IP 地址或 CIDR")
+		}
+		return query.Where("ip = ?", addr.String()), nil
+	}
+	_, network, err := net.ParseCIDR(raw)
+	if err != nil {
+		return nil, logFilterError("ip 必须是 IP 地址或 CIDR")
 	}
+	first := []byte(network.IP)
+	last := make([]byte, len(first))
+	for i := range first {
+		last[i] = first[i] | ^network.Mask[i]
+	}
+	return query.Where("LENGTH(INET6_ATON(ip)) = ? AND INET6_ATON(ip) BETWEEN ? AND ?", len(first), first, last), nil
+}
 
-	// 返回结果
-	c.JSON(200, gin.H{
-		"total": total,
-		"page": pageNum,
-		"page_size": limit,
-		"data": logs,
-	})
+// escapeLike 转义 LIKE 中的通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// logSort 日志列表的排序，同一排序值再按 id 排序，保证翻页稳定
+type logSort struct {
+	key    string // sort 参数中的字段名
+	column string
+	desc   bool
+}
+
+// logSortColumns 可排序的字段，均有以 (tenant_id, 字段) 开头、按 id 排序的索引
+var logSortColumns = map[string]string{
+	"created_at": "created_at",
+	"status":     "status",
+	"username":   "username",
+}
+
+// parseLogSort 解析 sort 参数，字段名前加 - 表示倒序，如 -created_at
+func parseLogSort(raw string) (logSort, error) {
+	key := strings.TrimPrefix(raw, "-")
+	column, ok := logSortColumns[key]
+	if !ok {
+		return logSort{}, logFilterError("sort 只能是 created_at、status、username，前面加 - 表示倒序")
+	}
+	return logSort{key: key, column: column, desc: strings.HasPrefix(raw, "-")}, nil
+}
+
+// String 返回 sort 参数的写法
+func (s logSort) String() string {
+	if s.desc {
+		return "-" + s.key
+	}
+	return s.key
+}
+
+func (s logSort) order(query *gorm.DB) *gorm.DB {
+	dir := " ASC"
+	if s.desc {
+		dir = " DESC"
+	}
+	return query.Order(s.column + dir).Order("id" + dir)
+}
+
+// logCursor 游标中保存上一页最后一条日志的排序值和ID，以及排序方式，换了排序的游标不能继续使用
+type logCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+
+	value interface{}
+}
+
+func encodeLogCursor(l models.Log, sort logSort) string {
+	cursor := logCursor{Sort: sort.String(), ID: l.ID}
+	switch sort.key {
+	case "created_at":
+		cursor.Value = l.CreatedAt.Format(time.RFC3339Nano)
+	case "status":
+		cursor.Value = strconv.Itoa(l.Status)
+	case "username":
+		cursor.Value = l.Username
+	}
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeLogCursor(raw string, sort logSort) (logCursor, error) {
+	invalid := logFilterError("cursor 无效或与当前排序不一致")
+	var cursor logCursor
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil || json.Unmarshal(data, &cursor) != nil {
+		return cursor, invalid
+	}
+	if cursor.Sort != sort.String() {
+		return cursor, invalid
+	}
+	switch sort.key {
+	case "created_at":
+		cursor.value, err = time.Parse(time.RFC3339Nano, cursor.Value)
+	case "status":
+		cursor.value, err = strconv.Atoi(cursor.Value)
+	default:
+		cursor.value = cursor.Value
+	}
+	if err != nil {
+		return cursor, invalid
+	}
+	return cursor, nil
+}
+
+// respondLogFilterError 筛选参数无效时返回 400，其它错误返回 500
+func respondLogFilterError(c *gin.Context, err error, message string) {
+	var filterErr logFilterError
+	if errors.As(err, &filterErr) {
+		c.JSON(400, gin.H{"error": filterErr.Error()})
+		return
+	}
+	c.JSON(500, gin.H{"error": message})
 }
 
 // GetLogTypes 获取日志类型列表
 func GetLogTypes(c *gin.Context) {
 	var types []string
-	if err := config.DB.Model(&models.Log{}).Distinct().Pluck("action", &types).Error; err != nil {
+	if err := tenantDB(c).Model(&models.Log{}).Distinct().Pluck("action", &types).Error; err != nil {
 		c.JSON(500, gin.H{"error": "获取日志类型失败"})
 		return
 	}
@@ -73,7 +408,7 @@ func GetLogTypes(c *gin.Context) {
 func GetLogStats(c *gin.Context) {
 	// 获取今日日志数
 	var todayCount int64
-	if err := config.DB.Model(&models.Log{}).Where("DATE(created_at) = CURDATE()").Count(&todayCount).Error; err != nil {
+	if err := tenantDB(c).Model(&models.Log{}).Where("DATE(created_at) = CURDATE()").Count(&todayCount).Error; err != nil {
 		c.JSON(500, gin.H{"error": "获取统计信息失败"})
 		return
 	}
@@ -84,7 +419,7 @@ func GetLogStats(c *gin.Context) {
 		Count  int64  `json:"count"`
 	}
 	var actionCounts []ActionCount
-	if err := config.DB.Model(&models.Log{}).
+	if err := tenantDB(c).Model(&models.Log{}).
 		Select("action, count(*) as count").
 		Group("action").
 		Find(&actionCounts).Error; err != nil {
@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestExportLogsQueryFailsBeforeFirstBatch(t *testing.T) {
+	useFakeDB(t, &fakeDB{err: errors.New("数据库不可用")})
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/logs/export?format=csv", nil)
+	ExportLogs(c)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("code = %d, want 500", w.Code)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != "" {
+		t.Errorf("Content-Disposition = %q, want no attachment", got)
+	}
+	if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, "application/json") {
+		t.Errorf("Content-Type = %q, want JSON", got)
+	}
+	if !strings.Contains(w.Body.String(), "导出日志失败") {
+		t.Errorf("body = %s", w.Body)
+	}
+}
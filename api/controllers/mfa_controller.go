@@ -0,0 +1,300 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"useradmin/api/middleware"
+	"useradmin/api/models"
+	"useradmin/api/services"
+)
+
+// MFALoginRequest 两步验证登录请求，code 和 recovery_code 二选一
+type MFALoginRequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// MFACodeRequest 需要提供验证码的请求
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// MFADisableRequest 关闭两步验证请求
+type MFADisableRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// LoginMFA 登录第二步：使用临时token和验证码换取正式token
+func LoginMFA(c *gin.Context) {
+	var req MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Code == "" && req.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	claims, user, ok := loadMFAUser(c, req.MFAToken, middleware.PurposeMFAVerify)
+	if !ok {
+		return
+	}
+
+	if err := services.VerifyMFA(&user, req.Code, req.RecoveryCode); err != nil {
+		respondMFAError(c, err, user.Username)
+		return
+	}
+
+	consumeMFAToken(claims)
+	respondLogin(c, user, nil)
+}
+
+// LoginMFAEnroll 角色强制两步验证但尚未设置时，在登录过程中生成密钥
+func LoginMFAEnroll(c *gin.Context) {
+	var req struct {
+		MFAToken string `json:"mfa_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	_, user, ok := loadMFAUser(c, req.MFAToken, middleware.PurposeMFAEnroll)
+	if !ok {
+		return
+	}
+
+	startMFAEnrollment(c, &user)
+}
+
+// LoginMFAActivate 登录过程中完成两步验证设置，返回恢复码和正式token
+func LoginMFAActivate(c *gin.Context) {
+	var req MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	claims, user, ok := loadMFAUser(c, req.MFAToken, middleware.PurposeMFAEnroll)
+	if !ok {
+		return
+	}
+
+	codes, err := services.ActivateMFA(&user, req.Code)
+	if err != nil {
+		respondMFAError(c, err, user.Username)
+		return
+	}
+
+	consumeMFAToken(claims)
+	user.TOTPEnabled = true
+	respondLogin(c, user, gin.H{"recovery_codes": codes})
+}
+
+// SetupMFA 当前用户开始设置两步验证，返回密钥和 otpauth 链接
+func SetupMFA(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	startMFAEnrollment(c, &user)
+}
+
+// EnableMFA 当前用户输入验证码后启用两步验证
+func EnableMFA(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	codes, err := services.ActivateMFA(&user, req.Code)
+	if err != nil {
+		respondMFAError(c, err, "")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "两步验证已启用",
+		"recovery_codes": codes,
+	})
+}
+
+// DisableMFA 当前用户关闭两步验证，需要密码和验证码
+func DisableMFA(c *gin.Context) {
+	var req MFADisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	mfaRequired, err := services.MFARequired(tenantDB(c), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "查询用户角色失败"})
+		return
+	}
+	if mfaRequired {
+		c.JSON(http.StatusForbidden, gin.H{"error": services.ErrMFARequiredByRole.Error()})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "密码错误"})
+		return
+	}
+
+	if err := services.VerifyMFA(&user, req.Code, ""); err != nil {
+		respondMFAError(c, err, "")
+		return
+	}
+
+	if err := services.DisableMFA(tenantDB(c), &user); err != nil {
+		log.Printf("关闭两步验证失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "关闭两步验证失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "两步验证已关闭"})
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if err := services.VerifyMFA(&user, req.Code, ""); err != nil {
+		respondMFAError(c, err, "")
+		return
+	}
+
+	codes, err := services.RegenerateRecoveryCodes(&user)
+	if err != nil {
+		respondMFAError(c, err, "")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// ResetUserMFA 管理员重置用户的两步验证（例如用户丢失设备）
+func ResetUserMFA(c *gin.Context) {
+	id := c.Param("id")
+
+	var user models.User
+	if err := scoped(c, services.DataResourceUser).First(&user, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+	if !authorizeUser(c, "user:update", user) {
+		return
+	}
+
+	err := tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := services.DisableMFA(tx, &user); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, services.AuditUserMFAReset, services.AuditTargetUser, user.ID, nil, nil)
+	})
+	if err != nil {
+		log.Printf("重置两步验证失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "重置两步验证失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "两步验证已重置"})
+}
+
+// loadMFAUser 解析两步验证临时token并加载用户
+func loadMFAUser(c *gin.Context, token string, purpose string) (*middleware.Claims, models.User, bool) {
+	var user models.User
+
+	claims, err := middleware.ParseMFAToken(token, purpose)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "验证已过期，请重新登录"})
+		return nil, user, false
+	}
+
+	// 第二步验证同样受登录失败次数限制
+	if wait := services.CheckLogin(claims.Username, c.ClientIP()); wait > 0 {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "登录失败次数过多，请稍后再试"})
+		return nil, user, false
+	}
+
+	if err := tenantDB(c).Preload("Role").Preload("Roles.Permissions").Where("username = ?", claims.Username).First(&user).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
+		return nil, user, false
+	}
+	if user.Status != 1 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "用户已被禁用"})
+		return nil, user, false
+	}
+	return claims, user, true
+}
+
+// consumeMFAToken 临时token只能使用一次
+func consumeMFAToken(claims *middleware.Claims) {
+	if err := middleware.RevokeJTI(claims.ID, claims.ExpiresAt.Time); err != nil {
+		log.Printf("吊销两步验证token失败: %v", err)
+	}
+}
+
+// currentUser 加载当前登录用户
+func currentUser(c *gin.Context) (models.User, bool) {
+	var user models.User
+	if err := tenantDB(c).Preload("Roles").Where("username = ?", c.GetString("username")).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return user, false
+	}
+	return user, true
+}
+
+func startMFAEnrollment(c *gin.Context, user *models.User) {
+	secret, uri, err := services.StartMFAEnrollment(user)
+	if err != nil {
+		respondMFAError(c, err, "")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": uri,
+	})
+}
+
+// respondMFAError 验证码错误时计入登录失败次数
+func respondMFAError(c *gin.Context, err error, username string) {
+	switch {
+	case errors.Is(err, services.ErrMFAInvalidCode):
+		if username != "" {
+			services.RecordLoginFailure(username, c.ClientIP())
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMFANotEnrolled), errors.Is(err, services.ErrMFAAlreadyEnabled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("两步验证失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "两步验证失败"})
+	}
+}
@@ -0,0 +1,240 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"useradmin/api/dto"
+	"useradmin/api/middleware"
+	"useradmin/api/models"
+	"useradmin/api/services"
+)
+
+// PolicyRequest 创建、更新策略请求结构
+type PolicyRequest struct {
+	Name        string                     `json:"name" binding:"required"`
+	Description string                     `json:"description"`
+	Effect      string                     `json:"effect" binding:"required"`
+	Permission  string                     `json:"permission" binding:"required"`
+	Conditions  []services.PolicyCondition `json:"conditions"`
+	Enabled     *bool                      `json:"enabled"`
+}
+
+// apply 将请求写入策略并校验，未指定 enabled 时新策略默认启用
+func (req PolicyRequest) apply(policy *models.Policy) (services.CompiledPolicy, error) {
+	conditions := req.Conditions
+	if conditions == nil {
+		conditions = []services.PolicyCondition{}
+	}
+	raw, err := json.Marshal(conditions)
+	if err != nil {
+		return services.CompiledPolicy{}, services.ErrInvalidPolicy
+	}
+
+	policy.Name = req.Name
+	policy.Description = req.Description
+	policy.Effect = req.Effect
+	policy.Permission = req.Permission
+	policy.Conditions = string(raw)
+	if req.Enabled != nil {
+		policy.Enabled = *req.Enabled
+	} else if policy.ID == 0 {
+		policy.Enabled = true
+	}
+	return services.CompilePolicy(*policy)
+}
+
+// ExplainRequest 策略试运行请求：判断用户对某个权限、资源在给定环境下能否操作
+type ExplainRequest struct {
+	UserID     uint           `json:"user_id"`
+	Username   string         `json:"username"`
+	Permission string         `json:"permission" binding:"required"`
+	Resource   map[string]any `json:"resource"`
+	// 覆盖当前请求的环境属性，例如 {"hour": 20}
+	Env map[string]any `json:"env"`
+	// 尚未保存的策略，与已启用的策略一起评估
+	Drafts []PolicyRequest `json:"drafts"`
+}
+
+// GetPolicies 获取策略列表，可按适用的权限过滤
+func GetPolicies(c *gin.Context) {
+	query := tenantDB(c).Order("id")
+	if permission := c.Query("permission"); permission != "" {
+		query = query.Where("permission = ?", permission)
+	}
+	var policies []models.Policy
+	if err := query.Find(&policies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取策略列表失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": dto.NewPolicies(policies)})
+}
+
+// CreatePolicy 创建策略
+func CreatePolicy(c *gin.Context) {
+	var req PolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	var policy models.Policy
+	compiled, err := req.apply(&policy)
+	if err != nil {
+		respondPolicyError(c, err)
+		return
+	}
+	if !guardPolicy(c, compiled) {
+		return
+	}
+	err = tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&policy).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, services.AuditPolicyCreated, services.AuditTargetPolicy, policy.ID, nil, dto.NewPolicy(policy))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建策略失败"})
+		return
+	}
+	services.InvalidatePolicies(policy.TenantID)
+	out := dto.NewPolicy(policy)
+
+	c.JSON(http.StatusOK, gin.H{"message": "创建成功", "data": out})
+}
+
+// UpdatePolicy 更新策略
+func UpdatePolicy(c *gin.Context) {
+	var policy models.Policy
+	if err := tenantDB(c).First(&policy, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "策略不存在"})
+		return
+	}
+
+	var req PolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+	before := dto.NewPolicy(policy)
+	compiled, err := req.apply(&policy)
+	if err != nil {
+		respondPolicyError(c, err)
+		return
+	}
+	if !guardPolicy(c, compiled) {
+		return
+	}
+	out := dto.NewPolicy(policy)
+	err = tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&policy).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, services.AuditPolicyUpdated, services.AuditTargetPolicy, policy.ID, before, out)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新策略失败"})
+		return
+	}
+	services.InvalidatePolicies(policy.TenantID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "更新成功", "data": out})
+}
+
+// DeletePolicy 删除策略
+func DeletePolicy(c *gin.Context) {
+	var policy models.Policy
+	if err := tenantDB(c).First(&policy, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "策略不存在"})
+		return
+	}
+	err := tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&policy).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, services.AuditPolicyDeleted, services.AuditTargetPolicy, policy.ID, dto.NewPolicy(policy), nil)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除策略失败"})
+		return
+	}
+	services.InvalidatePolicies(policy.TenantID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// ExplainPolicy 试运行授权判断，可附带未保存的策略，返回原因链、各策略及其条件的评估过程，不执行任何操作
+func ExplainPolicy(c *gin.Context) {
+	var req ExplainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	subject, ok := loadAuthzSubject(c, tenantDB(c), req.UserID, req.Username)
+	if !ok {
+		return
+	}
+
+	drafts := make([]services.CompiledPolicy, 0, len(req.Drafts))
+	for _, draft := range req.Drafts {
+		compiled, err := draft.apply(&models.Policy{})
+		if err != nil {
+			respondPolicyError(c, err)
+			return
+		}
+		drafts = append(drafts, compiled)
+	}
+
+	decision, err := services.ExplainAuthorization(tenantDB(c), subject, req.Permission, req.Resource, authzEnvironment(c, req.Env), drafts...)
+	if err != nil {
+		respondPolicyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": dto.NewAuthzDecision(decision)})
+}
+
+// guardPolicy 保存策略前检查资源属性的使用范围和授予权限的限制，返回 false 时已返回错误
+func guardPolicy(c *gin.Context, policy services.CompiledPolicy) bool {
+	if err := services.ValidatePolicyScope(policy); err != nil {
+		respondPolicyError(c, err)
+		return false
+	}
+	if err := services.GuardPolicyGrant(currentSubject(c), policy); err != nil {
+		respondGuardError(c, err)
+		return false
+	}
+	return true
+}
+
+// authorizeResource 加载资源后再次评估策略，使依赖资源属性的策略生效；返回 false 时已返回 403。
+// permission 需要登记在 services.IsResourcePermission 中
+func authorizeResource(c *gin.Context, permission string, resource services.Attributes) bool {
+	subject := currentSubject(c)
+	if subject == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
+		return false
+	}
+	decision, err := services.Authorize(subject, permission, resource, middleware.RequestEnvironment(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "权限检查失败"})
+		return false
+	}
+	if !decision.Allowed {
+		middleware.RespondForbidden(c, decision)
+		return false
+	}
+	return true
+}
+
+// respondPolicyError 策略格式错误返回 400，其他错误返回 500
+func respondPolicyError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrInvalidPolicy) || errors.Is(err, services.ErrInvalidPermissionCode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "策略评估失败"})
+}
@@ -2,9 +2,11 @@ package controllers
 
 import (
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 	"strconv"
-	"useradmin/api/config"
+	"useradmin/api/dto"
 	"useradmin/api/models"
+	"useradmin/api/services"
 )
 
 // CreateProduct 创建商品
@@ -18,18 +20,24 @@ func CreateProduct(c *gin.Context) {
 	// 设置创建人ID
 	if username, exists := c.Get("username"); exists {
 		var user models.User
-		if err := config.DB.Where("username = ?", username).First(&user).Error; err == nil {
+		if err := tenantDB(c).Where("username = ?", username).First(&user).Error; err == nil {
 			product.CreatedBy = user.ID
 			product.UpdatedBy = user.ID
 		}
 	}
 
-	if err := config.DB.Create(&product).Error; err != nil {
+	err := tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&product).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, services.AuditProductCreated, services.AuditTargetProduct, product.ID, nil, auditProduct(tx, product.ID))
+	})
+	if err != nil {
 		c.JSON(500, gin.H{"error": "创建商品失败"})
 		return
 	}
 
-	c.JSON(200, product)
+	c.JSON(200, dto.NewProduct(product))
 }
 
 // GetProducts 获取商品列表
@@ -48,7 +56,8 @@ func GetProducts(c *gin.Context) {
 	offset := (pageNum - 1) * limit
 
 	var products []models.Product
-	query := config.DB.Preload("Images").Preload("Specs")
+	// 只返回数据范围内的商品
+	query := scoped(c, services.DataResourceProduct).Preload("Images").Preload("Specs")
 
 	// 添加搜索条件
 	if title != "" {
@@ -68,11 +77,11 @@ func GetProducts(c *gin.Context) {
 		return
 	}
 
-	c.JSON(200, gin.H{
-		"total": total,
-		"page": pageNum,
-		"page_size": limit,
-		"data": products,
+	c.JSON(200, dto.Page{
+		Total:    total,
+		Page:     pageNum,
+		PageSize: limit,
+		Data:     dto.NewProducts(products),
 	})
 }
 
@@ -80,18 +89,19 @@ func GetProducts(c *gin.Context) {
 func GetProduct(c *gin.Context) {
 	id := c.Param("id")
 	var product models.Product
-	if err := config.DB.Preload("Images").Preload("Specs").First(&product, id).Error; err != nil {
+	if err := scoped(c, services.DataResourceProduct).Preload("Images").Preload("Specs").First(&product, id).Error; err != nil {
 		c.JSON(404, gin.H{"error": "商品不存在"})
 		return
 	}
-	c.JSON(200, product)
+	c.JSON(200, dto.NewProduct(product))
 }
 
 // UpdateProduct 更新商品
 func UpdateProduct(c *gin.Context) {
 	id := c.Param("id")
 	var product models.Product
-	if err := config.DB.First(&product, id).Error; err != nil {
+	// 数据范围外的商品视为不存在
+	if err := scoped(c, services.DataResourceProduct).First(&product, id).Error; err != nil {
 		c.JSON(404, gin.H{"error": "商品不存在"})
 		return
 	}
@@ -102,7 +112,7 @@ func UpdateProduct(c *gin.Context) {
 		Description string                `json:"description"`
 		Images      []models.ProductImage `json:"images"`
 		Specs       []models.ProductSpec  `json:"specs"`
-		Status      int                   `json:"status"`
+		Status      *int                  `json:"status"` // 不传时保持原状态
 	}
 
 	if err := c.ShouldBindJSON(&updateData); err != nil {
@@ -110,13 +120,34 @@ func UpdateProduct(c *gin.Context) {
 		return
 	}
 
+	// 上下架需要单独的 product:status 权限，与 UpdateProductStatus 评估相同的策略
+	if updateData.Status != nil && *updateData.Status != product.Status {
+		resource := productAttributes(product)
+		resource["new_status"] = *updateData.Status
+		if !authorizeResource(c, "product:status", resource) {
+			return
+		}
+	}
+	if !authorizeResource(c, "product:update", productAttributes(product)) {
+		return
+	}
+
 	// 开启事务
-	tx := config.DB.Begin()
+	tx := tenantDB(c).Begin()
+	// 先锁定商品再加载变更前的状态
+	if err := lockForAudit(tx, &models.Product{}, product.ID); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "更新商品失败"})
+		return
+	}
+	before := auditProduct(tx, product.ID)
 
 	// 更新基本信息
 	product.Title = updateData.Title
 	product.Description = updateData.Description
-	product.Status = updateData.Status
+	if updateData.Status != nil {
+		product.Status = *updateData.Status
+	}
 
 	// 保存基本信息
 	if err := tx.Save(&product).Error; err != nil {
@@ -168,6 +199,18 @@ func UpdateProduct(c *gin.Context) {
 		}
 	}
 
+	// 重新加载完整的商品信息，审计记录与商品在同一事务中提交
+	if err := tx.Preload("Images").Preload("Specs").First(&product, id).Error; err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "更新商品失败"})
+		return
+	}
+	if err := recordAudit(c, tx, services.AuditProductUpdated, services.AuditTargetProduct, product.ID, before, auditProduct(tx, product.ID)); err != nil {
+		tx.Rollback()
+		c.JSON(500, gin.H{"error": "记录审计事件失败"})
+		return
+	}
+
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
 		tx.Rollback()
@@ -175,16 +218,97 @@ func UpdateProduct(c *gin.Context) {
 		return
 	}
 
-	// 重新加载完整的商品信息
-	config.DB.Preload("Images").Preload("Specs").First(&product, id)
+	c.JSON(200, dto.NewProduct(product))
+}
+
+// UpdateProductStatus 商品上架、下架
+func UpdateProductStatus(c *gin.Context) {
+	var product models.Product
+	if err := scoped(c, services.DataResourceProduct).First(&product, c.Param("id")).Error; err != nil {
+		c.JSON(404, gin.H{"error": "商品不存在"})
+		return
+	}
+
+	var req struct {
+		Status *int `json:"status" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || (*req.Status != 0 && *req.Status != 1) {
+		c.JSON(400, gin.H{"error": "无效的请求参数"})
+		return
+	}
+	resource := productAttributes(product)
+	resource["new_status"] = *req.Status
+	if !authorizeResource(c, "product:status", resource) {
+		return
+	}
+
+	err := tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := lockForAudit(tx, &models.Product{}, product.ID); err != nil {
+			return err
+		}
+		before := auditProduct(tx, product.ID)
+		if err := tx.Model(&product).Update("status", *req.Status).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, services.AuditProductStatusChanged, services.AuditTargetProduct, product.ID, before, auditProduct(tx, product.ID))
+	})
+	if err != nil {
+		c.JSON(500, gin.H{"error": "更新商品状态失败"})
+		return
+	}
 
-	c.JSON(200, product)
+	c.JSON(200, dto.NewProduct(product))
+}
+
+// productAttributes 商品的策略属性
+func productAttributes(product models.Product) services.Attributes {
+	return services.Attributes{
+		"type":       "product",
+		"id":         product.ID,
+		"status":     product.Status,
+		"created_by": product.CreatedBy,
+	}
+}
+
+// auditProduct 加载商品的响应结构，用于记录审计事件的变更前后状态。
+// 更新商品时图片和规格会重新创建，忽略其ID，只比较内容
+func auditProduct(db *gorm.DB, id uint) any {
+	var product models.Product
+	if err := db.Preload("Images").Preload("Specs").First(&product, id).Error; err != nil {
+		return nil
+	}
+	out := dto.NewProduct(product)
+	for i := range out.Images {
+		out.Images[i].ID = 0
+	}
+	for i := range out.Specs {
+		out.Specs[i].ID = 0
+	}
+	return out
 }
 
 // DeleteProduct 删除商品
 func DeleteProduct(c *gin.Context) {
 	id := c.Param("id")
-	if err := config.DB.Delete(&models.Product{}, id).Error; err != nil {
+	var product models.Product
+	if err := scoped(c, services.DataResourceProduct).First(&product, id).Error; err != nil {
+		c.JSON(404, gin.H{"error": "商品不存在"})
+		return
+	}
+	if !authorizeResource(c, "product:delete", productAttributes(product)) {
+		return
+	}
+	err := tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := lockForAudit(tx, &models.Product{}, product.ID); err != nil {
+			return err
+		}
+		before := auditProduct(tx, product.ID)
+		if err := tx.Delete(&product).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, services.AuditProductDeleted, services.AuditTargetProduct, product.ID, before, nil)
+	})
+	if err != nil {
 		c.JSON(500, gin.H{"error": "删除商品失败"})
 		return
 	}
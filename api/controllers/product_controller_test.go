@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"useradmin/api/services"
+)
+
+// productEditorDB 拥有 product:update、product:status 权限的编辑，租户中有一条
+// 禁止下架自己创建的商品的拒绝策略
+func productEditorDB(username string) *fakeDB {
+	return &fakeDB{tables: map[string][]map[string]driver.Value{
+		"users":            {{"id": int64(7), "username": username, "status": int64(1), "tenant_id": int64(1)}},
+		"tenants":          {{"id": int64(1), "code": "shop", "status": int64(1)}},
+		"roles":            {{"id": int64(2), "tenant_id": int64(1), "name": "编辑", "data_scope": "all"}},
+		"role_permissions": {{"role_id": int64(2), "permission_id": int64(1)}, {"role_id": int64(2), "permission_id": int64(2)}},
+		"permissions":      {{"id": int64(1), "code": "product:update"}, {"id": int64(2), "code": "product:status"}},
+		"policies": {{"id": int64(5), "tenant_id": int64(1), "name": "不能下架自己的商品", "effect": "deny", "permission": "product:status", "enabled": true,
+			"conditions": `[{"attr":"resource.created_by","op":"eq","ref":"subject.id"},{"attr":"resource.new_status","op":"eq","value":0}]`}},
+		"products": {{"id": int64(3), "tenant_id": int64(1), "title": "商品", "status": int64(1), "created_by": int64(7)}},
+	}}
+}
+
+func updateProduct(t *testing.T, username, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPut, "/api/products/3", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "id", Value: "3"}}
+	c.Set("username", username)
+	UpdateProduct(c)
+	return w
+}
+
+func TestUpdateProductStatusEvaluatesPolicies(t *testing.T) {
+	const username = "product-status-editor"
+	useFakeDB(t, productEditorDB(username))
+	t.Cleanup(func() {
+		services.InvalidateUserPermissions(7)
+		services.InvalidatePolicies(1)
+	})
+
+	// 通过修改商品接口下架，与 PUT /products/:id/status 一样被拒绝策略拦截
+	w := updateProduct(t, username, `{"title":"商品","status":0}`)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status change: code = %d, body = %s", w.Code, w.Body)
+	}
+	var resp struct{ Policy string }
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Policy != "不能下架自己的商品" {
+		t.Errorf("status change: body = %s, want the deny policy", w.Body)
+	}
+
+	// 不改变状态时只需要 product:update
+	if w := updateProduct(t, username, `{"title":"新标题","status":1}`); w.Code != http.StatusOK {
+		t.Errorf("unchanged status: code = %d, body = %s", w.Code, w.Body)
+	}
+}
@@ -1,35 +1,52 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
-	"strings"
+	"sort"
 	"strconv"
+	"gorm.io/gorm"
 	"useradmin/api/config"
+	"useradmin/api/dto"
 	"useradmin/api/models"
+	"useradmin/api/services"
 	"github.com/gin-gonic/gin"
 )
 
 // GetRoles 获取角色列表
 func GetRoles(c *gin.Context) {
 	var roles []models.Role
-	if err := config.DB.Preload("Permissions").Find(&roles).Error; err != nil {
+	if err := tenantDB(c).Preload("Permissions").Preload("Parents").Preload("DataDepartments").Find(&roles).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取角色列表失败"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"data": roles})
+	c.JSON(http.StatusOK, gin.H{"data": dto.NewRoles(roles)})
 }
 
 // CreateRole 创建角色
 func CreateRole(c *gin.Context) {
-	var role models.Role
-	if err := c.ShouldBindJSON(&role); err != nil {
+	var req struct {
+		models.Role
+		ParentIDs         []uint `json:"parent_ids"`
+		DataDepartmentIDs []uint `json:"data_department_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
 		return
 	}
+	role := req.Role
+	role.Parents = nil
+	if err := services.ValidateDataScope(role.DataScope); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if role.DataScope == "" {
+		role.DataScope = models.DataScopeAll
+	}
 
 	// 检查角色名是否已存在
 	var count int64
-	if err := config.DB.Model(&models.Role{}).Where("name = ?", role.Name).Count(&count).Error; err != nil {
+	if err := tenantDB(c).Model(&models.Role{}).Where("name = ?", role.Name).Count(&count).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "检查角色名失败"})
 		return
 	}
@@ -39,33 +56,74 @@ func CreateRole(c *gin.Context) {
 		return
 	}
 
-	if err := config.DB.Create(&role).Error; err != nil {
+	// 系统内置角色只能由初始化数据创建，超级管理员角色只能由超级管理员创建
+	role.IsSystem = false
+	if role.IsSuperuser {
+		if err := services.GuardSuperuserGrant(tenantDB(c), currentSubject(c), nil, true); err != nil {
+			respondGuardError(c, err)
+			return
+		}
+	}
+
+	// 继承超级管理员角色等同于获得其全部权限
+	if err := services.GuardSuperuserGrant(tenantDB(c), currentSubject(c), req.ParentIDs, false); err != nil {
+		if !respondGuardError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "创建角色失败"})
+		}
+		return
+	}
+
+	err := tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Parents").Create(&role).Error; err != nil {
+			return err
+		}
+		if err := services.SetRoleDataDepartments(tx, &role, req.DataDepartmentIDs); err != nil {
+			return err
+		}
+		if err := services.SetRoleParents(tx, &role, req.ParentIDs); err != nil {
+			return err
+		}
+		if err := tx.Preload("Parents").Preload("DataDepartments").First(&role, role.ID).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, services.AuditRoleCreated, services.AuditTargetRole, role.ID, nil, dto.NewRole(role))
+	})
+	if respondRoleParentError(c, err) {
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建角色失败"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": role})
+	c.JSON(http.StatusOK, gin.H{"data": dto.NewRole(role)})
 }
 
 // UpdateRole 更新角色
 func UpdateRole(c *gin.Context) {
 	id := c.Param("id")
 
-	// 不允许修改超级管理员角色
-	if id == "1" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "不能修改超级管理员角色"})
+	var role models.Role
+	if err := tenantDB(c).First(&role, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
 		return
 	}
 
-	var role models.Role
-	if err := config.DB.First(&role, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+	// 不允许修改系统内置角色
+	if err := services.GuardRoleChange(role); err != nil {
+		respondGuardError(c, err)
 		return
 	}
 
 	var updateData struct {
-		Name        string `json:"name"`
-		Description string `json:"description"`
+		Name        string  `json:"name"`
+		Description string  `json:"description"`
+		RequireMFA  *bool   `json:"require_mfa"`
+		IsSuperuser *bool   `json:"is_superuser"`
+		ParentIDs   *[]uint `json:"parent_ids"`
+		// 数据范围，custom 时由 data_department_ids 指定部门
+		DataScope         *string `json:"data_scope"`
+		DataDepartmentIDs *[]uint `json:"data_department_ids"`
 	}
 
 	if err := c.ShouldBindJSON(&updateData); err != nil {
@@ -76,7 +134,7 @@ func UpdateRole(c *gin.Context) {
 	// 如果更新名称，检查是否已存在
 	if updateData.Name != "" && updateData.Name != role.Name {
 		var count int64
-		if err := config.DB.Model(&models.Role{}).Where("name = ?", updateData.Name).Count(&count).Error; err != nil {
+		if err := tenantDB(c).Model(&models.Role{}).Where("name = ?", updateData.Name).Count(&count).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "检查角色名失败"})
 			return
 		}
@@ -89,28 +147,98 @@ func UpdateRole(c *gin.Context) {
 	}
 
 	role.Description = updateData.Description
+	if updateData.RequireMFA != nil {
+		role.RequireMFA = *updateData.RequireMFA
+	}
+	if updateData.IsSuperuser != nil && *updateData.IsSuperuser != role.IsSuperuser {
+		if err := services.GuardSuperuserGrant(tenantDB(c), currentSubject(c), nil, true); err != nil {
+			respondGuardError(c, err)
+			return
+		}
+		role.IsSuperuser = *updateData.IsSuperuser
+	}
+	if updateData.DataScope != nil {
+		if err := services.ValidateDataScope(*updateData.DataScope); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		role.DataScope = *updateData.DataScope
+		if role.DataScope == "" {
+			role.DataScope = models.DataScopeAll
+		}
+	}
+	if updateData.ParentIDs != nil {
+		if err := services.GuardSuperuserGrant(tenantDB(c), currentSubject(c), *updateData.ParentIDs, false); err != nil {
+			if !respondGuardError(c, err) {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "更新角色失败"})
+			}
+			return
+		}
+	}
 
-	if err := config.DB.Save(&role).Error; err != nil {
+	err := tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := lockForAudit(tx, &models.Role{}, role.ID); err != nil {
+			return err
+		}
+		before := auditRole(tx, role.ID)
+		if err := tx.Omit("Parents").Save(&role).Error; err != nil {
+			return err
+		}
+		if updateData.ParentIDs != nil {
+			if err := services.SetRoleParents(tx, &role, *updateData.ParentIDs); err != nil {
+				return err
+			}
+		}
+		if updateData.DataScope != nil || updateData.DataDepartmentIDs != nil {
+			var departmentIDs []uint
+			if updateData.DataDepartmentIDs != nil {
+				departmentIDs = *updateData.DataDepartmentIDs
+			} else if err := tx.Model(&models.RoleDataDepartment{}).Where("role_id = ?", role.ID).Pluck("department_id", &departmentIDs).Error; err != nil {
+				return err
+			}
+			if err := services.SetRoleDataDepartments(tx, &role, departmentIDs); err != nil {
+				return err
+			}
+		}
+		if err := services.EnsureSuperuserRemains(tx); err != nil {
+			return err
+		}
+		if err := tx.Preload("Parents").Preload("DataDepartments").First(&role, role.ID).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, services.AuditRoleUpdated, services.AuditTargetRole, role.ID, before, dto.NewRole(role))
+	})
+	if respondGuardError(c, err) || respondRoleParentError(c, err) {
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新角色失败"})
 		return
 	}
+	services.InvalidateRolePermissions(role.ID)
 
-	c.JSON(http.StatusOK, gin.H{"data": role})
+	c.JSON(http.StatusOK, gin.H{"data": dto.NewRole(role)})
 }
 
 // DeleteRole 删除角色
 func DeleteRole(c *gin.Context) {
 	id := c.Param("id")
 
-	// 不允许删除超级管理员角色
-	if id == "1" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "不能删除超级管理员角色"})
+	var role models.Role
+	if err := tenantDB(c).First(&role, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		return
+	}
+
+	// 不允许删除系统内置角色
+	if err := services.GuardRoleChange(role); err != nil {
+		respondGuardError(c, err)
 		return
 	}
 
 	// 检查是否有用户正在使用该角色
 	var count int64
-	if err := config.DB.Model(&models.User{}).Where("role_id = ?", id).Count(&count).Error; err != nil {
+	if err := tenantDB(c).Model(&models.UserRole{}).Where("role_id = ?", role.ID).Count(&count).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "检查角色使用状态失败"})
 		return
 	}
@@ -120,10 +248,28 @@ func DeleteRole(c *gin.Context) {
 		return
 	}
 
-	if err := config.DB.Delete(&models.Role{}, id).Error; err != nil {
+	// 子角色不再继承被删除角色的权限
+	err := tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := lockForAudit(tx, &models.Role{}, role.ID); err != nil {
+			return err
+		}
+		before := auditRole(tx, role.ID)
+		if err := services.DetachRole(tx, role.ID); err != nil {
+			return err
+		}
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.RoleDataDepartment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&role).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, services.AuditRoleDeleted, services.AuditTargetRole, role.ID, before, nil)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除角色失败"})
 		return
 	}
+	services.InvalidateRolePermissions(role.ID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
 }
@@ -131,24 +277,45 @@ func DeleteRole(c *gin.Context) {
 // GetPermissions 获取权限列表
 func GetPermissions(c *gin.Context) {
 	var permissions []models.Permission
-	if err := config.DB.Order("code asc").Find(&permissions).Error; err != nil {
+	if err := tenantDB(c).Order("code asc").Find(&permissions).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取权限列表失败"})
 		return
 	}
 
 	// 按模块分组返回权限
-	moduleMap := make(map[string][]models.Permission)
+	moduleMap := make(map[string][]dto.Permission)
 	for _, perm := range permissions {
-		module := strings.Split(perm.Code, ":")[0]
-		moduleMap[module] = append(moduleMap[module], perm)
+		module := services.PermissionModule(perm.Code)
+		moduleMap[module] = append(moduleMap[module], dto.NewPermission(perm))
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"data": permissions,
+		"data": dto.NewPermissions(permissions),
 		"modules": moduleMap,
 	})
 }
 
+// GetRoutePermissions 获取接口与所需权限的对应关系，以及没有任何接口使用的内置权限
+func GetRoutePermissions(c *gin.Context) {
+	routes := services.RoutePermissions()
+	used := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		used[route.Permission] = true
+	}
+
+	unrouted := []dto.PermissionDefinition{}
+	for _, def := range services.PermissionDefinitions() {
+		if !used[def.Code] && !services.IsWildcardPermission(def.Code) {
+			unrouted = append(unrouted, dto.NewPermissionDefinition(def))
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":     dto.NewRoutePermissions(routes),
+		"unrouted": unrouted,
+	})
+}
+
 // CreatePermission 创建权限
 func CreatePermission(c *gin.Context) {
 	var permission models.Permission
@@ -158,14 +325,14 @@ func CreatePermission(c *gin.Context) {
 	}
 
 	// 验证权限代码格式
-	if !strings.Contains(permission.Code, ":") {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "权限代码格式错误，应为 module:action"})
+	if err := services.ValidatePermissionCode(permission.Code); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	// 检查权限代码是否已存在
 	var count int64
-	if err := config.DB.Model(&models.Permission{}).Where("code = ?", permission.Code).Count(&count).Error; err != nil {
+	if err := tenantDB(c).Model(&models.Permission{}).Where("code = ?", permission.Code).Count(&count).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "检查权限代码失败"})
 		return
 	}
@@ -175,14 +342,20 @@ func CreatePermission(c *gin.Context) {
 		return
 	}
 
-	if err := config.DB.Create(&permission).Error; err != nil {
+	err := tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&permission).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, services.AuditPermissionCreated, services.AuditTargetPermission, permission.ID, nil, dto.NewPermission(permission))
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建权限失败"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "创建成功",
-		"data": permission,
+		"data": dto.NewPermission(permission),
 	})
 }
 
@@ -190,7 +363,7 @@ func CreatePermission(c *gin.Context) {
 func UpdatePermission(c *gin.Context) {
 	id := c.Param("id")
 	var permission models.Permission
-	if err := config.DB.First(&permission, id).Error; err != nil {
+	if err := tenantDB(c).First(&permission, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "权限不存在"})
 		return
 	}
@@ -205,17 +378,25 @@ func UpdatePermission(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
 		return
 	}
+	before := dto.NewPermission(permission)
 
 	// 如果更新code，验证格式
-	if updateData.Code != "" && !strings.Contains(updateData.Code, ":") {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "权限代码格式错误，应为 module:action"})
-		return
+	if updateData.Code != "" {
+		if err := services.ValidatePermissionCode(updateData.Code); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 	}
 
 	// 如果更新code，检查唯一性
 	if updateData.Code != "" && updateData.Code != permission.Code {
+		// 接口按代码要求内置权限，修改代码会使对应接口无人可用
+		if _, ok := services.LookupPermissionDefinition(permission.Code); ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "内置权限的代码不能修改"})
+			return
+		}
 		var count int64
-		if err := config.DB.Model(&models.Permission{}).Where("code = ?", updateData.Code).Count(&count).Error; err != nil {
+		if err := tenantDB(c).Model(&models.Permission{}).Where("code = ?", updateData.Code).Count(&count).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "检查权限代码失败"})
 			return
 		}
@@ -232,23 +413,40 @@ func UpdatePermission(c *gin.Context) {
 	}
 	permission.Description = updateData.Description
 
-	if err := config.DB.Save(&permission).Error; err != nil {
+	err := tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&permission).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, services.AuditPermissionUpdated, services.AuditTargetPermission, permission.ID, before, dto.NewPermission(permission))
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新权限失败"})
 		return
 	}
+	services.InvalidateAllPermissions()
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "更新成功",
-		"data": permission,
+		"data": dto.NewPermission(permission),
 	})
 }
 
 // DeletePermission 删除权限
 func DeletePermission(c *gin.Context) {
 	id := c.Param("id")
+	var permission models.Permission
+	if err := tenantDB(c).First(&permission, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "权限不存在"})
+		return
+	}
+	if _, ok := services.LookupPermissionDefinition(permission.Code); ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "内置权限不能删除"})
+		return
+	}
 
 	// 检查权限是否被角色使用
 	var count int64
+	// 权限定义为全局数据，检查所有租户的角色是否在使用
 	if err := config.DB.Table("role_permissions").Where("permission_id = ?", id).Count(&count).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "检查权限使用状态失败"})
 		return
@@ -259,10 +457,17 @@ func DeletePermission(c *gin.Context) {
 		return
 	}
 
-	if err := config.DB.Delete(&models.Permission{}, id).Error; err != nil {
+	err := tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&permission).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, services.AuditPermissionDeleted, services.AuditTargetPermission, permission.ID, dto.NewPermission(permission), nil)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除权限失败"})
 		return
 	}
+	services.InvalidateAllPermissions()
 
 	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
 }
@@ -277,9 +482,16 @@ func GetRolePermissions(c *gin.Context) {
 		})
 		return
 	}
-	
+
+	// 只能查看本租户的角色
+	var role models.Role
+	if err := tenantDB(c).First(&role, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		return
+	}
+
 	var permissions []models.Permission
-	result := config.DB.Table("role_permissions").
+	result := tenantDB(c).Table("role_permissions").
 		Select("permissions.*").
 		Joins("LEFT JOIN permissions ON role_permissions.permission_id = permissions.ID").
 		Where("role_permissions.role_id = ?", uint(id)).
@@ -292,8 +504,18 @@ func GetRolePermissions(c *gin.Context) {
 		return
 	}
 	
+	// 临时权限的有效期
+	windows, err := services.RolePermissionWindows(tenantDB(c), role.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "获取角色权限失败",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"data": permissions,
+		"data":    dto.NewPermissions(permissions),
+		"windows": windows,
 	})
 }
 
@@ -307,6 +529,18 @@ func UpdateRolePermissions(c *gin.Context) {
 		})
 		return
 	}
+	var role models.Role
+	if err := tenantDB(c).First(&role, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		return
+	}
+
+	// 系统内置角色的权限由初始化数据维护
+	if err := services.GuardRoleChange(role); err != nil {
+		respondGuardError(c, err)
+		return
+	}
+
 	var requestBody struct {
 		PermissionIDs []uint `json:"permission_ids"`
 	}
@@ -318,36 +552,254 @@ func UpdateRolePermissions(c *gin.Context) {
 		return
 	}
 	
-	// 开启事务
-	tx := config.DB.Begin()
-	
-	// 删除原有权限
-	if err := tx.Table("role_permissions").Where("role_id = ?", uint(id)).Delete(nil).Error; err != nil {
-		tx.Rollback()
+	// 保留的权限沿用原有效期
+	err = tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := lockForAudit(tx, &models.Role{}, role.ID); err != nil {
+			return err
+		}
+		before := auditRolePermissions(tx, role.ID)
+		if err := services.SetRolePermissions(tx, uint(id), requestBody.PermissionIDs); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, services.AuditRolePermissionsChanged, services.AuditTargetRole, role.ID, before, auditRolePermissions(tx, role.ID))
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "更新角色权限失败",
 		})
 		return
 	}
-	
-	// 添加新权限
-	for _, permID := range requestBody.PermissionIDs {
-		rolePermission := models.RolePermission{
-			RoleID:       uint(id),
-			PermissionID: permID,
-		}
-		if err := tx.Create(&rolePermission).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "更新角色权限失败",
-			})
-			return
-		}
-	}
-	
-	tx.Commit()
+	services.InvalidateRolePermissions(uint(id))
 	
 	c.JSON(http.StatusOK, gin.H{
 		"message": "角色权限更新成功",
 	})
-} 
\ No newline at end of file
+} 
+
+// RolePermissionGrantRequest 为角色授予单个权限请求结构，可指定有效期作为临时授权
+type RolePermissionGrantRequest struct {
+	PermissionID uint `json:"permission_id" binding:"required"`
+	services.GrantWindow
+}
+
+// GrantRolePermission 为角色授予单个权限，可设置有效期，已授予时覆盖原有效期
+func GrantRolePermission(c *gin.Context) {
+	var role models.Role
+	if err := tenantDB(c).First(&role, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		return
+	}
+	if err := services.GuardRoleChange(role); err != nil {
+		respondGuardError(c, err)
+		return
+	}
+
+	var req RolePermissionGrantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	err := tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := lockForAudit(tx, &models.Role{}, role.ID); err != nil {
+			return err
+		}
+		before := auditRolePermissions(tx, role.ID)
+		if err := services.GrantRolePermission(tx, role.ID, req.PermissionID, req.GrantWindow); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, services.AuditRolePermissionsChanged, services.AuditTargetRole, role.ID, before, auditRolePermissions(tx, role.ID))
+	})
+	if errors.Is(err, services.ErrPermissionNotFound) || errors.Is(err, services.ErrInvalidGrantWindow) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "授予权限失败"})
+		return
+	}
+	services.InvalidateRolePermissions(role.ID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "授予成功"})
+}
+
+// RevokeRolePermission 收回角色的单个权限
+func RevokeRolePermission(c *gin.Context) {
+	var role models.Role
+	if err := tenantDB(c).First(&role, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		return
+	}
+	if err := services.GuardRoleChange(role); err != nil {
+		respondGuardError(c, err)
+		return
+	}
+	permissionID, err := strconv.ParseUint(c.Param("permission_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的权限ID"})
+		return
+	}
+
+	err = tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := lockForAudit(tx, &models.Role{}, role.ID); err != nil {
+			return err
+		}
+		before := auditRolePermissions(tx, role.ID)
+		if err := services.RevokeRolePermission(tx, role.ID, uint(permissionID)); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, services.AuditRolePermissionsChanged, services.AuditTargetRole, role.ID, before, auditRolePermissions(tx, role.ID))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "收回权限失败"})
+		return
+	}
+	services.InvalidateRolePermissions(role.ID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "收回成功"})
+}
+
+// UpdateRoleParents 设置角色继承的父角色
+func UpdateRoleParents(c *gin.Context) {
+	var role models.Role
+	if err := tenantDB(c).First(&role, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		return
+	}
+
+	// 系统内置角色的权限由初始化数据维护
+	if err := services.GuardRoleChange(role); err != nil {
+		respondGuardError(c, err)
+		return
+	}
+
+	var req struct {
+		ParentIDs []uint `json:"parent_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	if err := services.GuardSuperuserGrant(tenantDB(c), currentSubject(c), req.ParentIDs, false); err != nil {
+		if !respondGuardError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "更新父角色失败"})
+		}
+		return
+	}
+
+	err := tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := lockForAudit(tx, &models.Role{}, role.ID); err != nil {
+			return err
+		}
+		before := auditRole(tx, role.ID)
+		if err := services.SetRoleParents(tx, &role, req.ParentIDs); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, services.AuditRoleParentsChanged, services.AuditTargetRole, role.ID, before, auditRole(tx, role.ID))
+	})
+	if respondRoleParentError(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新父角色失败"})
+		return
+	}
+	services.InvalidateRolePermissions(role.ID)
+
+	tenantDB(c).Preload("Permissions").Preload("Parents").First(&role, role.ID)
+	c.JSON(http.StatusOK, gin.H{"data": dto.NewRole(role)})
+}
+
+// GetRoleEffectivePermissions 获取角色的有效权限，区分直接授予和继承的权限
+func GetRoleEffectivePermissions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的角色ID"})
+		return
+	}
+
+	set, err := services.ResolveRolePermissions(tenantDB(c), uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "角色不存在"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取角色权限失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": dto.NewRolePermissionSet(set)})
+}
+
+// auditRole 加载角色的响应结构（不含权限），用于记录审计事件的变更前后状态
+func auditRole(db *gorm.DB, id uint) any {
+	var role models.Role
+	if err := db.Preload("Parents").Preload("DataDepartments").First(&role, id).Error; err != nil {
+		return nil
+	}
+	return dto.NewRole(role)
+}
+
+// auditRolePermissions 角色直接授予的权限代码和临时权限的有效期，用于记录权限变更
+func auditRolePermissions(db *gorm.DB, roleID uint) any {
+	var links []models.RolePermission
+	if err := db.Preload("Permission").Where("role_id = ?", roleID).Find(&links).Error; err != nil {
+		return nil
+	}
+	codes := make([]string, 0, len(links))
+	windows := make(map[string]services.GrantWindow)
+	for _, link := range links {
+		codes = append(codes, link.Permission.Code)
+		if link.ValidFrom != nil || link.ValidUntil != nil {
+			windows[link.Permission.Code] = services.GrantWindow{ValidFrom: link.ValidFrom, ValidUntil: link.ValidUntil}
+		}
+	}
+	sort.Strings(codes)
+	return gin.H{"permissions": codes, "windows": windows}
+}
+
+// currentSubject 获取当前登录用户的权限快照
+func currentSubject(c *gin.Context) *services.Subject {
+	subject, err := services.LoadSubject(c.GetString("username"))
+	if err != nil {
+		return nil
+	}
+	return subject
+}
+
+// scoped 返回按当前用户数据范围过滤 resource 的查询
+func scoped(c *gin.Context, resource string) *gorm.DB {
+	return tenantDB(c).Scopes(services.DataScope(currentSubject(c), resource))
+}
+
+// tenantDB 返回限定在当前登录用户租户内的数据库连接（见 services.RegisterTenantScope），未登录的请求不做限制
+func tenantDB(c *gin.Context) *gorm.DB {
+	return config.DB.WithContext(c.Request.Context())
+}
+
+// respondGuardError 受保护记录相关的错误返回 403，返回 true 表示已处理
+func respondGuardError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, services.ErrSystemRole),
+		errors.Is(err, services.ErrSystemUser),
+		errors.Is(err, services.ErrLastSuperuser),
+		errors.Is(err, services.ErrSuperuserGrant),
+		errors.Is(err, services.ErrPolicyGrant),
+		errors.Is(err, services.ErrDepartmentScope):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return true
+	}
+	return false
+}
+
+// respondRoleParentError 设置父角色相关的错误返回 400，返回 true 表示已处理
+func respondRoleParentError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, services.ErrRoleCycle),
+		errors.Is(err, services.ErrRoleNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return true
+	}
+	return false
+}
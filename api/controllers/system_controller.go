@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"useradmin/api/middleware"
+	"useradmin/api/services"
+)
+
+// GetPermissionCacheStats 获取权限缓存命中率等统计信息
+func GetPermissionCacheStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": services.GetPermissionCacheStats()})
+}
+
+// GetLogWriterStats 获取请求日志队列长度、已写入和丢弃的条数
+func GetLogWriterStats(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": middleware.GetLogWriterStats()})
+}
+
+// GetLogRetention 获取日志保留策略、最近的清理记录、待清理的条数和归档文件
+func GetLogRetention(c *gin.Context) {
+	status, err := services.GetLogRetentionStatus()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取日志清理状态失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": status})
+}
+
+// RunLogRetention 立即在后台执行一次日志归档和清理，通过 GetLogRetention 查看进度
+func RunLogRetention(c *gin.Context) {
+	run, err := services.TriggerLogRetention(c.GetString("username"))
+	switch {
+	case errors.Is(err, services.ErrLogRetentionRunning):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrLogRetentionDisabled):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "启动日志清理失败"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"data": run})
+}
+
+// DownloadLogArchive 下载日志归档文件
+func DownloadLogArchive(c *gin.Context) {
+	path, err := services.LogArchivePath(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.FileAttachment(path, filepath.Base(path))
+}
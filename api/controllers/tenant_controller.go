@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"useradmin/api/config"
+	"useradmin/api/dto"
+	"useradmin/api/models"
+	"useradmin/api/services"
+)
+
+// CreateTenantRequest 创建租户请求结构，同时创建租户的管理员账号
+type CreateTenantRequest struct {
+	Code          string `json:"code" binding:"required"`
+	Name          string `json:"name" binding:"required"`
+	AdminUsername string `json:"admin_username" binding:"required"`
+	AdminPassword string `json:"admin_password" binding:"required"`
+}
+
+// UpdateTenantRequest 更新租户请求结构
+type UpdateTenantRequest struct {
+	Name   string `json:"name"`
+	Status *int   `json:"status"`
+}
+
+// 租户是平台级数据，以下接口都不按租户限定，只允许平台租户的用户访问（见 middleware.RequirePlatform）
+
+// GetTenants 获取租户列表
+func GetTenants(c *gin.Context) {
+	var tenants []models.Tenant
+	if err := config.DB.Order("id").Find(&tenants).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取租户列表失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": dto.NewTenants(tenants)})
+}
+
+// CreateTenant 创建租户，并初始化租户的超级管理员角色和管理员账号
+func CreateTenant(c *gin.Context) {
+	var req CreateTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	tenant := models.Tenant{Code: req.Code, Name: req.Name, Status: 1}
+	var admin *models.User
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if admin, err = services.CreateTenant(tx, &tenant, req.AdminUsername, req.AdminPassword); err != nil {
+			return err
+		}
+		return recordAudit(c, auditTx(c, tx), services.AuditTenantCreated, services.AuditTargetTenant, tenant.ID, nil, dto.NewTenant(tenant))
+	})
+	var policyErr *services.PasswordPolicyError
+	switch {
+	case errors.Is(err, services.ErrTenantCodeExists), errors.Is(err, services.ErrUsernameExists):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.As(err, &policyErr):
+		respondPasswordError(c, err)
+		return
+	case err != nil:
+		log.Printf("创建租户失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建租户失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  dto.NewTenant(tenant),
+		"admin": dto.NewUser(*admin),
+	})
+}
+
+// UpdateTenant 更新租户名称或启用、停用租户
+func UpdateTenant(c *gin.Context) {
+	var tenant models.Tenant
+	if err := config.DB.First(&tenant, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "租户不存在"})
+		return
+	}
+
+	var req UpdateTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+	before := dto.NewTenant(tenant)
+
+	statusChanged := req.Status != nil && *req.Status != tenant.Status
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if req.Name != "" && req.Name != tenant.Name {
+			tenant.Name = req.Name
+			if err := tx.Model(&tenant).Update("name", tenant.Name).Error; err != nil {
+				return err
+			}
+		}
+		if statusChanged {
+			if err := services.UpdateTenantStatus(tx, &tenant, *req.Status); err != nil {
+				return err
+			}
+		}
+		return recordAudit(c, auditTx(c, tx), services.AuditTenantUpdated, services.AuditTargetTenant, tenant.ID, before, dto.NewTenant(tenant))
+	})
+	if errors.Is(err, services.ErrPlatformTenant) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新租户失败"})
+		return
+	}
+	if statusChanged {
+		services.InvalidateTenantPermissions(tenant.ID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": dto.NewTenant(tenant)})
+}
+
+// auditTx 租户操作的事务不按租户限定，审计事件需要带上当前请求的租户，归属操作人所在的平台租户
+func auditTx(c *gin.Context, tx *gorm.DB) *gorm.DB {
+	return tx.WithContext(c.Request.Context())
+}
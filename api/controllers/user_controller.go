@@ -1,14 +1,19 @@
 package controllers
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 	"log"
 	"strconv"
+	"time"
 	"useradmin/api/config"
+	"useradmin/api/dto"
 	"useradmin/api/middleware"
 	"useradmin/api/models"
-	"fmt"
+	"useradmin/api/services"
+	"math"
 )
 
 // LoginRequest 登录请求结构
@@ -17,20 +22,40 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
-// CreateUserRequest 创建用户请求结构
+// CreateUserRequest 创建用户请求结构，role_id 为主角色，role_ids 为全部角色，至少提供一个
 type CreateUserRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
-	RoleID   uint   `json:"role_id" binding:"required"`
+	RoleID   uint   `json:"role_id"`
+	RoleIDs  []uint `json:"role_ids"`
 	Status   int    `json:"status"`
+	// 所属部门，必须在操作人的数据范围内
+	DepartmentID uint `json:"department_id"`
 }
 
 // UpdateUserRequest 更新用户请求结构
+// role_ids 不为空时替换全部角色；只提供 role_id 时设置主角色并追加到角色列表
 type UpdateUserRequest struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
-	RoleID   uint   `json:"role_id"`
-	Status   int    `json:"status"`
+	Username string  `json:"username"`
+	Password string  `json:"password"`
+	RoleID   uint    `json:"role_id"`
+	RoleIDs  *[]uint `json:"role_ids"`
+	Status   int     `json:"status"`
+	// 只有超级管理员可以修改
+	IsSuperuser *bool `json:"is_superuser"`
+	// 调整后的部门必须在操作人的数据范围内
+	DepartmentID *uint `json:"department_id"`
+}
+
+// UserRoleRequest 为用户分配角色请求结构，可指定有效期（valid_from、valid_until）作为临时授权
+type UserRoleRequest struct {
+	RoleID uint `json:"role_id" binding:"required"`
+	services.GrantWindow
+}
+
+// UserRolesRequest 替换用户角色请求结构
+type UserRolesRequest struct {
+	RoleIDs []uint `json:"role_ids"`
 }
 
 // Login 处理用户登录
@@ -41,9 +66,22 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	// 登录失败次数过多时拒绝尝试
+	if wait := services.CheckLogin(req.Username, c.ClientIP()); wait > 0 {
+		retryAfter := int(math.Ceil(wait.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.JSON(429, gin.H{
+			"error":       "登录失败次数过多，请稍后再试",
+			"retry_after": retryAfter,
+		})
+		return
+	}
+
 	var user models.User
-	if err := config.DB.Preload("Role.Permissions").Where("username = ?", req.Username).First(&user).Error; err != nil {
+	// 用户名全局唯一，登录前还不知道租户
+	if err := config.DB.Preload("Role").Preload("Department").Preload("Roles.Permissions").Where("username = ?", req.Username).First(&user).Error; err != nil {
 		log.Printf("查询用户失败: %v", err)
+		services.RecordLoginFailure(req.Username, c.ClientIP())
 		c.JSON(401, gin.H{"error": "用户名或密码错误"})
 		return
 	}
@@ -51,12 +89,58 @@ func Login(c *gin.Context) {
 	// 验证密码
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
 		log.Printf("密码验证失败: %v", err)
+		services.RecordLoginFailure(req.Username, c.ClientIP())
 		c.JSON(401, gin.H{"error": "用户名或密码错误"})
 		return
 	}
+	services.RecordLoginSuccess(req.Username)
+	c.Set("tenant_id", user.TenantID)
+
+	// 检查用户状态
+	if user.Status != 1 {
+		c.JSON(403, gin.H{"error": "用户已被禁用"})
+		return
+	}
+
+	// 租户停用后其用户不能登录
+	if err := services.CheckTenantActive(user.TenantID); err != nil {
+		c.JSON(403, gin.H{"error": services.ErrTenantDisabled.Error()})
+		return
+	}
+
+	// 已启用或角色强制要求两步验证时，先返回临时token
+	mfaRequired, err := services.MFARequired(config.DB, user.ID)
+	if err != nil {
+		log.Printf("查询用户角色失败: %v", err)
+		c.JSON(500, gin.H{"error": "登录失败"})
+		return
+	}
+	if user.TOTPEnabled || mfaRequired {
+		purpose := middleware.PurposeMFAVerify
+		if !user.TOTPEnabled {
+			purpose = middleware.PurposeMFAEnroll
+		}
+		mfaToken, err := middleware.GenerateMFAToken(user.Username, purpose)
+		if err != nil {
+			log.Printf("生成token失败: %v", err)
+			c.JSON(500, gin.H{"error": "生成token失败"})
+			return
+		}
+		c.JSON(200, gin.H{
+			"mfa_required":        true,
+			"mfa_enroll_required": !user.TOTPEnabled,
+			"mfa_token":           mfaToken,
+		})
+		return
+	}
+
+	respondLogin(c, user, nil)
+}
 
+// respondLogin 签发token并返回登录结果，extra 中的字段会合并到响应中
+func respondLogin(c *gin.Context, user models.User, extra gin.H) {
 	// 生成 JWT token
-	token, err := middleware.GenerateToken(user.Username)
+	tokens, err := middleware.IssueTokens(user)
 	if err != nil {
 		log.Printf("生成token失败: %v", err)
 		c.JSON(500, gin.H{"error": "生成token失败"})
@@ -64,78 +148,134 @@ func Login(c *gin.Context) {
 	}
 
 	// 获取用户权限列表
-	var permissions []string
-	for _, perm := range user.Role.Permissions {
-		permissions = append(permissions, perm.Code)
+	permissions := userPermissions(user)
+
+	response := gin.H{
+		"token":                tokens.AccessToken,
+		"refresh_token":        tokens.RefreshToken,
+		"expires_in":           tokens.ExpiresIn,
+		"user":                 dto.NewUserWithPermissions(user, permissions),
+		"must_change_password": services.PasswordExpired(user),
 	}
-
-	c.JSON(200, gin.H{
-		"token": token,
-		"user": gin.H{
-			"id":          user.ID,
-			"username":    user.Username,
-			"role_id":     user.RoleID,
-			"role_name":   user.Role.Name,
-			"permissions": permissions,
-			"status":      user.Status,
-		},
-	})
+	for k, v := range extra {
+		response[k] = v
+	}
+	c.JSON(200, response)
 }
 
 // GetUsers 获取用户列表
 func GetUsers(c *gin.Context) {
+	query := scoped(c, services.DataResourceUser).Preload("Role").Preload("Department").Preload("Roles")
+	departmentIDs, ok, err := departmentFilter(c)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "获取用户列表失败"})
+		return
+	}
+	if ok {
+		query = query.Where("department_id IN ?", departmentIDs)
+	}
+
 	var users []models.User
-	if err := config.DB.Preload("Role").Find(&users).Error; err != nil {
+	if err := query.Find(&users).Error; err != nil {
 		c.JSON(500, gin.H{"error": "获取用户列表失败"})
 		return
 	}
 
-	c.JSON(200, users)
+	c.JSON(200, dto.NewUsers(users))
 }
 
 // CreateUser 创建用户
 func CreateUser(c *gin.Context) {
 	var req CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(400, gin.H{"error": "无效的请求参数" + fmt.Sprintf("%s %s", err, req)})
+		c.JSON(400, gin.H{"error": "无效的请求参数"})
 		return
 	}
 
 	// 检查用户名是否已存在
 	var count int64
+	// 用户名全局唯一，需要检查所有租户
 	config.DB.Model(&models.User{}).Where("username = ?", req.Username).Count(&count)
 	if count > 0 {
 		c.JSON(400, gin.H{"error": "用户名已存在"})
 		return
 	}
 
-	// 加密密码
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-	if err != nil {
-		c.JSON(500, gin.H{"error": "密码加密失败"})
+	roleIDs := req.RoleIDs
+	if req.RoleID != 0 {
+		roleIDs = append([]uint{req.RoleID}, roleIDs...)
+	}
+	if len(roleIDs) == 0 {
+		c.JSON(400, gin.H{"error": "至少需要分配一个角色"})
+		return
+	}
+
+	// 只有超级管理员可以分配超级管理员角色，新用户的部门必须在数据范围内
+	subject := currentSubject(c)
+	if err := services.CheckDepartmentExists(tenantDB(c), req.DepartmentID); err != nil {
+		if !respondDepartmentError(c, err) {
+			c.JSON(500, gin.H{"error": "创建用户失败"})
+		}
+		return
+	}
+	if err := services.GuardDepartmentAccess(subject, req.DepartmentID); err != nil {
+		respondGuardError(c, err)
+		return
+	}
+	if err := services.GuardSuperuserGrant(tenantDB(c), subject, roleIDs, false); err != nil {
+		if !respondGuardError(c, err) {
+			c.JSON(500, gin.H{"error": "创建用户失败"})
+		}
 		return
 	}
 
 	user := models.User{
-		Username: req.Username,
-		Password: string(hashedPassword),
-		RoleID:   req.RoleID,
-		Status:   req.Status,
+		Username:     req.Username,
+		RoleID:       roleIDs[0],
+		Status:       req.Status,
+		DepartmentID: req.DepartmentID,
+	}
+
+	// 校验并加密密码，管理员设置的密码需要用户首次登录后修改
+	if err := services.SetPassword(tenantDB(c), &user, req.Password, true); err != nil {
+		respondPasswordError(c, err)
+		return
 	}
 
-	if err := config.DB.Create(&user).Error; err != nil {
+	var out dto.User
+	err := tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		if err := services.SetUserRoles(tx, &user, roleIDs); err != nil {
+			return err
+		}
+		if err := services.RecordPasswordHistory(tx, &user); err != nil {
+			return err
+		}
+		if err := tx.Preload("Role").Preload("Department").Preload("Roles").First(&user, user.ID).Error; err != nil {
+			return err
+		}
+		out = dto.NewUser(user)
+		return recordAudit(c, tx, services.AuditUserCreated, services.AuditTargetUser, user.ID, nil, out)
+	})
+	if errors.Is(err, services.ErrRoleNotFound) {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(500, gin.H{"error": "创建用户失败"})
 		return
 	}
 
-	c.JSON(200, user)
+	c.JSON(200, out)
 }
 
 // UpdateUser 更新用户
 func UpdateUser(c *gin.Context) {
 	id := c.Param("id")
 	var user models.User
-	if err := config.DB.First(&user, id).Error; err != nil {
+	if err := scoped(c, services.DataResourceUser).First(&user, id).Error; err != nil {
 		c.JSON(404, gin.H{"error": "用户不存在"})
 		return
 	}
@@ -146,54 +286,486 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
-	// 只更新允许修改的字段
-	if req.Password != "" {
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-		if err != nil {
-			c.JSON(500, gin.H{"error": "密码加密失败"})
+	// 系统内置用户不能禁用；只有超级管理员可以授予超级管理员角色或标记
+	grantRoleIDs := []uint{req.RoleID}
+	if req.RoleIDs != nil {
+		grantRoleIDs = append(grantRoleIDs, *req.RoleIDs...)
+	}
+	grantFlag := req.IsSuperuser != nil && *req.IsSuperuser != user.IsSuperuser
+	if err := services.GuardUserStatus(user, req.Status); err != nil {
+		respondGuardError(c, err)
+		return
+	}
+	if req.DepartmentID != nil && *req.DepartmentID != user.DepartmentID {
+		if err := services.CheckDepartmentExists(tenantDB(c), *req.DepartmentID); err != nil {
+			if !respondDepartmentError(c, err) {
+				c.JSON(500, gin.H{"error": "更新用户失败"})
+			}
+			return
+		}
+		if err := services.GuardDepartmentAccess(currentSubject(c), *req.DepartmentID); err != nil {
+			respondGuardError(c, err)
 			return
 		}
-		user.Password = string(hashedPassword)
+	}
+	if err := services.GuardSuperuserGrant(tenantDB(c), currentSubject(c), grantRoleIDs, grantFlag); err != nil {
+		if !respondGuardError(c, err) {
+			c.JSON(500, gin.H{"error": "更新用户失败"})
+		}
+		return
+	}
+	currentRoleIDs, err := services.UserRoleIDs(tenantDB(c), user.ID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "更新用户失败"})
+		return
+	}
+	resource := userAttributes(user, currentRoleIDs)
+	resource["role_changed"] = req.RoleIDs != nil && !sameIDs(currentRoleIDs, append([]uint{req.RoleID}, *req.RoleIDs...)) ||
+		req.RoleIDs == nil && req.RoleID != 0 && !containsID(currentRoleIDs, req.RoleID)
+	if !authorizeResource(c, "user:update", resource) {
+		return
 	}
 
+	// 密码修改或禁用用户后需要强制下线
+	revokeSessions := req.Password != "" || (user.Status == 1 && req.Status != 1)
+
+	// 只更新允许修改的字段
 	if req.RoleID != 0 {
 			user.RoleID = req.RoleID
 	}
 
 	user.Status = req.Status
+	if req.IsSuperuser != nil {
+		user.IsSuperuser = *req.IsSuperuser
+	}
+	if req.DepartmentID != nil {
+		user.DepartmentID = *req.DepartmentID
+	}
 
-	if err := config.DB.Save(&user).Error; err != nil {
+	// 密码与其它字段在同一个事务中保存，后续步骤失败时密码也不会修改
+	var passwordErr error
+	var out dto.User
+	err = tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := lockForAudit(tx, &models.User{}, user.ID); err != nil {
+			return err
+		}
+		before := auditUser(tx, user.ID)
+		// 管理员重置的密码需要用户下次登录后修改
+		if req.Password != "" {
+			if passwordErr = services.SetPassword(tx, &user, req.Password, true); passwordErr != nil {
+				return passwordErr
+			}
+		}
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		switch {
+		case req.RoleIDs != nil:
+			roleIDs := *req.RoleIDs
+			if req.RoleID != 0 {
+				roleIDs = append([]uint{req.RoleID}, roleIDs...)
+			}
+			if err := services.SetUserRoles(tx, &user, roleIDs); err != nil {
+				return err
+			}
+		case req.RoleID != 0:
+			if err := services.AssignUserRole(tx, &user, req.RoleID, nil); err != nil {
+				return err
+			}
+		}
+		if err := services.EnsureSuperuserRemains(tx); err != nil {
+			return err
+		}
+
+		// 重新加载用户信息，包括角色信息
+		if err := tx.Preload("Role").Preload("Department").Preload("Roles").First(&user, user.ID).Error; err != nil {
+			return err
+		}
+		out = dto.NewUser(user)
+		return recordAudit(c, tx, services.AuditUserUpdated, services.AuditTargetUser, user.ID, before, out)
+	})
+	if passwordErr != nil {
+		respondPasswordError(c, passwordErr)
+		return
+	}
+	if respondGuardError(c, err) {
+		return
+	}
+	if errors.Is(err, services.ErrRoleNotFound) {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
 		c.JSON(500, gin.H{"error": "更新用户失败"})
 		return
 	}
 
-	// 重新加载用户信息，包括角色信息
-	if err := config.DB.Preload("Role").First(&user, user.ID).Error; err != nil {
-		c.JSON(500, gin.H{"error": "获取更新后的用户信息失败"})
-		return
+	services.InvalidateUserPermissions(user.ID)
+
+	if revokeSessions {
+		if err := middleware.RevokeUserTokens(user.ID); err != nil {
+			log.Printf("吊销用户token失败: %v", err)
+			c.JSON(500, gin.H{"error": "吊销用户登录状态失败"})
+			return
+		}
 	}
 
-	c.JSON(200, user)
+	c.JSON(200, out)
 }
 
 // DeleteUser 删除用户
 func DeleteUser(c *gin.Context) {
 	id := c.Param("id")
-	
-	// 不允许删除超级管理员
-	if id == "1" {
-		c.JSON(403, gin.H{"error": "不能删除超级管理员"})
+
+	var user models.User
+	if err := scoped(c, services.DataResourceUser).First(&user, id).Error; err != nil {
+		c.JSON(404, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	// 不允许删除系统内置用户和最后一个超级管理员
+	if err := services.GuardUserDelete(user); err != nil {
+		respondGuardError(c, err)
+		return
+	}
+	roleIDs, err := services.UserRoleIDs(tenantDB(c), user.ID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "删除用户失败"})
+		return
+	}
+	if !authorizeResource(c, "user:delete", userAttributes(user, roleIDs)) {
 		return
 	}
 
-	if err := config.DB.Delete(&models.User{}, id).Error; err != nil {
+	err = tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		if err := lockForAudit(tx, &models.User{}, user.ID); err != nil {
+			return err
+		}
+		before := auditUser(tx, user.ID)
+		if err := tx.Delete(&user).Error; err != nil {
+			return err
+		}
+		if err := services.EnsureSuperuserRemains(tx); err != nil {
+			return err
+		}
+		return recordAudit(c, tx, services.AuditUserDeleted, services.AuditTargetUser, user.ID, before, nil)
+	})
+	if respondGuardError(c, err) {
+		return
+	}
+	if err != nil {
 		c.JSON(500, gin.H{"error": "删除用户失败"})
 		return
 	}
 
+	services.InvalidateUserPermissions(user.ID)
+	if err := middleware.RevokeUserTokens(user.ID); err != nil {
+		log.Printf("吊销用户token失败: %v", err)
+	}
+
 	c.JSON(200, gin.H{"message": "用户已删除"})
 }
 
+// ChangePasswordRequest 修改密码请求结构
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ChangePassword 当前用户修改自己的密码，修改后所有会话需要重新登录
+func ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	var user models.User
+	if err := tenantDB(c).Where("username = ?", c.GetString("username")).First(&user).Error; err != nil {
+		c.JSON(404, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.OldPassword)); err != nil {
+		c.JSON(400, gin.H{"error": "原密码错误"})
+		return
+	}
+
+	err := tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		return services.SetPassword(tx, &user, req.NewPassword, false)
+	})
+	if err != nil {
+		respondPasswordError(c, err)
+		return
+	}
+
+	if err := middleware.RevokeUserTokens(user.ID); err != nil {
+		log.Printf("吊销用户token失败: %v", err)
+	}
+
+	c.JSON(200, gin.H{"message": "密码已修改，请重新登录"})
+}
+
+// respondPasswordError 密码不符合策略时返回 400
+func respondPasswordError(c *gin.Context, err error) {
+	var policyErr *services.PasswordPolicyError
+	switch {
+	case errors.As(err, &policyErr):
+		c.JSON(400, gin.H{"error": err.Error(), "violations": policyErr.Violations})
+	case errors.Is(err, services.ErrPasswordReused):
+		c.JSON(400, gin.H{"error": err.Error()})
+	default:
+		log.Printf("设置密码失败: %v", err)
+		c.JSON(500, gin.H{"error": "设置密码失败"})
+	}
+}
+
+// userPermissions 获取用户权限列表（含继承的权限），通配符权限展开为具体的权限代码
+// 与 CheckPermission 一样只计当前生效的角色，查询失败时返回空列表
+func userPermissions(user models.User) []string {
+	roles, err := services.ActiveUserRoles(config.DB, user.ID, time.Now())
+	if err != nil {
+		log.Printf("获取用户角色失败: %v", err)
+		return []string{}
+	}
+	roleIDs := make([]uint, 0, len(roles))
+	for _, role := range roles {
+		roleIDs = append(roleIDs, role.ID)
+	}
+	grants, _, err := services.EffectivePermissionCodes(config.DB, roleIDs)
+	if err != nil {
+		log.Printf("获取继承权限失败: %v", err)
+		return []string{}
+	}
+
+	permissions, err := services.ExpandPermissions(grants)
+	if err != nil {
+		log.Printf("展开权限失败: %v", err)
+		return grants
+	}
+	return permissions
+}
+
+// GetUserRoles 获取用户的角色列表
+func GetUserRoles(c *gin.Context) {
+	var user models.User
+	if err := scoped(c, services.DataResourceUser).Preload("Roles.Permissions").First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(404, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	// 临时角色的有效期
+	windows, err := services.UserRoleWindows(tenantDB(c), user.ID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "获取用户角色失败"})
+		return
+	}
+
+	c.JSON(200, gin.H{
+		"primary_role_id": user.RoleID,
+		"data":            dto.NewRoles(user.Roles),
+		"windows":         windows,
+	})
+}
+
+// UpdateUserRoles 替换用户的全部角色
+func UpdateUserRoles(c *gin.Context) {
+	var req UserRolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	changeUserRoles(c, req.RoleIDs, func(tx *gorm.DB, user *models.User) error {
+		return services.SetUserRoles(tx, user, req.RoleIDs)
+	})
+}
+
+// AssignUserRole 为用户增加一个角色
+func AssignUserRole(c *gin.Context) {
+	var req UserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	changeUserRoles(c, []uint{req.RoleID}, func(tx *gorm.DB, user *models.User) error {
+		return services.AssignUserRole(tx, user, req.RoleID, &req.GrantWindow)
+	})
+}
+
+// UnassignUserRole 移除用户的一个角色
+func UnassignUserRole(c *gin.Context) {
+	roleID, err := strconv.ParseUint(c.Param("role_id"), 10, 32)
+	if err != nil {
+		c.JSON(400, gin.H{"error": "无效的角色ID"})
+		return
+	}
+
+	changeUserRoles(c, nil, func(tx *gorm.DB, user *models.User) error {
+		return services.UnassignUserRole(tx, user, uint(roleID))
+	})
+}
+
+// changeUserRoles 在事务中修改用户角色，成功后清除权限缓存并返回最新的用户信息
+// grantRoleIDs 为新授予的角色，用于检查是否有权授予超级管理员角色
+func changeUserRoles(c *gin.Context, grantRoleIDs []uint, change func(tx *gorm.DB, user *models.User) error) {
+	var user models.User
+	if err := scoped(c, services.DataResourceUser).First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(404, gin.H{"error": "用户不存在"})
+		return
+	}
+
+	if err := services.GuardSuperuserGrant(tenantDB(c), currentSubject(c), grantRoleIDs, false); err != nil {
+		if !respondGuardError(c, err) {
+			c.JSON(500, gin.H{"error": "修改用户角色失败"})
+		}
+		return
+	}
+	roleIDs, err := services.UserRoleIDs(tenantDB(c), user.ID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "修改用户角色失败"})
+		return
+	}
+	resource := userAttributes(user, roleIDs)
+	resource["role_changed"] = true
+	if !authorizeResource(c, "user:update", resource) {
+		return
+	}
+
+	err = tenantDB(c).Transaction(func(tx *gorm.DB) error {
+		// 锁定后重新加载用户，变更前的主角色以锁定时为准
+		var locked models.User
+		if err := lockForAudit(tx, &locked, user.ID); err != nil {
+			return err
+		}
+		before := auditUserRoles(tx, locked)
+		if err := change(tx, &user); err != nil {
+			return err
+		}
+		if err := services.EnsureSuperuserRemains(tx); err != nil {
+			return err
+		}
+		if err := tx.Preload("Role").Preload("Department").Preload("Roles").First(&user, user.ID).Error; err != nil {
+			return err
+		}
+		return recordAudit(c, tx, services.AuditUserRolesChanged, services.AuditTargetUser, user.ID, before, auditUserRoles(tx, user))
+	})
+	if respondGuardError(c, err) {
+		return
+	}
+	if errors.Is(err, services.ErrRoleNotFound) || errors.Is(err, services.ErrInvalidGrantWindow) {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("修改用户角色失败: %v", err)
+		c.JSON(500, gin.H{"error": "修改用户角色失败"})
+		return
+	}
+	services.InvalidateUserPermissions(user.ID)
+	c.JSON(200, dto.NewUser(user))
+}
+
+// auditUser 加载用户的响应结构，用于记录审计事件的变更前后状态，变更后的状态需要在同一事务中加载
+func auditUser(db *gorm.DB, id uint) any {
+	var user models.User
+	if err := db.Preload("Role").Preload("Department").Preload("Roles").First(&user, id).Error; err != nil {
+		return nil
+	}
+	return dto.NewUser(user)
+}
+
+// auditUserRoles 用户的主角色、全部角色和临时角色的有效期，用于记录角色变更
+func auditUserRoles(db *gorm.DB, user models.User) any {
+	roleIDs, err := services.UserRoleIDs(db, user.ID)
+	if err != nil {
+		return nil
+	}
+	windows, err := services.UserRoleWindows(db, user.ID)
+	if err != nil {
+		return nil
+	}
+	return gin.H{"role_id": user.RoleID, "role_ids": roleIDs, "windows": windows}
+}
+
+// authorizeUser 加载用户的角色后按资源属性再次评估策略，返回 false 时已返回错误
+func authorizeUser(c *gin.Context, permission string, user models.User) bool {
+	roleIDs, err := services.UserRoleIDs(tenantDB(c), user.ID)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "权限检查失败"})
+		return false
+	}
+	return authorizeResource(c, permission, userAttributes(user, roleIDs))
+}
+
+// userAttributes 用户的策略属性
+func userAttributes(user models.User, roleIDs []uint) services.Attributes {
+	return services.Attributes{
+		"type":          "user",
+		"id":            user.ID,
+		"username":      user.Username,
+		"status":        user.Status,
+		"department_id": user.DepartmentID,
+		"role_ids":      roleIDs,
+		"is_superuser":  user.IsSuperuser,
+		"role_changed":  false,
+	}
+}
+
+// sameIDs 判断两组ID去重后是否相同，0 忽略
+func sameIDs(a, b []uint) bool {
+	set := make(map[uint]bool)
+	for _, id := range a {
+		if id != 0 {
+			set[id] = true
+		}
+	}
+	seen := make(map[uint]bool)
+	for _, id := range b {
+		if id == 0 {
+			continue
+		}
+		if !set[id] {
+			return false
+		}
+		seen[id] = true
+	}
+	return len(seen) == len(set)
+}
+
+func containsID(ids []uint, id uint) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// UnlockUser 解除用户的登录锁定
+func UnlockUser(c *gin.Context) {
+	id := c.Param("id")
+
+	var user models.User
+	if err := scoped(c, services.DataResourceUser).First(&user, id).Error; err != nil {
+		c.JSON(404, gin.H{"error": "用户不存在"})
+		return
+	}
+	if !authorizeUser(c, "user:update", user) {
+		return
+	}
+
+	// 锁定状态不在数据库中，无法与审计记录放在同一事务，先写入审计记录，失败时不解锁
+	if err := recordAudit(c, tenantDB(c), services.AuditUserUnlocked, services.AuditTargetUser, user.ID, nil, nil); err != nil {
+		c.JSON(500, gin.H{"error": "解除锁定失败"})
+		return
+	}
+	services.UnlockLogin(user.Username, c.GetString("username"), c.ClientIP())
+
+	c.JSON(200, gin.H{"message": "用户已解除锁定"})
+}
+
 // GetUserInfo 获取当前登录用户信息
 func GetUserInfo(c *gin.Context) {
 	username := c.GetString("username")
@@ -203,27 +775,15 @@ func GetUserInfo(c *gin.Context) {
 	}
 
 	var user models.User
-	if err := config.DB.Preload("Role.Permissions").Where("username = ?", username).First(&user).Error; err != nil {
+	if err := tenantDB(c).Preload("Role").Preload("Department").Preload("Roles.Permissions").Where("username = ?", username).First(&user).Error; err != nil {
 		c.JSON(404, gin.H{"error": "用户不存在"})
 		return
 	}
 
 	// 获取用户权限列表
-	var permissions []string
-	for _, perm := range user.Role.Permissions {
-		permissions = append(permissions, perm.Code)
-	}
+	permissions := userPermissions(user)
 
-	c.JSON(200, gin.H{
-		"id":          user.ID,
-		"username":    user.Username,
-		"role_id":     user.RoleID,
-		"role_name":   user.Role.Name,
-		"permissions": permissions,
-		"status":      user.Status,
-		"created_at":  user.CreatedAt,
-		"updated_at":  user.UpdatedAt,
-	})
+	c.JSON(200, dto.NewUserWithPermissions(user, permissions))
 }
 
 // GetUserDetail 获取指定用户详细信息
@@ -231,27 +791,15 @@ func GetUserDetail(c *gin.Context) {
 	id := c.Param("id")
 	
 	var user models.User
-	if err := config.DB.Preload("Role.Permissions").First(&user, id).Error; err != nil {
+	if err := scoped(c, services.DataResourceUser).Preload("Role").Preload("Department").Preload("Roles.Permissions").First(&user, id).Error; err != nil {
 		c.JSON(404, gin.H{"error": "用户不存在"})
 		return
 	}
 
 	// 获取用户权限列表
-	var permissions []string
-	for _, perm := range user.Role.Permissions {
-		permissions = append(permissions, perm.Code)
-	}
+	permissions := userPermissions(user)
 
-	c.JSON(200, gin.H{
-		"id":          user.ID,
-		"username":    user.Username,
-		"role_id":     user.RoleID,
-		"role_name":   user.Role.Name,
-		"permissions": permissions,
-		"status":      user.Status,
-		"created_at":  user.CreatedAt,
-		"updated_at":  user.UpdatedAt,
-	})
+	c.JSON(200, dto.NewUserWithPermissions(user, permissions))
 }
 
 // GetUserList 获取用户列表
@@ -266,7 +814,17 @@ func GetUserList(c *gin.Context) {
 	offset := (pageNum - 1) * limit
 
 	var users []models.User
-	query := config.DB.Preload("Role")
+	query := scoped(c, services.DataResourceUser).Preload("Role").Preload("Department").Preload("Roles")
+
+	// 按部门筛选，默认包含下级部门
+	departmentIDs, ok, err := departmentFilter(c)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "获取用户列表失败"})
+		return
+	}
+	if ok {
+		query = query.Where("department_id IN ?", departmentIDs)
+	}
 
 	// 获取总数
 	var total int64
@@ -278,22 +836,10 @@ func GetUserList(c *gin.Context) {
 		return
 	}
 
-	// 构造响应数据
-	var responseUsers []gin.H
-	for _, user := range users {
-			responseUsers = append(responseUsers, gin.H{
-				"id":       user.ID,
-				"username": user.Username,
-				"role_id":  user.RoleID,
-				"role":     user.Role,
-				"status":   user.Status,
-			})
-	}
-
-	c.JSON(200, gin.H{
-		"total": total,
-		"page": pageNum,
-		"page_size": limit,
-		"data": responseUsers,
+	c.JSON(200, dto.Page{
+		Total:    total,
+		Page:     pageNum,
+		PageSize: limit,
+		Data:     dto.NewUsers(users),
 	})
 } 
\ No newline at end of file
@@ -0,0 +1,48 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+
+	"useradmin/api/models"
+	"useradmin/api/services"
+)
+
+// AuditEvent 审计事件
+type AuditEvent struct {
+	ID         uint                   `json:"id"`
+	RequestID  string                 `json:"request_id"`
+	Type       string                 `json:"type"`
+	ActorID    uint                   `json:"actor_id"`
+	ActorName  string                 `json:"actor_name"`
+	TargetType string                 `json:"target_type"`
+	TargetID   string                 `json:"target_id"`
+	Changes    []services.FieldChange `json:"changes"`
+	IP         string                 `json:"ip"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+// NewAuditEvent 转换审计事件，变更无法解析时返回空列表
+func NewAuditEvent(e models.AuditEvent) AuditEvent {
+	changes := []services.FieldChange{}
+	if e.Changes != "" {
+		_ = json.Unmarshal([]byte(e.Changes), &changes)
+	}
+	return AuditEvent{
+		ID:         e.ID,
+		RequestID:  e.RequestID,
+		Type:       e.Type,
+		ActorID:    e.ActorID,
+		ActorName:  e.ActorName,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		Changes:    changes,
+		IP:         e.IP,
+		CreatedAt:  e.CreatedAt,
+	}
+}
+
+// NewAuditEvents 转换审计事件列表
+func NewAuditEvents(events []models.AuditEvent) []AuditEvent {
+	return mapSlice(events, NewAuditEvent)
+}
@@ -0,0 +1,110 @@
+package dto
+
+import (
+	"useradmin/api/services"
+)
+
+// GrantSource 授予权限的角色，继承时 From 为实际授予该权限的祖先角色
+type GrantSource struct {
+	Role      RoleSummary   `json:"role"`
+	Inherited bool          `json:"inherited"`
+	From      []RoleSummary `json:"from"`
+}
+
+func newGrantSources(sources []services.GrantSource) []GrantSource {
+	return mapSlice(sources, func(s services.GrantSource) GrantSource {
+		return GrantSource{Role: NewRoleSummary(s.Role), Inherited: s.Inherited, From: NewRoleSummaries(s.From)}
+	})
+}
+
+// AuthzReason 授权判断过程中的一步
+type AuthzReason struct {
+	Type     string        `json:"type"`
+	Message  string        `json:"message"`
+	Grant    string        `json:"grant,omitempty"`
+	Wildcard bool          `json:"wildcard,omitempty"`
+	Sources  []GrantSource `json:"sources,omitempty"`
+	Policy   *PolicyMatch  `json:"policy,omitempty"`
+}
+
+// AuthzDecision 授权判断的结果、原因链和策略评估过程
+type AuthzDecision struct {
+	Allowed   bool          `json:"allowed"`
+	Superuser bool          `json:"superuser"`
+	Granted   bool          `json:"granted"`
+	Reasons   []AuthzReason `json:"reasons"`
+	Effect    string        `json:"effect"`
+	Decisive  *PolicyMatch  `json:"decisive"`
+	Policies  []PolicyMatch `json:"policies"`
+}
+
+// NewAuthzDecision 转换授权结果
+func NewAuthzDecision(d *services.AuthzDecision) AuthzDecision {
+	out := AuthzDecision{
+		Allowed:   d.Allowed,
+		Superuser: d.Superuser,
+		Granted:   d.Granted,
+		Effect:    d.Policy.Effect,
+		Policies:  mapSlice(d.Policy.Policies, newPolicyMatch),
+		Reasons: mapSlice(d.Reasons, func(r services.AuthzReason) AuthzReason {
+			reason := AuthzReason{
+				Type:     r.Type,
+				Message:  r.Message,
+				Grant:    r.Grant,
+				Wildcard: r.Wildcard,
+				Sources:  newGrantSources(r.Sources),
+			}
+			if r.Policy != nil {
+				m := newPolicyMatch(*r.Policy)
+				reason.Policy = &m
+			}
+			return reason
+		}),
+	}
+	if d.Policy.Decisive != nil {
+		m := newPolicyMatch(*d.Policy.Decisive)
+		out.Decisive = &m
+	}
+	return out
+}
+
+// PermissionGrant 用户获得的一个权限代码及其全部来源
+type PermissionGrant struct {
+	Permission Permission    `json:"permission"`
+	Wildcard   bool          `json:"wildcard"`
+	Sources    []GrantSource `json:"sources"`
+}
+
+// EffectivePermissions 用户的有效权限及其来源
+type EffectivePermissions struct {
+	UserID         uint              `json:"user_id"`
+	Username       string            `json:"username"`
+	Status         int               `json:"status"`
+	TenantActive   bool              `json:"tenant_active"`
+	Superuser      bool              `json:"superuser"`
+	SuperuserFlag  bool              `json:"superuser_flag"`  // 用户本身带有超级管理员标记
+	SuperuserRoles []RoleSummary     `json:"superuser_roles"` // 带有超级管理员标记的角色
+	Roles          []RoleSummary     `json:"roles"`
+	InheritedRoles []RoleSummary     `json:"inherited_roles"`
+	Grants         []PermissionGrant `json:"grants"`
+	Permissions    []string          `json:"permissions"` // 展开通配符后的具体权限代码
+}
+
+// NewEffectivePermissions 转换用户有效权限
+func NewEffectivePermissions(e *services.EffectivePermissions) EffectivePermissions {
+	return EffectivePermissions{
+		UserID:         e.Subject.UserID,
+		Username:       e.Subject.Username,
+		Status:         e.Subject.Status,
+		TenantActive:   e.Subject.TenantActive,
+		Superuser:      e.Subject.Superuser,
+		SuperuserFlag:  e.SuperuserFlag,
+		SuperuserRoles: NewRoleSummaries(e.SuperuserRoles),
+		Roles:          NewRoleSummaries(e.Roles),
+		InheritedRoles: NewRoleSummaries(e.InheritedRoles),
+		Grants: mapSlice(e.Grants, func(g services.PermissionGrant) PermissionGrant {
+			return PermissionGrant{Permission: NewPermission(g.Permission), Wildcard: g.Wildcard, Sources: newGrantSources(g.Sources)}
+		}),
+		Permissions: e.Permissions,
+	}
+}
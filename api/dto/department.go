@@ -0,0 +1,84 @@
+package dto
+
+import (
+	"sort"
+	"time"
+
+	"useradmin/api/models"
+)
+
+// DepartmentSummary 嵌套在其它资源中的部门摘要
+type DepartmentSummary struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// Department 部门信息
+type Department struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	ParentID  uint      `json:"parent_id"`
+	Path      string    `json:"path"`
+	Sort      int       `json:"sort"`
+	Status    int       `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NewDepartment 转换部门
+func NewDepartment(d models.Department) Department {
+	return Department{
+		ID:        d.ID,
+		Name:      d.Name,
+		ParentID:  d.ParentID,
+		Path:      d.Path,
+		Sort:      d.Sort,
+		Status:    d.Status,
+		CreatedAt: d.CreatedAt,
+		UpdatedAt: d.UpdatedAt,
+	}
+}
+
+// NewDepartments 转换部门列表
+func NewDepartments(departments []models.Department) []Department {
+	return mapSlice(departments, NewDepartment)
+}
+
+// DepartmentNode 部门树节点
+type DepartmentNode struct {
+	Department
+	Children []*DepartmentNode `json:"children"`
+}
+
+// NewDepartmentTree 将部门列表组装为树，上级部门不在列表中的部门作为根节点
+func NewDepartmentTree(departments []models.Department) []*DepartmentNode {
+	nodes := make(map[uint]*DepartmentNode, len(departments))
+	for _, d := range departments {
+		nodes[d.ID] = &DepartmentNode{Department: NewDepartment(d), Children: []*DepartmentNode{}}
+	}
+
+	roots := []*DepartmentNode{}
+	for _, d := range departments {
+		node := nodes[d.ID]
+		if parent, ok := nodes[d.ParentID]; ok {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+
+	var sortNodes func([]*DepartmentNode)
+	sortNodes = func(list []*DepartmentNode) {
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].Sort != list[j].Sort {
+				return list[i].Sort < list[j].Sort
+			}
+			return list[i].ID < list[j].ID
+		})
+		for _, n := range list {
+			sortNodes(n.Children)
+		}
+	}
+	sortNodes(roots)
+	return roots
+}
@@ -0,0 +1,43 @@
+// Package dto 定义接口返回给客户端的数据结构。
+// 所有接口都通过这里的结构体输出，不直接序列化 models，避免密码哈希等内部字段泄露。
+// 结构体的字段即接口契约，修改字段需要同时提升 Version。
+package dto
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Version 当前响应结构的版本，通过 X-API-Version 响应头返回
+const Version = "1"
+
+// VersionHeader 在响应头中声明响应结构的版本
+func VersionHeader() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("X-API-Version", Version)
+		c.Next()
+	}
+}
+
+// Page 分页列表
+type Page struct {
+	Total    int64       `json:"total"`
+	Page     int         `json:"page"`
+	PageSize int         `json:"page_size"`
+	Data     interface{} `json:"data"`
+}
+
+// CursorPage 按游标翻页的列表，NextCursor 为空表示没有下一页
+type CursorPage struct {
+	PageSize   int         `json:"page_size"`
+	NextCursor string      `json:"next_cursor"`
+	Data       interface{} `json:"data"`
+}
+
+// mapSlice 将模型列表转换为响应结构列表，空列表返回 [] 而不是 null
+func mapSlice[M any, D any](items []M, fn func(M) D) []D {
+	result := make([]D, 0, len(items))
+	for _, item := range items {
+		result = append(result, fn(item))
+	}
+	return result
+}
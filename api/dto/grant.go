@@ -0,0 +1,45 @@
+package dto
+
+import (
+	"time"
+
+	"useradmin/api/services"
+)
+
+// GrantUser 临时角色所属的用户
+type GrantUser struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+}
+
+// TimedGrant 即将到期的临时授权，kind 为 user_role 时包含 user，为 role_permission 时包含 permission
+type TimedGrant struct {
+	Kind       string      `json:"kind"`
+	TenantID   uint        `json:"tenant_id"`
+	User       *GrantUser  `json:"user,omitempty"`
+	Role       RoleSummary `json:"role"`
+	Permission *Permission `json:"permission,omitempty"`
+	ValidFrom  *time.Time  `json:"valid_from"`
+	ValidUntil time.Time   `json:"valid_until"`
+}
+
+// NewTimedGrants 转换临时授权列表
+func NewTimedGrants(grants []services.TimedGrant) []TimedGrant {
+	return mapSlice(grants, func(g services.TimedGrant) TimedGrant {
+		out := TimedGrant{
+			Kind:       g.Kind,
+			TenantID:   g.TenantID,
+			Role:       NewRoleSummary(g.Role),
+			ValidFrom:  g.ValidFrom,
+			ValidUntil: g.ValidUntil,
+		}
+		if g.User != nil {
+			out.User = &GrantUser{ID: g.User.ID, Username: g.User.Username}
+		}
+		if g.Permission != nil {
+			p := NewPermission(*g.Permission)
+			out.Permission = &p
+		}
+		return out
+	})
+}
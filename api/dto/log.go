@@ -0,0 +1,44 @@
+package dto
+
+import (
+	"time"
+
+	"useradmin/api/models"
+)
+
+// Log 操作日志
+type Log struct {
+	ID        uint      `json:"id"`
+	RequestID string    `json:"request_id"`
+	Username  string    `json:"username"`
+	Action    string    `json:"action"`
+	Resource  string    `json:"resource"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Status    int       `json:"status"`
+	Request   string    `json:"request"`
+	Response  string    `json:"response"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewLog 转换日志
+func NewLog(l models.Log) Log {
+	return Log{
+		ID:        l.ID,
+		RequestID: l.RequestID,
+		Username:  l.Username,
+		Action:    l.Action,
+		Resource:  l.Resource,
+		IP:        l.IP,
+		UserAgent: l.UserAgent,
+		Status:    l.Status,
+		Request:   l.Request,
+		Response:  l.Response,
+		CreatedAt: l.CreatedAt,
+	}
+}
+
+// NewLogs 转换日志列表
+func NewLogs(logs []models.Log) []Log {
+	return mapSlice(logs, NewLog)
+}
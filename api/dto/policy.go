@@ -0,0 +1,77 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+
+	"useradmin/api/models"
+	"useradmin/api/services"
+)
+
+// Policy 授权策略
+type Policy struct {
+	ID          uint                       `json:"id"`
+	Name        string                     `json:"name"`
+	Description string                     `json:"description"`
+	Effect      string                     `json:"effect"`
+	Permission  string                     `json:"permission"`
+	Conditions  []services.PolicyCondition `json:"conditions"`
+	Enabled     bool                       `json:"enabled"`
+	CreatedAt   time.Time                  `json:"created_at"`
+	UpdatedAt   time.Time                  `json:"updated_at"`
+}
+
+// NewPolicy 转换策略，条件无法解析时返回空列表
+func NewPolicy(p models.Policy) Policy {
+	conditions := []services.PolicyCondition{}
+	if p.Conditions != "" {
+		_ = json.Unmarshal([]byte(p.Conditions), &conditions)
+	}
+	return Policy{
+		ID:          p.ID,
+		Name:        p.Name,
+		Description: p.Description,
+		Effect:      p.Effect,
+		Permission:  p.Permission,
+		Conditions:  conditions,
+		Enabled:     p.Enabled,
+		CreatedAt:   p.CreatedAt,
+		UpdatedAt:   p.UpdatedAt,
+	}
+}
+
+// NewPolicies 转换策略列表
+func NewPolicies(policies []models.Policy) []Policy {
+	return mapSlice(policies, NewPolicy)
+}
+
+// ConditionResult 条件的评估结果
+type ConditionResult struct {
+	services.PolicyCondition
+	Actual  any  `json:"actual"`
+	Found   bool `json:"found"`
+	Matched bool `json:"matched"`
+}
+
+// PolicyMatch 策略的评估结果，ID 为 0 表示试运行的草稿策略
+type PolicyMatch struct {
+	ID         uint              `json:"id"`
+	Name       string            `json:"name"`
+	Effect     string            `json:"effect"`
+	Permission string            `json:"permission"`
+	Matched    bool              `json:"matched"`
+	Conditions []ConditionResult `json:"conditions"`
+}
+
+func newPolicyMatch(m services.PolicyMatch) PolicyMatch {
+	return PolicyMatch{
+		ID:         m.ID,
+		Name:       m.Name,
+		Effect:     m.Effect,
+		Permission: m.Permission,
+		Matched:    m.Matched,
+		Conditions: mapSlice(m.Conditions, func(c services.ConditionResult) ConditionResult {
+			return ConditionResult{PolicyCondition: c.PolicyCondition, Actual: c.Actual, Found: c.Found, Matched: c.Matched}
+		}),
+	}
+}
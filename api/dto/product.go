@@ -0,0 +1,61 @@
+package dto
+
+import (
+	"time"
+
+	"useradmin/api/models"
+)
+
+// Product 商品信息
+type Product struct {
+	ID          uint           `json:"id"`
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	Images      []ProductImage `json:"images"`
+	Specs       []ProductSpec  `json:"specs"`
+	Status      int            `json:"status"`
+	CreatedBy   uint           `json:"created_by"`
+	UpdatedBy   uint           `json:"updated_by"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// ProductImage 商品图片
+type ProductImage struct {
+	ID   uint   `json:"id"`
+	URL  string `json:"url"`
+	Sort int    `json:"sort"`
+}
+
+// ProductSpec 商品规格
+type ProductSpec struct {
+	ID    uint   `json:"id"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Sort  int    `json:"sort"`
+}
+
+// NewProduct 转换商品
+func NewProduct(p models.Product) Product {
+	return Product{
+		ID:          p.ID,
+		Title:       p.Title,
+		Description: p.Description,
+		Images: mapSlice(p.Images, func(i models.ProductImage) ProductImage {
+			return ProductImage{ID: i.ID, URL: i.URL, Sort: i.Sort}
+		}),
+		Specs: mapSlice(p.Specs, func(s models.ProductSpec) ProductSpec {
+			return ProductSpec{ID: s.ID, Name: s.Name, Value: s.Value, Sort: s.Sort}
+		}),
+		Status:    p.Status,
+		CreatedBy: p.CreatedBy,
+		UpdatedBy: p.UpdatedBy,
+		CreatedAt: p.CreatedAt,
+		UpdatedAt: p.UpdatedAt,
+	}
+}
+
+// NewProducts 转换商品列表
+func NewProducts(products []models.Product) []Product {
+	return mapSlice(products, NewProduct)
+}
@@ -0,0 +1,149 @@
+package dto
+
+import (
+	"time"
+
+	"useradmin/api/models"
+	"useradmin/api/services"
+)
+
+// RoleSummary 嵌套在其它资源中的角色摘要
+type RoleSummary struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+// Role 角色信息
+type Role struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	RequireMFA  bool   `json:"require_mfa"`
+	IsSystem    bool   `json:"is_system"`
+	IsSuperuser bool   `json:"is_superuser"`
+	DataScope   string `json:"data_scope"`
+	// 自定义数据范围的部门，需要预加载 DataDepartments
+	DataDepartmentIDs []uint        `json:"data_department_ids"`
+	Permissions       []Permission  `json:"permissions"`
+	Parents           []RoleSummary `json:"parents"` // 直接继承的父角色
+	CreatedAt         time.Time     `json:"created_at"`
+	UpdatedAt         time.Time     `json:"updated_at"`
+}
+
+// NewRole 转换角色，Permissions、Parents 未预加载时为空列表
+func NewRole(r models.Role) Role {
+	return Role{
+		ID:          r.ID,
+		Name:        r.Name,
+		Description: r.Description,
+		RequireMFA:  r.RequireMFA,
+		IsSystem:    r.IsSystem,
+		IsSuperuser: r.IsSuperuser,
+		DataScope:   r.DataScope,
+		DataDepartmentIDs: mapSlice(r.DataDepartments, func(d models.RoleDataDepartment) uint {
+			return d.DepartmentID
+		}),
+		Permissions: NewPermissions(r.Permissions),
+		Parents:     NewRoleSummaries(r.Parents),
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+}
+
+// NewRoles 转换角色列表
+func NewRoles(roles []models.Role) []Role {
+	return mapSlice(roles, NewRole)
+}
+
+// NewRoleSummary 转换角色摘要
+func NewRoleSummary(r models.Role) RoleSummary {
+	return RoleSummary{ID: r.ID, Name: r.Name}
+}
+
+// NewRoleSummaries 转换角色摘要列表
+func NewRoleSummaries(roles []models.Role) []RoleSummary {
+	return mapSlice(roles, NewRoleSummary)
+}
+
+// InheritedPermission 继承的权限及其来源角色
+type InheritedPermission struct {
+	Permission
+	From []RoleSummary `json:"from"`
+}
+
+// RolePermissionSet 角色的直接授予权限与继承权限
+type RolePermissionSet struct {
+	Role      RoleSummary           `json:"role"`
+	Parents   []RoleSummary         `json:"parents"`
+	Ancestors []RoleSummary         `json:"ancestors"`
+	Direct    []Permission          `json:"direct"`
+	Inherited []InheritedPermission `json:"inherited"`
+}
+
+// NewRolePermissionSet 转换角色有效权限
+func NewRolePermissionSet(set *services.RolePermissionSet) RolePermissionSet {
+	return RolePermissionSet{
+		Role:      NewRoleSummary(set.Role),
+		Parents:   NewRoleSummaries(set.Role.Parents),
+		Ancestors: NewRoleSummaries(set.Ancestors),
+		Direct:    NewPermissions(set.Direct),
+		Inherited: mapSlice(set.Inherited, func(p services.InheritedPermission) InheritedPermission {
+			return InheritedPermission{Permission: NewPermission(p.Permission), From: NewRoleSummaries(p.From)}
+		}),
+	}
+}
+
+// Permission 权限信息
+type Permission struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Code        string    `json:"code"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// NewPermission 转换权限
+func NewPermission(p models.Permission) Permission {
+	return Permission{
+		ID:          p.ID,
+		Name:        p.Name,
+		Description: p.Description,
+		Code:        p.Code,
+		CreatedAt:   p.CreatedAt,
+		UpdatedAt:   p.UpdatedAt,
+	}
+}
+
+// NewPermissions 转换权限列表
+func NewPermissions(permissions []models.Permission) []Permission {
+	return mapSlice(permissions, NewPermission)
+}
+
+// PermissionDefinition 内置权限定义
+type PermissionDefinition struct {
+	Code        string `json:"code"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// NewPermissionDefinition 转换内置权限定义
+func NewPermissionDefinition(def services.PermissionDefinition) PermissionDefinition {
+	return PermissionDefinition{Code: def.Code, Name: def.Name, Description: def.Description}
+}
+
+// RoutePermission 接口所需的权限
+type RoutePermission struct {
+	Method         string `json:"method"`
+	Path           string `json:"path"`
+	Permission     string `json:"permission"`
+	PermissionName string `json:"permission_name"`
+}
+
+// NewRoutePermissions 转换接口权限列表，附带权限名称
+func NewRoutePermissions(routes []services.RoutePermission) []RoutePermission {
+	return mapSlice(routes, func(r services.RoutePermission) RoutePermission {
+		def, _ := services.LookupPermissionDefinition(r.Permission)
+		return RoutePermission{Method: r.Method, Path: r.Path, Permission: r.Permission, PermissionName: def.Name}
+	})
+}
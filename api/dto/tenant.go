@@ -0,0 +1,36 @@
+package dto
+
+import (
+	"time"
+
+	"useradmin/api/models"
+)
+
+// Tenant 租户信息
+type Tenant struct {
+	ID         uint      `json:"id"`
+	Code       string    `json:"code"`
+	Name       string    `json:"name"`
+	Status     int       `json:"status"`
+	IsPlatform bool      `json:"is_platform"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// NewTenant 转换租户
+func NewTenant(t models.Tenant) Tenant {
+	return Tenant{
+		ID:         t.ID,
+		Code:       t.Code,
+		Name:       t.Name,
+		Status:     t.Status,
+		IsPlatform: t.IsPlatform,
+		CreatedAt:  t.CreatedAt,
+		UpdatedAt:  t.UpdatedAt,
+	}
+}
+
+// NewTenants 转换租户列表
+func NewTenants(tenants []models.Tenant) []Tenant {
+	return mapSlice(tenants, NewTenant)
+}
@@ -0,0 +1,75 @@
+package dto
+
+import (
+	"time"
+
+	"useradmin/api/models"
+)
+
+// User 用户信息，不包含密码哈希和两步验证密钥
+type User struct {
+	ID                 uint               `json:"id"`
+	TenantID           uint               `json:"tenant_id"`
+	Username           string             `json:"username"`
+	RoleID             uint               `json:"role_id"`
+	RoleName           string             `json:"role_name"`
+	Role               *RoleSummary       `json:"role,omitempty"`
+	RoleIDs            []uint             `json:"role_ids"`
+	Roles              []RoleSummary      `json:"roles"`
+	Permissions        []string           `json:"permissions,omitempty"`
+	Status             int                `json:"status"`
+	TOTPEnabled        bool               `json:"totp_enabled"`
+	IsSystem           bool               `json:"is_system"`
+	IsSuperuser        bool               `json:"is_superuser"`
+	DepartmentID       uint               `json:"department_id"`
+	Department         *DepartmentSummary `json:"department"` // 需要预加载 Department
+	MustChangePassword bool               `json:"must_change_password"`
+	PasswordChangedAt  *time.Time         `json:"password_changed_at"`
+	CreatedAt          time.Time          `json:"created_at"`
+	UpdatedAt          time.Time          `json:"updated_at"`
+}
+
+// NewUser 转换用户，预加载了 Role 时附带角色摘要
+func NewUser(u models.User) User {
+	user := User{
+		ID:                 u.ID,
+		TenantID:           u.TenantID,
+		Username:           u.Username,
+		RoleID:             u.RoleID,
+		RoleName:           u.Role.Name,
+		Status:             u.Status,
+		TOTPEnabled:        u.TOTPEnabled,
+		IsSystem:           u.IsSystem,
+		IsSuperuser:        u.IsSuperuser,
+		DepartmentID:       u.DepartmentID,
+		MustChangePassword: u.MustChangePassword,
+		PasswordChangedAt:  u.PasswordChangedAt,
+		CreatedAt:          u.CreatedAt,
+		UpdatedAt:          u.UpdatedAt,
+	}
+	if u.Department != nil {
+		user.Department = &DepartmentSummary{ID: u.Department.ID, Name: u.Department.Name}
+	}
+	if u.Role.ID != 0 {
+		role := NewRoleSummary(u.Role)
+		user.Role = &role
+	}
+	user.Roles = NewRoleSummaries(u.Roles)
+	user.RoleIDs = mapSlice(u.Roles, func(r models.Role) uint { return r.ID })
+	return user
+}
+
+// NewUserWithPermissions 转换用户并附带权限代码列表
+func NewUserWithPermissions(u models.User, permissions []string) User {
+	user := NewUser(u)
+	if permissions == nil {
+		permissions = []string{}
+	}
+	user.Permissions = permissions
+	return user
+}
+
+// NewUsers 转换用户列表
+func NewUsers(users []models.User) []User {
+	return mapSlice(users, NewUser)
+}
@@ -1,15 +1,24 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-contrib/cors"
 	"gorm.io/gorm"
 	"gorm.io/driver/mysql"
 	"useradmin/api/config"
+	"useradmin/api/dto"
 	"useradmin/api/models"
 	"useradmin/api/middleware"
 	"useradmin/api/routes"
+	"useradmin/api/services"
 )
 
 func main() {
@@ -28,12 +37,9 @@ func main() {
 	// 初始化全局数据库连接
 	config.InitDB(db)
 
-	// 自动迁移数据库结构
-	db.AutoMigrate(&models.User{}, &models.Role{}, &models.Permission{}, &models.Log{}, &models.Product{}, &models.ProductImage{}, &models.ProductSpec{})
-
-	// 初始化基础数据
-	if err := models.InitData(db); err != nil {
-		log.Fatal("初始化数据失败:", err)
+	// 注册租户隔离回调
+	if err := services.RegisterTenantScope(db); err != nil {
+		log.Fatal("注册租户隔离失败:", err)
 	}
 
 	// 初始化 Gin
@@ -44,7 +50,7 @@ func main() {
 		AllowOrigins:     []string{"*"},                                          // 允许所有域名
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With"},
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", middleware.RequestIDHeader},
 		AllowCredentials: false,  // 当 AllowOrigins 为 * 时，必须设置为 false
 		MaxAge:           12 * 60 * 60, // 预检请求结果缓存12小时
 	}))
@@ -52,17 +58,78 @@ func main() {
 	// 配置静态文件服务
 	r.Static("/uploads", "./uploads")
 
-	// 添加日志中间件
+	// 分配请求ID，关联访问日志和审计事件
+	r.Use(middleware.RequestID())
+
+	// 添加日志中间件，日志由后台按批写入配置的输出（数据库、文件、syslog）
+	if err := middleware.StartLogWriter(cfg.Log); err != nil {
+		log.Fatal("初始化日志输出失败:", err)
+	}
 	r.Use(middleware.Logger())
 
-	// API 路由组
+	// 声明响应结构版本
+	r.Use(dto.VersionHeader())
+
+	// API 路由组，接口声明的权限必须已在权限注册表中定义
 	api := r.Group("/api")
-	{
-		// 设置路由
-		routes.SetupRoutes(api)
+	if err := routes.SetupRoutes(api); err != nil {
+		log.Fatal("注册路由失败:", err)
+	}
+
+	// 自动迁移数据库结构，日志表单独迁移
+	if err := db.AutoMigrate(&models.Tenant{}, &models.User{}, &models.Role{}, &models.Permission{}, &models.Product{}, &models.ProductImage{}, &models.ProductSpec{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.RecoveryCode{}, &models.PasswordHistory{}, &models.UserRole{}, &models.RoleParent{}, &models.RoleDataDepartment{}, &models.Department{}, &models.Policy{}, &models.AuditEvent{}); err != nil {
+		log.Fatal("数据库迁移失败:", err)
+	}
+	if err := models.MigrateLogs(db); err != nil {
+		log.Fatal("日志表迁移失败:", err)
+	}
+
+	// 写入路由声明的内置权限
+	if err := services.SeedPermissions(db); err != nil {
+		log.Fatal("初始化权限失败:", err)
+	}
+
+	// 初始化基础数据
+	if err := models.InitData(db); err != nil {
+		log.Fatal("初始化数据失败:", err)
 	}
 
+	// 加载已吊销的token
+	if err := middleware.LoadRevokedTokens(); err != nil {
+		log.Fatal("加载token吊销列表失败:", err)
+	}
+
+	// 收到 SIGINT、SIGTERM 时停止后台任务并优雅退出
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 定期清理到期的临时授权
+	services.StartGrantExpiry(ctx, time.Duration(cfg.Security.GrantExpiryInterval)*time.Second)
+
+	// 定期归档并清理过期的请求日志
+	services.StartLogRetention(ctx)
+
 	// 启动服务器
-	log.Println("服务器启动在 :8080 端口")
-	r.Run(":8080")
+	srv := &http.Server{Addr: cfg.Server.Addr(), Handler: r}
+	go func() {
+		log.Printf("服务器启动在 %s 端口", cfg.Server.Addr())
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("服务器启动失败:", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Println("正在关闭服务器...")
+
+	// 先等待进行中的请求完成，再写入队列中剩余的日志
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Server.ShutdownTimeout)*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("关闭服务器失败: %v", err)
+	}
+	if err := middleware.StopLogWriter(shutdownCtx); err != nil {
+		log.Printf("写入剩余日志失败: %v", err)
+	}
+	log.Println("服务器已关闭")
 } 
\ No newline at end of file
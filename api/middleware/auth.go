@@ -1,9 +1,10 @@
 package middleware
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
-	"useradmin/api/config"
-	"useradmin/api/models"
+	"useradmin/api/services"
 )
 
 
@@ -18,42 +19,65 @@ func CheckPermission(requiredPermission string) gin.HandlerFunc {
 			return
 		}
 
-		// 查询用户及其权限
-		var user models.User
-		if err := config.DB.Preload("Role.Permissions").Where("username = ?", username).First(&user).Error; err != nil {
+		// 查询用户及其权限（带缓存）
+		user, err := services.LoadSubject(username.(string))
+		if err != nil {
 			c.JSON(401, gin.H{"error": "用户不存在"})
 			c.Abort()
 			return
 		}
 
-		// 检查用户状态
-		if user.Status != 1 {
-			c.JSON(403, gin.H{"error": "用户已被禁用"})
+		// token 中的租户必须与用户所属租户一致
+		if user.TenantID != c.GetUint("tenant_id") {
+			c.JSON(401, gin.H{"error": "token无效"})
 			c.Abort()
 			return
 		}
 
-		// 超级管理员角色（ID=1）拥有所有权限
-		if user.RoleID == 1 {
-			c.Next()
+		// 检查租户、用户状态、角色权限和策略，超级管理员拥有所有权限；此时尚未加载资源，只评估主体和环境属性
+		decision, err := services.Authorize(user, requiredPermission, nil, RequestEnvironment(c))
+		if err != nil {
+			c.JSON(500, gin.H{"error": "权限检查失败"})
+			c.Abort()
 			return
 		}
-
-		// 检查权限
-		hasPermission := false
-		for _, p := range user.Role.Permissions {
-			if p.Code == requiredPermission {
-				hasPermission = true
-				break
-			}
+		if !decision.Allowed {
+			RespondForbidden(c, decision)
+			c.Abort()
+			return
 		}
 
-		if !hasPermission {
-			c.JSON(403, gin.H{"error": "没有权限"})
+		c.Next()
+	}
+}
+
+// RequestEnvironment 返回当前请求的环境属性，供策略条件使用
+func RequestEnvironment(c *gin.Context) services.Attributes {
+	return services.EnvironmentAttributes(time.Now(), c.ClientIP(), c.Request.Method, c.FullPath())
+}
+
+// RespondForbidden 按拒绝原因返回 403，被策略拒绝时附带策略名称
+func RespondForbidden(c *gin.Context, decision *services.AuthzDecision) {
+	reason := decision.Decisive()
+	switch reason.Type {
+	case services.ReasonTenantDisabled, services.ReasonUserDisabled:
+		c.JSON(403, gin.H{"error": reason.Message})
+	case services.ReasonPolicyDeny:
+		c.JSON(403, gin.H{"error": "没有权限", "policy": reason.Policy.Name})
+	default:
+		c.JSON(403, gin.H{"error": "没有权限"})
+	}
+}
+
+// RequirePlatform 只允许平台租户的用户访问，例如租户管理和全局权限定义
+func RequirePlatform() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := services.LoadSubject(c.GetString("username"))
+		if err != nil || !user.TenantPlatform {
+			c.JSON(403, gin.H{"error": "只有平台管理员可以执行该操作"})
 			c.Abort()
 			return
 		}
-
 		c.Next()
 	}
-} 
\ No newline at end of file
+}
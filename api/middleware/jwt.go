@@ -3,44 +3,101 @@ package middleware
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"time"
 	"strings"
 
 	"useradmin/api/config"
+	"useradmin/api/services"
 )
 
+// 两步验证过程中使用的临时token用途，不能用于访问接口
+const (
+	PurposeMFAVerify = "mfa_verify" // 已启用两步验证，等待输入验证码
+	PurposeMFAEnroll = "mfa_enroll" // 角色强制两步验证但尚未设置
+)
+
+// mfaTokenExpire 两步验证临时token的有效期
+const mfaTokenExpire = 5 * time.Minute
+
 type Claims struct {
 	Username string `json:"username"`
+	TenantID uint   `json:"tid,omitempty"` // 用户所属租户，接口只能访问该租户的数据
+	Purpose  string `json:"purpose,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken 生成JWT token
-func GenerateToken(username string) (string, error) {
+// GenerateToken 生成JWT访问token，每个token带有唯一的 jti 以便吊销
+func GenerateToken(username string, tenantID uint) (string, *Claims, error) {
 	cfg := config.GetConfig()
-	
+	now := time.Now()
+
 	// 创建 claims
 	claims := &Claims{
 		Username: username,
+		TenantID: tenantID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(cfg.JWT.Expire))),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
+			ID:        uuid.New().String(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute * time.Duration(cfg.JWT.AccessExpire))),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
 		},
 	}
 
 	// 生成 token
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	signed, err := token.SignedString([]byte(cfg.JWT.Secret))
+	if err != nil {
+		return "", nil, err
+	}
+	return signed, claims, nil
+}
+
+// GenerateMFAToken 密码验证通过后生成两步验证用的临时token
+func GenerateMFAToken(username, purpose string) (string, error) {
+	cfg := config.GetConfig()
+	now := time.Now()
+
+	claims := &Claims{
+		Username: username,
+		Purpose:  purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaTokenExpire)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+	}
+
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(cfg.JWT.Secret))
 }
 
+// ParseMFAToken 解析两步验证临时token，并校验用途
+func ParseMFAToken(tokenString string, purposes ...string) (*Claims, error) {
+	claims, err := ParseToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if IsRevoked(claims.ID) {
+		return nil, jwt.ErrTokenExpired
+	}
+	for _, purpose := range purposes {
+		if claims.Purpose == purpose {
+			return claims, nil
+		}
+	}
+	return nil, jwt.ErrTokenInvalidClaims
+}
+
 // ParseToken 解析JWT token
 func ParseToken(tokenString string) (*Claims, error) {
 	cfg := config.GetConfig()
-	
+
 	// 解析 token
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		return []byte(cfg.JWT.Secret), nil
-	})
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 
 	if err != nil {
 		return nil, err
@@ -62,13 +119,8 @@ func JWTAuth() gin.HandlerFunc {
 			return
 		}
 
-		cfg := config.GetConfig()
-		claims := &Claims{}
 		token = strings.Replace(token, "Bearer ", "", 1)
-		_, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(cfg.JWT.Secret), nil
-		})
-
+		claims, err := ParseToken(token)
 		if err != nil {
 			//fmt.Println("err",err)
 			c.JSON(401, gin.H{"error": "token无效"  })
@@ -76,7 +128,26 @@ func JWTAuth() gin.HandlerFunc {
 			return
 		}
 
+		// 两步验证的临时token不能访问接口；检查token是否已被吊销（登出、禁用用户等）
+		if claims.Purpose != "" || IsRevoked(claims.ID) {
+			c.JSON(401, gin.H{"error": "token已失效"})
+			c.Abort()
+			return
+		}
+
+		// 没有租户的token（升级前签发）需要重新登录
+		if claims.TenantID == 0 {
+			c.JSON(401, gin.H{"error": "token已失效"})
+			c.Abort()
+			return
+		}
+
+		// 后续使用请求 context 的数据库操作自动限定在该租户内
+		c.Request = c.Request.WithContext(services.WithTenant(c.Request.Context(), claims.TenantID))
+
 		c.Set("username", claims.Username)
+		c.Set("tenant_id", claims.TenantID)
+		c.Set("claims", claims)
 		c.Next()
 	}
-} 
\ No newline at end of file
+}
@@ -0,0 +1,275 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"useradmin/api/config"
+)
+
+// redactMarker 替换脱敏字段的值
+const redactMarker = "******"
+
+// redactBufferSize 需要脱敏的 JSON、表单内容最多缓存的字节数，超出时无法完整解析，不记录内容
+const redactBufferSize = 64 << 10
+
+// logSkipBodyKey 路由关闭内容记录的标记，见 SkipLogBody
+const logSkipBodyKey = "log_skip_body"
+
+// SkipLogBody 路由选项：请求日志不记录该接口的请求体和响应体，
+// 用于日志查询等响应很大或没有记录价值的接口
+func SkipLogBody() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(logSkipBodyKey, true)
+		c.Next()
+	}
+}
+
+// Redactor 按字段路径规则替换 JSON 和表单内容中的敏感字段
+type Redactor struct {
+	rules [][]string
+}
+
+// NewRedactor 创建脱敏规则，路径以 . 分隔，* 匹配一层任意字段，** 匹配任意多层（包括零层），不区分大小写
+func NewRedactor(paths []string) *Redactor {
+	r := &Redactor{}
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		r.rules = append(r.rules, strings.Split(strings.ToLower(path), "."))
+	}
+	return r
+}
+
+// matches 判断字段路径是否命中任意一条规则
+func (r *Redactor) matches(path []string) bool {
+	for _, rule := range r.rules {
+		if matchPath(rule, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPath(rule, path []string) bool {
+	if len(rule) == 0 {
+		return len(path) == 0
+	}
+	if rule[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchPath(rule[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 || (rule[0] != "*" && rule[0] != path[0]) {
+		return false
+	}
+	return matchPath(rule[1:], path[1:])
+}
+
+// RedactJSON 脱敏 JSON 内容，内容不是合法的 JSON 时返回 false
+func (r *Redactor) RedactJSON(body []byte) ([]byte, bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, false
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, false
+	}
+
+	var out bytes.Buffer
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(r.redactValue(v, nil)); err != nil {
+		return nil, false
+	}
+	return bytes.TrimRight(out.Bytes(), "\n"), true
+}
+
+func (r *Redactor) redactValue(v any, path []string) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for key, child := range val {
+			childPath := append(path[:len(path):len(path)], strings.ToLower(key))
+			if r.matches(childPath) {
+				val[key] = redactMarker
+				continue
+			}
+			val[key] = r.redactValue(child, childPath)
+		}
+	case []any:
+		for i, child := range val {
+			val[i] = r.redactValue(child, path)
+		}
+	}
+	return v
+}
+
+// RedactForm 脱敏 application/x-www-form-urlencoded 内容，字段按顶层路径匹配
+func (r *Redactor) RedactForm(body []byte) ([]byte, bool) {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, false
+	}
+	for key := range values {
+		if r.matches([]string{strings.ToLower(key)}) {
+			values[key] = []string{redactMarker}
+		}
+	}
+	return []byte(values.Encode()), true
+}
+
+// bodyCapture 记录内容的前 limit 字节和总大小
+type bodyCapture struct {
+	buf   bytes.Buffer
+	limit int
+	size  int
+}
+
+func (b *bodyCapture) write(p []byte) {
+	b.size += len(p)
+	if remain := b.limit - b.buf.Len(); remain > 0 {
+		if len(p) > remain {
+			p = p[:remain]
+		}
+		b.buf.Write(p)
+	}
+}
+
+// complete 是否记录了全部内容
+func (b *bodyCapture) complete() bool {
+	return b.size == b.buf.Len()
+}
+
+// captureReader 在处理函数读取请求体的同时记录内容，不预先读取整个请求体
+type captureReader struct {
+	io.ReadCloser
+	capture *bodyCapture
+}
+
+func (r captureReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.capture.write(p[:n])
+	return n, err
+}
+
+// bodyLogger 按日志配置整理请求体、响应体
+type bodyLogger struct {
+	redactor     *Redactor
+	maxSize      int
+	captureLimit int
+	skipTypes    []string
+}
+
+func newBodyLogger(cfg config.LogConfig) *bodyLogger {
+	l := &bodyLogger{
+		redactor:     NewRedactor(cfg.RedactFields),
+		maxSize:      cfg.MaxBodySize,
+		captureLimit: cfg.MaxBodySize,
+		skipTypes:    cfg.SkipContentTypes,
+	}
+	// 需要完整解析才能脱敏，缓存的内容可以比记录的多
+	if len(l.redactor.rules) > 0 && l.maxSize > 0 && l.captureLimit < redactBufferSize {
+		l.captureLimit = redactBufferSize
+	}
+	return l
+}
+
+// newCapture 返回用于记录内容的缓冲，不记录时返回 nil
+func (l *bodyLogger) newCapture(contentType string) *bodyCapture {
+	if l.maxSize <= 0 || l.skipped(contentType) {
+		return nil
+	}
+	return &bodyCapture{limit: l.captureLimit}
+}
+
+// skipped 是否为不记录内容的类型
+func (l *bodyLogger) skipped(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	for _, prefix := range l.skipTypes {
+		if prefix != "" && strings.HasPrefix(contentType, strings.ToLower(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// format 返回写入日志的内容：脱敏后截断到 maxSize，并标注原始大小
+func (l *bodyLogger) format(contentType string, capture *bodyCapture, size int) string {
+	if size <= 0 {
+		return ""
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if capture == nil {
+		if l.maxSize <= 0 {
+			return ""
+		}
+		return fmt.Sprintf("[未记录 %s 内容，%d 字节]", mediaType, size)
+	}
+
+	body := capture.buf.Bytes()
+	if len(l.redactor.rules) > 0 {
+		redacted, structured, ok := l.redact(mediaType, body)
+		switch {
+		case structured && !capture.complete():
+			return fmt.Sprintf("[内容过大无法脱敏，未记录，%d 字节]", size)
+		case structured && !ok:
+			return fmt.Sprintf("[内容无法解析，未记录，%d 字节]", size)
+		case ok:
+			body = redacted
+		}
+	}
+	return truncateBody(body, l.maxSize, capture.complete(), size)
+}
+
+// redact 按内容类型脱敏。structured 表示内容需要脱敏（JSON 或表单），ok 表示脱敏成功
+func (l *bodyLogger) redact(mediaType string, body []byte) (redacted []byte, structured, ok bool) {
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		redacted, ok = l.redactor.RedactJSON(body)
+		return redacted, true, ok
+	case mediaType == "application/x-www-form-urlencoded":
+		redacted, ok = l.redactor.RedactForm(body)
+		return redacted, true, ok
+	}
+	// 未声明类型的内容看起来是 JSON 时也按 JSON 脱敏
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		redacted, ok = l.redactor.RedactJSON(body)
+		return redacted, true, ok
+	}
+	return nil, false, false
+}
+
+// truncateBody 截断到 max 字节（不拆分 UTF-8 字符），内容不完整时标注原始大小
+func truncateBody(body []byte, max int, complete bool, size int) string {
+	if len(body) <= max && complete {
+		return string(body)
+	}
+	if len(body) > max {
+		body = body[:max]
+		// 去掉截断处不完整的字符
+		for i := len(body) - 1; i >= 0 && i >= len(body)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(body[i]) {
+				if !utf8.FullRune(body[i:]) {
+					body = body[:i]
+				}
+				break
+			}
+		}
+	}
+	return fmt.Sprintf("%s…[已截断，共 %d 字节]", body, size)
+}
@@ -0,0 +1,205 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"useradmin/api/config"
+	"useradmin/api/models"
+	"useradmin/api/services"
+)
+
+// 日志队列已满时的处理方式
+const (
+	LogOverflowDrop       = "drop"        // 丢弃新日志
+	LogOverflowDropOldest = "drop_oldest" // 丢弃队列中最早的日志
+	LogOverflowBlock      = "block"       // 最多等待 block_timeout，仍然已满时丢弃新日志
+)
+
+// LogWriterStats 异步日志写入的统计信息
+type LogWriterStats struct {
+	Running  bool                    `json:"running"`
+	Queued   int                     `json:"queued"`
+	Capacity int                     `json:"capacity"`
+	Dropped  uint64                  `json:"dropped"` // 队列已满或已停止时丢弃的日志
+	Batches  uint64                  `json:"batches"`
+	Overflow string                  `json:"overflow"`
+	Sinks    []services.LogSinkStats `json:"sinks"` // 各输出已写入、写入失败、队列已满丢弃的条数
+}
+
+// logWriter 将请求日志放入有界队列，由后台协程按批写入各个输出，请求不再等待写入
+type logWriter struct {
+	sinks        *services.LogFanout
+	queue        chan models.Log
+	batchSize    int
+	interval     time.Duration
+	overflow     string
+	blockTimeout time.Duration
+
+	// 写入队列时持有读锁，停止时持有写锁关闭队列，避免向已关闭的队列写入
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+
+	dropped atomic.Uint64
+	batches atomic.Uint64
+}
+
+// asyncLogs 为 nil 时 Logger 同步写入数据库
+var asyncLogs *logWriter
+
+// StartLogWriter 按配置创建日志输出并启动后台写入，应在注册 Logger 之前调用；
+// 停止时调用 StopLogWriter 写入剩余日志
+func StartLogWriter(cfg config.LogConfig) error {
+	sinks, err := services.NewLogSinks(cfg)
+	if err != nil {
+		return err
+	}
+	w := &logWriter{
+		sinks:        sinks,
+		queue:        make(chan models.Log, cfg.QueueSize),
+		batchSize:    cfg.BatchSize,
+		interval:     time.Duration(cfg.FlushInterval) * time.Millisecond,
+		overflow:     cfg.Overflow,
+		blockTimeout: time.Duration(cfg.BlockTimeout) * time.Millisecond,
+		done:         make(chan struct{}),
+	}
+	go w.run()
+	asyncLogs = w
+	return nil
+}
+
+// StopLogWriter 停止接收日志并写入队列中剩余的日志，ctx 到期时放弃剩余日志并返回错误
+func StopLogWriter(ctx context.Context) error {
+	w := asyncLogs
+	if w == nil {
+		return nil
+	}
+
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	select {
+	case <-w.done:
+	case <-ctx.Done():
+		log.Printf("日志写入超时，%d 条日志未写入", len(w.queue))
+		return ctx.Err()
+	}
+	// 等待各输出写完各自队列中的日志
+	return w.sinks.Close(ctx)
+}
+
+// GetLogWriterStats 返回异步日志写入的统计信息
+func GetLogWriterStats() LogWriterStats {
+	w := asyncLogs
+	if w == nil {
+		return LogWriterStats{}
+	}
+	w.mu.RLock()
+	running := !w.closed
+	w.mu.RUnlock()
+	return LogWriterStats{
+		Running:  running,
+		Queued:   len(w.queue),
+		Capacity: cap(w.queue),
+		Dropped:  w.dropped.Load(),
+		Batches:  w.batches.Load(),
+		Overflow: w.overflow,
+		Sinks:    w.sinks.Stats(),
+	}
+}
+
+// writeLog 写入一条请求日志，启动了后台写入时只放入队列
+func writeLog(entry models.Log) error {
+	if asyncLogs == nil {
+		return config.DB.Create(&entry).Error
+	}
+	asyncLogs.enqueue(entry)
+	return nil
+}
+
+// enqueue 放入队列，队列已满时按 overflow 处理，不会无限期阻塞请求
+func (w *logWriter) enqueue(entry models.Log) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		w.dropped.Add(1)
+		return
+	}
+
+	select {
+	case w.queue <- entry:
+		return
+	default:
+	}
+
+	switch w.overflow {
+	case LogOverflowBlock:
+		timer := time.NewTimer(w.blockTimeout)
+		defer timer.Stop()
+		select {
+		case w.queue <- entry:
+			return
+		case <-timer.C:
+		}
+	case LogOverflowDropOldest:
+		select {
+		case <-w.queue:
+			w.dropped.Add(1)
+		default:
+		}
+		select {
+		case w.queue <- entry:
+			return
+		default:
+		}
+	}
+	w.dropped.Add(1)
+}
+
+// run 攒够 batchSize 条或每隔 interval 写入一次，队列关闭后写入剩余日志并退出
+func (w *logWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	batch := make([]models.Log, 0, w.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		w.flush(batch)
+		batch = make([]models.Log, 0, w.batchSize)
+	}
+
+	for {
+		select {
+		case entry, ok := <-w.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, entry)
+			if len(batch) >= w.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// flush 将一批日志交给各个输出，输出的队列已满时丢弃并记录错误，不重试，避免输出故障时日志无限堆积
+func (w *logWriter) flush(batch []models.Log) {
+	w.batches.Add(1)
+	if err := w.sinks.WriteLogs(batch); err != nil {
+		log.Printf("丢弃 %d 条请求日志: %v", len(batch), err)
+	}
+}
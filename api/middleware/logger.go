@@ -1,35 +1,58 @@
 package middleware
 
 import (
-	"bytes"
-	"io/ioutil"
 	"github.com/gin-gonic/gin"
 	"useradmin/api/config"
 	"useradmin/api/models"
+	"useradmin/api/services"
 )
 
 type bodyLogWriter struct {
 	gin.ResponseWriter
-	body *bytes.Buffer
+	logger  *bodyLogger
+	capture *bodyCapture
+	started bool
 }
 
-func (w bodyLogWriter) Write(b []byte) (int, error) {
-	w.body.Write(b)
+func (w *bodyLogWriter) Write(b []byte) (int, error) {
+	w.record(b)
 	return w.ResponseWriter.Write(b)
 }
 
+func (w *bodyLogWriter) WriteString(s string) (int, error) {
+	w.record([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// record 首次写入时按响应的 Content-Type 决定是否记录内容
+func (w *bodyLogWriter) record(b []byte) {
+	if !w.started {
+		w.started = true
+		w.capture = w.logger.newCapture(w.Header().Get("Content-Type"))
+	}
+	if w.capture != nil {
+		w.capture.write(b)
+	}
+}
+
+// Logger 记录请求日志。请求体、响应体按 config.LogConfig 脱敏、截断，
+// 二进制内容和通过 SkipLogBody 关闭记录的接口不记录内容
 func Logger() gin.HandlerFunc {
+	bodies := newBodyLogger(config.GetConfig().Log)
+
 	return func(c *gin.Context) {
-		// 读取请求体
-		var bodyBytes []byte
+		// 处理函数读取请求体时同步记录，不预先读取整个请求体
+		requestType := c.GetHeader("Content-Type")
+		var request *bodyCapture
 		if c.Request.Body != nil {
-			bodyBytes, _ = ioutil.ReadAll(c.Request.Body)
+			request = bodies.newCapture(requestType)
+			if request != nil {
+				c.Request.Body = captureReader{ReadCloser: c.Request.Body, capture: request}
+			}
 		}
-		// 恢复请求体
-		c.Request.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
 
 		// 包装响应写入器
-		blw := &bodyLogWriter{body: bytes.NewBufferString(""), ResponseWriter: c.Writer}
+		blw := &bodyLogWriter{ResponseWriter: c.Writer, logger: bodies}
 		c.Writer = blw
 
 		// 处理请求
@@ -41,20 +64,37 @@ func Logger() gin.HandlerFunc {
 			username = "anonymous"
 		}
 
+		// 未登录的请求（登录失败等）归入平台租户
+		tenantID := c.GetUint("tenant_id")
+		if tenantID == 0 {
+			tenantID, _ = services.PlatformTenantID()
+		}
+
 		// 创建日志记录
 		log := models.Log{
+			TenantID:  tenantID,
+			RequestID: c.GetString("request_id"),
 			Username:  username,
 			Action:    c.Request.Method,
 			Resource:  c.Request.URL.Path,
 			IP:        c.ClientIP(),
 			UserAgent: c.Request.UserAgent(),
 			Status:    c.Writer.Status(),
-			Response:  blw.body.String(),
+		}
+		if !c.GetBool(logSkipBodyKey) {
+			requestSize := 0
+			if request != nil {
+				requestSize = request.size
+			} else if c.Request.ContentLength > 0 {
+				requestSize = int(c.Request.ContentLength)
+			}
+			log.Request = bodies.format(requestType, request, requestSize)
+			log.Response = bodies.format(blw.Header().Get("Content-Type"), blw.capture, blw.Size())
 		}
 
-		// 保存日志
-		if err := config.DB.Create(&log).Error; err != nil {
+		// 保存日志，启动了后台写入时只放入队列（见 StartLogWriter）
+		if err := writeLog(log); err != nil {
 			c.Error(err)
 		}
 	}
-} 
\ No newline at end of file
+}
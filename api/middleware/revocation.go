@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"sync"
+	"time"
+
+	"gorm.io/gorm/clause"
+	"useradmin/api/config"
+	"useradmin/api/models"
+)
+
+// 已吊销访问token的内存副本，JWTAuth 每次请求只查内存
+var revoked = struct {
+	sync.RWMutex
+	items map[string]time.Time
+}{items: make(map[string]time.Time)}
+
+// LoadRevokedTokens 启动时从数据库加载尚未过期的吊销记录，并清理已过期的记录
+func LoadRevokedTokens() error {
+	now := time.Now()
+	if err := config.DB.Where("expires_at < ?", now).Delete(&models.RevokedToken{}).Error; err != nil {
+		return err
+	}
+
+	var tokens []models.RevokedToken
+	if err := config.DB.Find(&tokens).Error; err != nil {
+		return err
+	}
+
+	revoked.Lock()
+	defer revoked.Unlock()
+	for _, t := range tokens {
+		revoked.items[t.JTI] = t.ExpiresAt
+	}
+	return nil
+}
+
+// RevokeJTI 吊销指定的访问token
+func RevokeJTI(jti string, expiresAt time.Time) error {
+	if jti == "" || time.Now().After(expiresAt) {
+		return nil
+	}
+
+	record := models.RevokedToken{JTI: jti, ExpiresAt: expiresAt}
+	if err := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&record).Error; err != nil {
+		return err
+	}
+
+	revoked.Lock()
+	revoked.items[jti] = expiresAt
+	revoked.Unlock()
+	return nil
+}
+
+// IsRevoked 判断访问token是否已被吊销
+func IsRevoked(jti string) bool {
+	revoked.RLock()
+	expiresAt, ok := revoked.items[jti]
+	revoked.RUnlock()
+	if !ok {
+		return false
+	}
+
+	// 已过期的token本身就无法通过校验，顺便从内存中移除
+	if time.Now().After(expiresAt) {
+		revoked.Lock()
+		delete(revoked.items, jti)
+		revoked.Unlock()
+	}
+	return true
+}
@@ -0,0 +1,189 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"useradmin/api/config"
+	"useradmin/api/models"
+	"useradmin/api/services"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("刷新token无效")
+	ErrRefreshTokenExpired = errors.New("刷新token已过期")
+	ErrRefreshTokenReused  = errors.New("刷新token已被使用")
+	ErrUserDisabled        = errors.New("用户已被禁用")
+)
+
+// TokenPair 登录或刷新后返回给客户端的token
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // 访问token有效期(秒)
+}
+
+// IssueTokens 为用户签发新的访问token和刷新token（开启一个新的会话）
+func IssueTokens(user models.User) (*TokenPair, error) {
+	return issueTokens(config.DB, user, uuid.New().String(), nil)
+}
+
+// RefreshTokens 使用刷新token换取新的token，旧的刷新token立即失效
+// 已被轮换过的刷新token再次使用时，视为泄露，吊销整个会话
+func RefreshTokens(refreshToken string) (*TokenPair, error) {
+	var pair *TokenPair
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var current models.RefreshToken
+		if err := tx.Where("token_hash = ?", hashToken(refreshToken)).First(&current).Error; err != nil {
+			return ErrRefreshTokenInvalid
+		}
+
+		if current.RevokedAt != nil {
+			if current.ReplacedBy != 0 {
+				return ErrRefreshTokenReused
+			}
+			return ErrRefreshTokenInvalid
+		}
+		if time.Now().After(current.ExpiresAt) {
+			return ErrRefreshTokenExpired
+		}
+
+		var user models.User
+		if err := tx.First(&user, current.UserID).Error; err != nil {
+			return ErrRefreshTokenInvalid
+		}
+		// 用户或其租户被停用后不能继续刷新
+		if user.Status != 1 || services.CheckTenantActive(user.TenantID) != nil {
+			return ErrUserDisabled
+		}
+
+		var err error
+		pair, err = issueTokens(tx, user, current.FamilyID, &current)
+		return err
+	})
+
+	if errors.Is(err, ErrRefreshTokenReused) || errors.Is(err, ErrUserDisabled) {
+		var current models.RefreshToken
+		if config.DB.Where("token_hash = ?", hashToken(refreshToken)).First(&current).Error == nil {
+			if revokeErr := revokeTokens(config.DB.Where("family_id = ?", current.FamilyID)); revokeErr != nil {
+				return nil, revokeErr
+			}
+		}
+	}
+	return pair, err
+}
+
+// RevokeSession 登出：吊销当前访问token及其所属会话的刷新token
+func RevokeSession(claims *Claims) error {
+	if err := RevokeJTI(claims.ID, claims.ExpiresAt.Time); err != nil {
+		return err
+	}
+
+	var current models.RefreshToken
+	if err := config.DB.Where("access_jti = ?", claims.ID).First(&current).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	return revokeTokens(config.DB.Where("family_id = ?", current.FamilyID))
+}
+
+// RevokeUserTokens 吊销用户的所有会话，用于禁用、删除用户或修改密码后强制下线
+func RevokeUserTokens(userID uint) error {
+	return revokeTokens(config.DB.Where("user_id = ?", userID))
+}
+
+// issueTokens 签发token并保存刷新token，previous 不为空时表示轮换
+func issueTokens(tx *gorm.DB, user models.User, familyID string, previous *models.RefreshToken) (*TokenPair, error) {
+	cfg := config.GetConfig()
+
+	accessToken, claims, err := GenerateToken(user.Username, user.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	record := models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(refreshToken),
+		AccessJTI: claims.ID,
+		ExpiresAt: time.Now().Add(time.Hour * time.Duration(cfg.JWT.RefreshExpire)),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return nil, err
+	}
+
+	if previous != nil {
+		// 只有仍未吊销时才能轮换，并发使用同一个刷新token时只有一个请求成功，其余视为重复使用
+		now := time.Now()
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", previous.ID).
+			Updates(map[string]interface{}{
+				"revoked_at":  now,
+				"replaced_by": record.ID,
+			})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected != 1 {
+			return nil, ErrRefreshTokenReused
+		}
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(cfg.JWT.AccessExpire * 60),
+	}, nil
+}
+
+// revokeTokens 吊销查询条件匹配的刷新token，以及仍可能有效的访问token
+func revokeTokens(query *gorm.DB) error {
+	cfg := config.GetConfig()
+	now := time.Now()
+	accessTTL := time.Minute * time.Duration(cfg.JWT.AccessExpire)
+
+	var tokens []models.RefreshToken
+	if err := query.Session(&gorm.Session{}).
+		Where("created_at > ?", now.Add(-accessTTL)).
+		Find(&tokens).Error; err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		if err := RevokeJTI(t.AccessJTI, t.CreatedAt.Add(accessTTL)); err != nil {
+			return err
+		}
+	}
+
+	return query.Session(&gorm.Session{}).
+		Model(&models.RefreshToken{}).
+		Where("revoked_at IS NULL").
+		Update("revoked_at", now).Error
+}
+
+// randomToken 生成随机的刷新token
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken 刷新token只保存哈希值
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
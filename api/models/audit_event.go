@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// AuditEvent 业务审计事件，记录谁在什么时候把什么改成了什么，与请求日志（Log）分开存储
+type AuditEvent struct {
+	ID         uint   `gorm:"primarykey" json:"id"`
+	TenantID   uint   `gorm:"index;not null;default:0" json:"tenant_id"`
+	RequestID  string `gorm:"size:64;index" json:"request_id"`
+	Type       string `gorm:"size:64;not null;index" json:"type"` // 例如 user.created、role.permissions_changed
+	ActorID    uint   `gorm:"index" json:"actor_id"`              // 后台任务产生的事件为 0
+	ActorName  string `gorm:"size:64" json:"actor_name"`
+	TargetType string `gorm:"size:32;index:idx_audit_target" json:"target_type"`
+	TargetID   string `gorm:"size:64;index:idx_audit_target" json:"target_id"`
+	// 字段变更列表（JSON 数组），见 services.FieldChange
+	Changes   string    `gorm:"type:text" json:"changes"`
+	IP        string    `gorm:"size:64" json:"ip"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// Department 部门，ParentID 为 0 的是顶级部门
+type Department struct {
+	gorm.Model
+	TenantID uint   `gorm:"index;not null;default:0" json:"tenant_id"`
+	Name     string `gorm:"not null" json:"name"`
+	ParentID uint   `gorm:"index;default:0" json:"parent_id"`
+	// 从根到本部门的ID路径，例如 /1/5/，用于查询子树
+	Path   string `gorm:"size:255;index" json:"path"`
+	Sort   int    `gorm:"default:0" json:"sort"`
+	Status int    `gorm:"default:1" json:"status"` // 0: 停用, 1: 启用
+}
@@ -4,31 +4,57 @@ import (
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 	"log"
+	"time"
+	"useradmin/api/config"
 )
 
 // InitData 初始化基础数据
 func InitData(db *gorm.DB) error {
 	// 自动迁移表结构
-	if err := db.AutoMigrate(&Permission{}, &Role{}, &RolePermission{}); err != nil {
+	if err := db.AutoMigrate(&Tenant{}, &Permission{}, &Role{}, &RolePermission{}, &RoleParent{}, &RoleDataDepartment{}, &UserRole{}); err != nil {
 		return err
 	}
 
-	// 创建默认权限
-	for _, perm := range DefaultPermissions {
-		var count int64
-		db.Model(&Permission{}).Where("code = ?", perm.Code).Count(&count)
-		if count == 0 {
-			if err := db.Create(&perm).Error; err != nil {
-				return err
-			}
+	// 角色名改为租户内唯一，删除旧的全局唯一索引
+	if db.Migrator().HasIndex(&Role{}, "name") {
+		if err := db.Migrator().DropIndex(&Role{}, "name"); err != nil {
+			return err
 		}
 	}
 
-	// 创建超级管理员角色
+	// 创建平台租户，单租户时代的数据都归入平台租户
+	var platform Tenant
+	if err := db.Where("is_platform = ?", true).Order("id").First(&platform).Error; err != nil {
+		platform = Tenant{Code: "platform", Name: "平台", Status: 1, IsPlatform: true}
+		if err := db.Create(&platform).Error; err != nil {
+			return err
+		}
+	}
+	for _, table := range []string{"users", "roles", "products", "logs", "departments"} {
+		if err := db.Exec("UPDATE "+table+" SET tenant_id = ? WHERE tenant_id = 0", platform.ID).Error; err != nil {
+			return err
+		}
+	}
+
+	// 内置权限由路由权限注册表在启动时写入（services.SeedPermissions）
+
+	// 创建超级管理员角色，已存在系统超级管理员角色时不依赖名称和ID查找
 	var adminRole Role
-	if err := db.FirstOrCreate(&adminRole, Role{
-		Name: "超级管理员",
-		Description: "系统超级管理员",
+	if err := db.Where("tenant_id = ? AND is_system = ? AND is_superuser = ?", platform.ID, true, true).First(&adminRole).Error; err != nil {
+		if err := db.FirstOrCreate(&adminRole, Role{
+			TenantID: platform.ID,
+			Name: "超级管理员",
+			Description: "系统超级管理员",
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	// 标记为系统内置的超级管理员角色，并设置是否强制两步验证
+	if err := db.Model(&adminRole).Updates(map[string]interface{}{
+		"is_system":    true,
+		"is_superuser": true,
+		"require_mfa":  config.GetConfig().Security.AdminRequireMFA,
 	}).Error; err != nil {
 		return err
 	}
@@ -42,16 +68,42 @@ func InitData(db *gorm.DB) error {
 		return err
 	}
 
+	// 创建平台管理员角色，拥有租户管理权限
+	var platformRole Role
+	if err := db.FirstOrCreate(&platformRole, Role{
+		TenantID:    platform.ID,
+		Name:        "平台管理员",
+		Description: "管理所有租户",
+	}).Error; err != nil {
+		return err
+	}
+	if err := db.Model(&platformRole).Update("is_system", true).Error; err != nil {
+		return err
+	}
+	var tenantPermissions []Permission
+	if err := db.Where("code LIKE ?", "tenant:%").Find(&tenantPermissions).Error; err != nil {
+		return err
+	}
+	if err := db.Model(&platformRole).Association("Permissions").Replace(tenantPermissions); err != nil {
+		return err
+	}
+
 	// 创建超级管理员用户
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte("admin123"), bcrypt.DefaultCost)
 	if err != nil {
 		return err
 	}
+	// 默认密码仅用于首次登录，登录后必须修改
+	now := time.Now()
 	adminUser := User{
-		Username: "admin",
-		Password: string(hashedPassword),
-		RoleID:   adminRole.ID,
-		Status:   1,
+		TenantID:           platform.ID,
+		Username:           "admin",
+		Password:           string(hashedPassword),
+		RoleID:             adminRole.ID,
+		Status:             1,
+		PasswordChangedAt:  &now,
+		MustChangePassword: true,
+		IsSystem:           true,
 	}
 
 	var count int64
@@ -60,7 +112,40 @@ func InitData(db *gorm.DB) error {
 		if err := db.Create(&adminUser).Error; err != nil {
 			return err
 		}
+		if err := db.Create(&UserRole{UserID: adminUser.ID, RoleID: adminRole.ID}).Error; err != nil {
+			return err
+		}
 		log.Printf("创建管理员用户成功: %s", adminUser.Username)
+	} else {
+		// 仍在使用默认密码的管理员，登录后必须修改密码
+		var existing User
+		if err := db.Where("username = ?", adminUser.Username).First(&existing).Error; err != nil {
+			return err
+		}
+		if !existing.IsSystem {
+			if err := db.Model(&existing).Update("is_system", true).Error; err != nil {
+				return err
+			}
+		}
+		if bcrypt.CompareHashAndPassword([]byte(existing.Password), []byte("admin123")) == nil {
+			if err := db.Model(&existing).Update("must_change_password", true).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	// 角色权限关联的租户与角色一致
+	if err := db.Exec(`UPDATE role_permissions rp JOIN roles r ON r.id = rp.role_id
+		SET rp.tenant_id = r.tenant_id WHERE rp.tenant_id = 0`).Error; err != nil {
+		return err
+	}
+
+	// 将单角色时代的 users.role_id 迁移到 user_roles
+	if err := db.Exec(`INSERT INTO user_roles (user_id, role_id, created_at)
+		SELECT u.id, u.role_id, NOW() FROM users u
+		WHERE u.role_id <> 0 AND u.deleted_at IS NULL
+		AND NOT EXISTS (SELECT 1 FROM user_roles ur WHERE ur.user_id = u.id AND ur.role_id = u.role_id)`).Error; err != nil {
+		return err
 	}
 
 	return nil
@@ -5,14 +5,20 @@ import (
 	"time"
 )
 
+// Log 请求日志。日志表写入量大、数据多，不参与启动时的 AutoMigrate，
+// 表结构变更和索引都在 MigrateLogs 中显式执行。索引只在 logIndexes 中定义，不使用 gorm 标签
+// （gorm.Model 自带的 deleted_at 索引除外）
 type Log struct {
 	gorm.Model
-	Username  string    `json:"username"`
+	TenantID  uint      `gorm:"not null;default:0" json:"tenant_id"`
+	RequestID string    `gorm:"size:64" json:"request_id"`
+	Username  string    `gorm:"size:191" json:"username"`
 	Action    string    `json:"action"`
 	Resource  string    `json:"resource"`
-	IP        string    `json:"ip"`
+	IP        string    `gorm:"size:45" json:"ip"`
 	UserAgent string    `json:"user_agent"`
 	Status    int       `json:"status"`
-	Response  string    `json:"response"`
+	Request   string    `gorm:"type:text" json:"request"` // 请求体，已脱敏、截断
+	Response  string    `json:"response"`                 // 响应体，已脱敏、截断
 	CreatedAt time.Time `json:"created_at"`
-} 
\ No newline at end of file
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// logIndexes 日志表的索引。GetLogs 的查询都会带上租户条件，索引以 tenant_id 开头；
+// 列表按 (排序字段, id) 排序翻页，InnoDB 二级索引末尾自带主键，(tenant_id, created_at) 即可按 (created_at, id) 顺序读取
+var logIndexes = []struct {
+	name    string
+	columns string
+}{
+	{"idx_logs_tenant_created", "tenant_id, created_at"},
+	{"idx_logs_tenant_status_id", "tenant_id, status, id"},
+	{"idx_logs_tenant_username", "tenant_id, username"},
+	{"idx_logs_tenant_resource", "tenant_id, resource(191)"}, // 按路径前缀查询，只索引前 191 个字符
+	{"idx_logs_tenant_ip", "tenant_id, ip"},
+	{"idx_logs_created_status", "created_at, status"}, // 跨租户的日志清理使用
+	{"idx_logs_request_id", "request_id"},             // 按审计事件的请求ID查找对应的请求日志
+}
+
+// obsoleteLogIndexes 已不再使用的索引，迁移时删除：
+//   - idx_logs_response：响应内容的全文索引，拖慢每次写入，已改为在其它条件筛选后的结果中 LIKE 搜索
+//   - idx_logs_tenant_status：(tenant_id, status, created_at)，与按 (status, id) 排序不一致，由 idx_logs_tenant_status_id 代替
+//   - idx_logs_tenant_id：tenant_id 单列索引，是以上各个 tenant_id 开头的索引的前缀
+var obsoleteLogIndexes = []string{"idx_logs_response", "idx_logs_tenant_status", "idx_logs_tenant_id"}
+
+// MigrateLogs 迁移日志表结构，只执行缺少的变更，已迁移过的库启动时不会再改表。
+// 修改 username、ip 的类型会重建表，日志表很大时应安排在维护窗口升级
+func MigrateLogs(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasTable(&Log{}) {
+		if err := m.CreateTable(&Log{}); err != nil {
+			return err
+		}
+		return createLogIndexes(db)
+	}
+
+	// 补齐新增的字段
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(&Log{}); err != nil {
+		return err
+	}
+	for _, field := range stmt.Schema.Fields {
+		if field.DBName == "" || m.HasColumn(&Log{}, field.DBName) {
+			continue
+		}
+		log.Printf("日志表添加字段 %s", field.DBName)
+		if err := m.AddColumn(&Log{}, field.Name); err != nil {
+			return err
+		}
+	}
+
+	// 用户名、IP 原为 longtext，改为 varchar 才能建立可用于排序的索引
+	columns, err := m.ColumnTypes(&Log{})
+	if err != nil {
+		return err
+	}
+	for _, column := range columns {
+		if (column.Name() == "username" || column.Name() == "ip") && column.DatabaseTypeName() != "varchar" {
+			log.Printf("日志表修改字段 %s 的类型", column.Name())
+			if err := m.AlterColumn(&Log{}, column.Name()); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 先建新索引再删旧索引，迁移过程中查询始终有索引可用
+	if err := createLogIndexes(db); err != nil {
+		return err
+	}
+	for _, name := range obsoleteLogIndexes {
+		if m.HasIndex(&Log{}, name) {
+			log.Printf("日志表删除索引 %s", name)
+			if err := m.DropIndex(&Log{}, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// createLogIndexes 创建缺少的索引
+func createLogIndexes(db *gorm.DB) error {
+	for _, index := range logIndexes {
+		if db.Migrator().HasIndex(&Log{}, index.name) {
+			continue
+		}
+		log.Printf("日志表创建索引 %s", index.name)
+		if err := db.Exec(fmt.Sprintf("CREATE INDEX %s ON logs (%s)", index.name, index.columns)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
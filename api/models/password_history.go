@@ -0,0 +1,12 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// PasswordHistory 用户历史密码的哈希，用于防止重复使用
+type PasswordHistory struct {
+	gorm.Model
+	UserID uint   `gorm:"index;not null" json:"user_id"`
+	Hash   string `gorm:"not null" json:"-"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+const (
+	PolicyEffectAllow = "allow"
+	PolicyEffectDeny  = "deny"
+)
+
+// Policy 授权策略，在角色权限（RBAC）之外按主体、资源和环境属性放行或拒绝操作
+type Policy struct {
+	gorm.Model
+	TenantID    uint   `gorm:"index;not null;default:0" json:"tenant_id"`
+	Name        string `gorm:"size:100;not null" json:"name"`
+	Description string `json:"description"`
+	Effect      string `gorm:"size:10;not null" json:"effect"`            // allow 或 deny
+	Permission  string `gorm:"size:100;not null;index" json:"permission"` // 适用的权限，支持通配符
+	// 条件列表（JSON 数组），全部满足时策略生效，见 services.PolicyCondition
+	Conditions string `gorm:"type:text" json:"conditions"`
+	Enabled    bool   `json:"enabled"`
+}
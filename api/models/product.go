@@ -7,6 +7,7 @@ import (
 // Product 商品模型
 type Product struct {
 	gorm.Model
+	TenantID    uint           `json:"tenant_id" gorm:"index;not null;default:0"` // 所属租户
 	Title       string         `json:"title" gorm:"not null"`              // 商品标题
 	Description string         `json:"description"`                        // 商品描述
 	Images      []ProductImage `json:"images" gorm:"foreignKey:ProductID"` // 商品图片
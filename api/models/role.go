@@ -4,9 +4,26 @@ import (
 	"gorm.io/gorm"
 )
 
+// 角色的数据范围，决定角色能看到和操作哪些商品、用户
+const (
+	DataScopeAll        = "all"        // 全部数据
+	DataScopeOwn        = "own"        // 仅本人创建的数据（用户管理中为本人）
+	DataScopeDepartment = "department" // 本部门的数据
+	DataScopeSubtree    = "subtree"    // 本部门及下级部门的数据
+	DataScopeCustom     = "custom"     // 自定义部门的数据，部门见 RoleDataDepartment
+)
+
 type Role struct {
 	gorm.Model
-	Name        string       `gorm:"unique;not null" json:"name"`
+	TenantID    uint         `gorm:"not null;default:0;uniqueIndex:idx_roles_tenant_name,priority:1" json:"tenant_id"`
+	Name        string       `gorm:"not null;uniqueIndex:idx_roles_tenant_name,priority:2" json:"name"` // 租户内唯一
 	Description string       `json:"description"`
+	RequireMFA  bool         `gorm:"default:false" json:"require_mfa"`        // 该角色的用户必须启用两步验证
+	IsSystem    bool         `gorm:"default:false" json:"is_system"`          // 系统内置角色，不能修改或删除
+	IsSuperuser bool         `gorm:"default:false" json:"is_superuser"`       // 超级管理员角色，拥有所有权限
+	DataScope   string       `gorm:"size:20;default:'all'" json:"data_scope"` // 数据范围：all/own/department/subtree/custom，见 DataScope* 常量
 	Permissions []Permission `gorm:"many2many:role_permissions;" json:"permissions"`
-} 
\ No newline at end of file
+	Parents     []Role       `gorm:"many2many:role_parents;joinForeignKey:RoleID;joinReferences:ParentID" json:"parents"` // 直接继承的父角色
+
+	DataDepartments []RoleDataDepartment `gorm:"foreignKey:RoleID" json:"-"` // 自定义数据范围的部门
+}
@@ -0,0 +1,13 @@
+package models
+
+// RoleDataDepartment 自定义数据范围的角色可访问的部门
+type RoleDataDepartment struct {
+	RoleID       uint `gorm:"primaryKey;not null" json:"role_id"`
+	DepartmentID uint `gorm:"primaryKey;not null" json:"department_id"`
+	Role         Role `gorm:"foreignKey:RoleID" json:"-"`
+}
+
+// TableName 指定表名
+func (RoleDataDepartment) TableName() string {
+	return "role_data_departments"
+}
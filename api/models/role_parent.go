@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// RoleParent 角色继承关联表，角色继承父角色（及其祖先角色）的全部权限
+type RoleParent struct {
+	RoleID    uint      `gorm:"primaryKey;not null" json:"role_id"`
+	ParentID  uint      `gorm:"primaryKey;not null;index" json:"parent_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Role      Role      `gorm:"foreignKey:RoleID" json:"-"`
+	Parent    Role      `gorm:"foreignKey:ParentID" json:"-"`
+}
+
+// TableName 指定表名
+func (RoleParent) TableName() string {
+	return "role_parents"
+}
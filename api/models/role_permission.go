@@ -1,9 +1,15 @@
 package models
 
+import "time"
+
 // RolePermission 角色权限关联表
 type RolePermission struct {
     RoleID       uint `gorm:"primaryKey;not null" json:"role_id"`
+    TenantID     uint `gorm:"index;not null;default:0" json:"tenant_id"` // 与角色所属租户一致
     PermissionID uint `gorm:"primaryKey;not null" json:"permission_id"`
+    // 临时授权的有效期，为空表示立即生效、长期有效；到期后由后台任务删除
+    ValidFrom    *time.Time `json:"valid_from"`
+    ValidUntil   *time.Time `gorm:"index" json:"valid_until"`
     Role         Role       `gorm:"foreignKey:RoleID" json:"-"`
     Permission   Permission `gorm:"foreignKey:PermissionID" json:"-"`
 }
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// Tenant 租户（店铺），用户、角色、商品、部门和日志都属于某个租户
+type Tenant struct {
+	gorm.Model
+	Code       string `gorm:"size:64;unique;not null" json:"code"`
+	Name       string `gorm:"not null" json:"name"`
+	Status     int    `gorm:"default:1" json:"status"`          // 0: 停用, 1: 启用
+	IsPlatform bool   `gorm:"default:false" json:"is_platform"` // 平台租户，其管理员可以管理所有租户
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken 刷新token，服务端只保存哈希值，每次刷新都会轮换
+type RefreshToken struct {
+	gorm.Model
+	UserID     uint       `gorm:"index;not null" json:"user_id"`
+	FamilyID   string     `gorm:"size:64;index;not null" json:"family_id"` // 同一次登录产生的token属于同一家族
+	TokenHash  string     `gorm:"size:64;uniqueIndex;not null" json:"-"`   // token的SHA-256
+	AccessJTI  string     `gorm:"size:64;index" json:"-"`                  // 与该刷新token一起签发的访问token
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	ReplacedBy uint       `json:"replaced_by"` // 轮换后的新token ID
+}
+
+// RevokedToken 已吊销的访问token，按 jti 记录，过期后可清理
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;size:64" json:"jti"`
+	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -2,13 +2,31 @@ package models
 
 import (
 	"gorm.io/gorm"
+	"time"
 )
 
 type User struct {
 	gorm.Model
-	Username string `gorm:"unique;not null" json:"username"`
-	Password string `json:"password"`
-	RoleID   uint   `json:"role_id"`
-	Status   int    `json:"status"` // 0: 禁用, 1: 启用
+	TenantID uint   `gorm:"index;not null;default:0" json:"tenant_id"`
+	Username string `gorm:"unique;not null" json:"username"` // 用户名全局唯一，登录时据此确定租户
+	Password string `json:"-"`
+	RoleID   uint   `json:"role_id"` // 主角色，兼容只支持单角色的接口
+	Status   int    `json:"status"`  // 0: 禁用, 1: 启用
 	Role     Role   `gorm:"foreignKey:RoleID" json:"role"`
-} 
\ No newline at end of file
+	Roles    []Role `gorm:"many2many:user_roles;" json:"roles"` // 用户拥有的全部角色，权限取并集
+
+	DepartmentID uint        `gorm:"index;default:0" json:"department_id"` // 所属部门，用于数据范围判断
+	Department   *Department `gorm:"foreignKey:DepartmentID" json:"department"`
+
+	IsSystem    bool `gorm:"default:false" json:"is_system"`    // 系统内置用户，不能删除或禁用
+	IsSuperuser bool `gorm:"default:false" json:"is_superuser"` // 超级管理员，不论角色拥有所有权限
+
+	// 两步验证
+	TOTPSecret      string `gorm:"size:64" json:"-"`
+	TOTPEnabled     bool   `gorm:"default:false" json:"totp_enabled"`
+	TOTPLastCounter int64  `json:"-"` // 最近一次使用的时间步，防止验证码重放
+
+	// 密码策略
+	PasswordChangedAt  *time.Time `json:"password_changed_at"`
+	MustChangePassword bool       `gorm:"default:false" json:"must_change_password"` // 下次登录必须修改密码
+}
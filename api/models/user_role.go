@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// UserRole 用户角色关联表，用户的权限为所有角色权限的并集
+type UserRole struct {
+	UserID    uint      `gorm:"primaryKey;not null" json:"user_id"`
+	RoleID    uint      `gorm:"primaryKey;not null;index" json:"role_id"`
+	CreatedAt time.Time `json:"created_at"`
+	// 临时授权的有效期，为空表示立即生效、长期有效；到期后由后台任务删除
+	ValidFrom  *time.Time `json:"valid_from"`
+	ValidUntil *time.Time `gorm:"index" json:"valid_until"`
+	User       User       `gorm:"foreignKey:UserID" json:"-"`
+	Role       Role       `gorm:"foreignKey:RoleID" json:"-"`
+}
+
+// TableName 指定表名
+func (UserRole) TableName() string {
+	return "user_roles"
+}
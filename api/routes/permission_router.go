@@ -0,0 +1,48 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"path"
+
+	"github.com/gin-gonic/gin"
+	"useradmin/api/middleware"
+	"useradmin/api/services"
+)
+
+// permissionRouter 注册需要权限的接口：挂载 CheckPermission 并把接口与权限登记到权限注册表
+type permissionRouter struct {
+	group *gin.RouterGroup
+	errs  []error
+}
+
+func (r *permissionRouter) handle(method, relativePath, code string, handlers ...gin.HandlerFunc) {
+	fullPath := path.Join(r.group.BasePath(), relativePath)
+	if err := services.BindRoutePermission(method, fullPath, code); err != nil {
+		r.errs = append(r.errs, err)
+		return
+	}
+	chain := append([]gin.HandlerFunc{middleware.CheckPermission(code)}, handlers...)
+	r.group.Handle(method, relativePath, chain...)
+}
+
+func (r *permissionRouter) GET(relativePath, code string, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodGet, relativePath, code, handlers...)
+}
+
+func (r *permissionRouter) POST(relativePath, code string, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodPost, relativePath, code, handlers...)
+}
+
+func (r *permissionRouter) PUT(relativePath, code string, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodPut, relativePath, code, handlers...)
+}
+
+func (r *permissionRouter) DELETE(relativePath, code string, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodDelete, relativePath, code, handlers...)
+}
+
+// err 汇总注册过程中的全部错误
+func (r *permissionRouter) err() error {
+	return errors.Join(r.errs...)
+}
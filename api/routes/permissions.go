@@ -0,0 +1,41 @@
+package routes
+
+import "useradmin/api/services"
+
+// permissionDefinitions 系统内置权限，启动时写入权限表。
+// 接口只能要求这里声明过的权限，新增接口时先在这里声明
+var permissionDefinitions = []services.PermissionDefinition{
+	{Code: "user:list", Name: "用户列表", Description: "查看用户列表"},
+	{Code: "user:create", Name: "创建用户", Description: "创建新用户"},
+	{Code: "user:update", Name: "更新用户", Description: "更新用户信息"},
+	{Code: "user:delete", Name: "删除用户", Description: "删除用户"},
+	{Code: "role:list", Name: "角色列表", Description: "查看角色列表"},
+	{Code: "role:create", Name: "创建角色", Description: "创建新角色"},
+	{Code: "role:update", Name: "更新角色", Description: "更新角色信息"},
+	{Code: "role:delete", Name: "删除角色", Description: "删除角色"},
+	{Code: "log:list", Name: "日志查看", Description: "查看系统日志"},
+	{Code: "log:export", Name: "日志导出", Description: "导出系统日志为 CSV、Excel 或 NDJSON 文件"},
+	{Code: "log:manage", Name: "日志清理", Description: "查看、执行日志归档清理任务，下载归档文件"},
+	{Code: "audit:list", Name: "审计记录", Description: "查看业务操作的审计记录"},
+	{Code: "product:list", Name: "商品列表", Description: "查看商品列表"},
+	{Code: "product:create", Name: "创建商品", Description: "创建新商品"},
+	{Code: "product:update", Name: "更新商品", Description: "更新商品信息"},
+	{Code: "product:delete", Name: "删除商品", Description: "删除商品"},
+	{Code: "product:status", Name: "商品上下架", Description: "商品上架和下架操作"},
+	{Code: "permission:create", Name: "创建权限", Description: "创建新权限"},
+	{Code: "permission:update", Name: "更新权限", Description: "更新权限信息"},
+	{Code: "permission:delete", Name: "删除权限", Description: "删除权限"},
+	{Code: "department:list", Name: "部门列表", Description: "查看部门列表"},
+	{Code: "department:create", Name: "创建部门", Description: "创建新部门"},
+	{Code: "department:update", Name: "更新部门", Description: "更新部门信息"},
+	{Code: "department:delete", Name: "删除部门", Description: "删除部门"},
+	{Code: "tenant:list", Name: "租户列表", Description: "查看租户列表（平台管理员）"},
+	{Code: "tenant:create", Name: "创建租户", Description: "创建新租户（平台管理员）"},
+	{Code: "tenant:update", Name: "更新租户", Description: "更新、停用租户（平台管理员）"},
+	{Code: "policy:list", Name: "策略列表", Description: "查看授权策略，试运行授权判断"},
+	{Code: "policy:create", Name: "创建策略", Description: "创建授权策略"},
+	{Code: "policy:update", Name: "更新策略", Description: "更新、启用、停用授权策略"},
+	{Code: "policy:delete", Name: "删除策略", Description: "删除授权策略"},
+	{Code: "product:*", Name: "商品管理(全部)", Description: "商品模块的全部权限"},
+	{Code: "*:list", Name: "只读(全部)", Description: "所有模块的列表查看权限"},
+}
@@ -4,53 +4,124 @@ import (
 	"github.com/gin-gonic/gin"
 	"useradmin/api/controllers"
 	"useradmin/api/middleware"
+	"useradmin/api/services"
 )
 
-func SetupRoutes(api *gin.RouterGroup) {
+// SetupRoutes 注册所有路由。需要权限的接口通过 permissionRouter 注册，
+// 同时登记到权限注册表；接口使用了未声明的权限时返回错误，服务不应启动
+func SetupRoutes(api *gin.RouterGroup) error {
+	if err := services.DefinePermissions(permissionDefinitions...); err != nil {
+		return err
+	}
+
 	// 公开接口
 	api.POST("/login", controllers.Login)
-	
+	api.POST("/token/refresh", controllers.RefreshToken)
+	api.POST("/login/mfa", controllers.LoginMFA)
+	api.POST("/login/mfa/enroll", controllers.LoginMFAEnroll)
+	api.POST("/login/mfa/activate", controllers.LoginMFAActivate)
+
 	// 需要认证的路由
 	auth := api.Group("/")
 	auth.Use(middleware.JWTAuth())
 	{
 		// 用户信息
 		auth.GET("/user/info", controllers.GetUserInfo)
+		auth.POST("/logout", controllers.Logout)
+		auth.PUT("/user/password", controllers.ChangePassword)
 
-		// 用户管理
-		auth.GET("/users", middleware.CheckPermission("user:list"), controllers.GetUserList)
-		auth.GET("/users/:id", middleware.CheckPermission("user:list"), controllers.GetUserDetail)
-		auth.POST("/users", middleware.CheckPermission("user:create"), controllers.CreateUser)
-		auth.PUT("/users/:id", middleware.CheckPermission("user:update"), controllers.UpdateUser)
-		auth.DELETE("/users/:id", middleware.CheckPermission("user:delete"), controllers.DeleteUser)
-
-		// 角色管理
-		auth.GET("/roles", middleware.CheckPermission("role:list"), controllers.GetRoles)
-		auth.POST("/roles", middleware.CheckPermission("role:create"), controllers.CreateRole)
-		auth.PUT("/roles/:id", middleware.CheckPermission("role:update"), controllers.UpdateRole)
-		auth.DELETE("/roles/:id", middleware.CheckPermission("role:delete"), controllers.DeleteRole)
-		auth.GET("/roles/:id/permissions", middleware.CheckPermission("role:update"), controllers.GetRolePermissions)
-		auth.PUT("/roles/:id/permissions", middleware.CheckPermission("role:update"), controllers.UpdateRolePermissions)
-
-		// 权限管理
-		auth.GET("/permissions", middleware.CheckPermission("role:list"), controllers.GetPermissions)
-		auth.POST("/permissions", middleware.CheckPermission("role:create"), controllers.CreatePermission)
-		auth.PUT("/permissions/:id", middleware.CheckPermission("role:update"), controllers.UpdatePermission)
-		auth.DELETE("/permissions/:id", middleware.CheckPermission("role:delete"), controllers.DeletePermission)
-
-		// 日志查询
-		auth.GET("/logs", middleware.CheckPermission("log:list"), controllers.GetLogs)
-		auth.GET("/logs/types", middleware.CheckPermission("log:list"), controllers.GetLogTypes)
-		auth.GET("/logs/stats", middleware.CheckPermission("log:list"), controllers.GetLogStats)
-
-		// 商品管理
-		auth.GET("/products", middleware.CheckPermission("product:list"), controllers.GetProducts)
-		auth.POST("/products", middleware.CheckPermission("product:create"), controllers.CreateProduct)
-		auth.GET("/products/:id", middleware.CheckPermission("product:list"), controllers.GetProduct)
-		auth.PUT("/products/:id", middleware.CheckPermission("product:update"), controllers.UpdateProduct)
-		auth.DELETE("/products/:id", middleware.CheckPermission("product:delete"), controllers.DeleteProduct)
-
-		// 文件上传
-		auth.POST("/upload/image", middleware.CheckPermission("product:update"), controllers.UploadImage)
+		// 两步验证
+		auth.POST("/user/mfa/setup", controllers.SetupMFA)
+		auth.POST("/user/mfa/enable", controllers.EnableMFA)
+		auth.POST("/user/mfa/disable", controllers.DisableMFA)
+		auth.POST("/user/mfa/recovery-codes", controllers.RegenerateRecoveryCodes)
 	}
-} 
\ No newline at end of file
+
+	perm := &permissionRouter{group: auth}
+
+	// 用户管理
+	perm.GET("/users", "user:list", controllers.GetUserList)
+	perm.GET("/users/:id", "user:list", controllers.GetUserDetail)
+	perm.POST("/users", "user:create", controllers.CreateUser)
+	perm.PUT("/users/:id", "user:update", controllers.UpdateUser)
+	perm.DELETE("/users/:id", "user:delete", controllers.DeleteUser)
+	perm.GET("/users/:id/roles", "user:list", controllers.GetUserRoles)
+	perm.PUT("/users/:id/roles", "user:update", controllers.UpdateUserRoles)
+	perm.POST("/users/:id/roles", "user:update", controllers.AssignUserRole)
+	perm.DELETE("/users/:id/roles/:role_id", "user:update", controllers.UnassignUserRole)
+	perm.POST("/users/:id/unlock", "user:update", controllers.UnlockUser)
+	perm.POST("/users/:id/mfa/reset", "user:update", controllers.ResetUserMFA)
+	perm.GET("/users/:id/effective-permissions", "user:list", controllers.GetUserEffectivePermissions)
+	perm.POST("/authz/check", "user:list", controllers.CheckAuthorization)
+
+	// 角色管理
+	perm.GET("/roles", "role:list", controllers.GetRoles)
+	perm.POST("/roles", "role:create", controllers.CreateRole)
+	perm.PUT("/roles/:id", "role:update", controllers.UpdateRole)
+	perm.DELETE("/roles/:id", "role:delete", controllers.DeleteRole)
+	perm.GET("/roles/:id/permissions", "role:update", controllers.GetRolePermissions)
+	perm.PUT("/roles/:id/permissions", "role:update", controllers.UpdateRolePermissions)
+	perm.POST("/roles/:id/permissions", "role:update", controllers.GrantRolePermission)
+	perm.DELETE("/roles/:id/permissions/:permission_id", "role:update", controllers.RevokeRolePermission)
+	perm.PUT("/roles/:id/parents", "role:update", controllers.UpdateRoleParents)
+	perm.GET("/roles/:id/effective-permissions", "role:list", controllers.GetRoleEffectivePermissions)
+
+	// 即将到期的临时授权
+	perm.GET("/grants/expiring", "role:list", controllers.GetExpiringGrants)
+
+	// 部门管理
+	perm.GET("/departments", "department:list", controllers.GetDepartments)
+	perm.POST("/departments", "department:create", controllers.CreateDepartment)
+	perm.PUT("/departments/:id", "department:update", controllers.UpdateDepartment)
+	perm.DELETE("/departments/:id", "department:delete", controllers.DeleteDepartment)
+
+	// 权限管理，编辑角色时需要权限列表，因此查看使用 role:list
+	perm.GET("/permissions", "role:list", controllers.GetPermissions)
+	perm.GET("/permissions/routes", "role:list", controllers.GetRoutePermissions)
+	// 权限定义为所有租户共用，只有平台管理员可以修改
+	perm.POST("/permissions", "permission:create", middleware.RequirePlatform(), controllers.CreatePermission)
+	perm.PUT("/permissions/:id", "permission:update", middleware.RequirePlatform(), controllers.UpdatePermission)
+	perm.DELETE("/permissions/:id", "permission:delete", middleware.RequirePlatform(), controllers.DeletePermission)
+
+	// 授权策略，在角色权限之外按属性放行或拒绝
+	perm.GET("/policies", "policy:list", controllers.GetPolicies)
+	perm.POST("/policies", "policy:create", controllers.CreatePolicy)
+	perm.PUT("/policies/:id", "policy:update", controllers.UpdatePolicy)
+	perm.DELETE("/policies/:id", "policy:delete", controllers.DeletePolicy)
+	perm.POST("/policies/explain", "policy:list", controllers.ExplainPolicy)
+
+	// 租户管理（平台管理员）
+	perm.GET("/tenants", "tenant:list", middleware.RequirePlatform(), controllers.GetTenants)
+	perm.POST("/tenants", "tenant:create", middleware.RequirePlatform(), controllers.CreateTenant)
+	perm.PUT("/tenants/:id", "tenant:update", middleware.RequirePlatform(), controllers.UpdateTenant)
+
+	// 日志查询
+	// 查询结果本身就是日志，不再记录响应内容
+	perm.GET("/logs", "log:list", middleware.SkipLogBody(), controllers.GetLogs)
+	perm.GET("/logs/export", "log:export", middleware.SkipLogBody(), controllers.ExportLogs)
+	perm.GET("/logs/types", "log:list", middleware.SkipLogBody(), controllers.GetLogTypes)
+	perm.GET("/logs/stats", "log:list", middleware.SkipLogBody(), controllers.GetLogStats)
+
+	// 审计记录，记录业务数据的变更，与请求日志分开
+	perm.GET("/audit", "audit:list", middleware.SkipLogBody(), controllers.GetAuditEvents)
+
+	// 商品管理
+	perm.GET("/products", "product:list", controllers.GetProducts)
+	perm.POST("/products", "product:create", controllers.CreateProduct)
+	perm.GET("/products/:id", "product:list", controllers.GetProduct)
+	perm.PUT("/products/:id", "product:update", controllers.UpdateProduct)
+	perm.PUT("/products/:id/status", "product:status", controllers.UpdateProductStatus)
+	perm.DELETE("/products/:id", "product:delete", controllers.DeleteProduct)
+
+	// 系统状态
+	perm.GET("/system/permission-cache", "role:list", middleware.RequirePlatform(), controllers.GetPermissionCacheStats)
+	perm.GET("/system/log-writer", "log:list", middleware.RequirePlatform(), controllers.GetLogWriterStats)
+	perm.GET("/system/log-retention", "log:manage", middleware.RequirePlatform(), controllers.GetLogRetention)
+	perm.POST("/system/log-retention/run", "log:manage", middleware.RequirePlatform(), controllers.RunLogRetention)
+	perm.GET("/system/log-retention/archives/:name", "log:manage", middleware.RequirePlatform(), middleware.SkipLogBody(), controllers.DownloadLogArchive)
+
+	// 文件上传
+	perm.POST("/upload/image", "product:update", middleware.SkipLogBody(), controllers.UploadImage)
+
+	return perm.err()
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"gorm.io/gorm"
+	"useradmin/api/models"
+)
+
+// 审计事件的目标类型
+const (
+	AuditTargetUser       = "user"
+	AuditTargetRole       = "role"
+	AuditTargetPermission = "permission"
+	AuditTargetProduct    = "product"
+	AuditTargetPolicy     = "policy"
+	AuditTargetDepartment = "department"
+	AuditTargetTenant     = "tenant"
+)
+
+// 审计事件类型，格式为 目标类型.动作
+const (
+	AuditUserCreated      = "user.created"
+	AuditUserUpdated      = "user.updated"
+	AuditUserDeleted      = "user.deleted"
+	AuditUserRolesChanged = "user.roles_changed"
+	AuditUserUnlocked     = "user.unlocked"
+	AuditUserMFAReset     = "user.mfa_reset"
+
+	AuditRoleCreated            = "role.created"
+	AuditRoleUpdated            = "role.updated"
+	AuditRoleDeleted            = "role.deleted"
+	AuditRolePermissionsChanged = "role.permissions_changed"
+	AuditRoleParentsChanged     = "role.parents_changed"
+
+	AuditPermissionCreated = "permission.created"
+	AuditPermissionUpdated = "permission.updated"
+	AuditPermissionDeleted = "permission.deleted"
+
+	AuditProductCreated       = "product.created"
+	AuditProductUpdated       = "product.updated"
+	AuditProductStatusChanged = "product.status_changed"
+	AuditProductDeleted       = "product.deleted"
+
+	AuditPolicyCreated = "policy.created"
+	AuditPolicyUpdated = "policy.updated"
+	AuditPolicyDeleted = "policy.deleted"
+
+	AuditDepartmentCreated = "department.created"
+	AuditDepartmentUpdated = "department.updated"
+	AuditDepartmentDeleted = "department.deleted"
+
+	AuditTenantCreated = "tenant.created"
+	AuditTenantUpdated = "tenant.updated"
+
+	AuditGrantExpired = "grant.expired"
+)
+
+// auditIgnoredFields 不记录变更的字段，每次修改都会变化，没有审计价值
+var auditIgnoredFields = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+}
+
+// FieldChange 一个字段的变更，创建时 Before 为 nil，删除时 After 为 nil。
+// 嵌套对象的字段以 . 连接，例如 department.name
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before any    `json:"before"`
+	After  any    `json:"after"`
+}
+
+// AuditEvent 待记录的审计事件
+type AuditEvent struct {
+	TenantID   uint
+	RequestID  string
+	Type       string
+	ActorID    uint
+	ActorName  string
+	TargetType string
+	TargetID   uint
+	IP         string
+	Changes    []FieldChange
+}
+
+// DiffFields 比较变更前后的对象（按 JSON 序列化结果），返回发生变化的字段，按字段名排序。
+// 传入的应是接口的响应结构（dto），这样密码哈希等内部字段不会进入审计记录
+func DiffFields(before, after any) []FieldChange {
+	b, a := flattenJSON(before), flattenJSON(after)
+
+	fields := make([]string, 0, len(b)+len(a))
+	for field := range b {
+		fields = append(fields, field)
+	}
+	for field := range a {
+		if _, ok := b[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	changes := []FieldChange{}
+	for _, field := range fields {
+		bv, av := b[field], a[field]
+		if reflect.DeepEqual(bv, av) {
+			continue
+		}
+		changes = append(changes, FieldChange{Field: field, Before: bv, After: av})
+	}
+	return changes
+}
+
+// flattenJSON 将对象序列化后展开为 字段路径 -> 值，数组整体作为一个值
+func flattenJSON(v any) map[string]any {
+	fields := make(map[string]any)
+	if v == nil {
+		return fields
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fields
+	}
+	var decoded any
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return fields
+	}
+	var walk func(prefix string, value any)
+	walk = func(prefix string, value any) {
+		obj, ok := value.(map[string]any)
+		if !ok || len(obj) == 0 {
+			if prefix != "" {
+				fields[prefix] = value
+			}
+			return
+		}
+		for key, child := range obj {
+			if prefix == "" && auditIgnoredFields[key] {
+				continue
+			}
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+			walk(path, child)
+		}
+	}
+	walk("", decoded)
+	return fields
+}
+
+// RecordAudit 写入审计事件，tx 携带租户时事件归属该租户
+func RecordAudit(tx *gorm.DB, event AuditEvent) error {
+	changes := event.Changes
+	if changes == nil {
+		changes = []FieldChange{}
+	}
+	raw, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.AuditEvent{
+		TenantID:   event.TenantID,
+		RequestID:  event.RequestID,
+		Type:       event.Type,
+		ActorID:    event.ActorID,
+		ActorName:  event.ActorName,
+		TargetType: event.TargetType,
+		TargetID:   strconv.FormatUint(uint64(event.TargetID), 10),
+		Changes:    string(raw),
+		IP:         event.IP,
+	}).Error
+}
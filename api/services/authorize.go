@@ -0,0 +1,275 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	"gorm.io/gorm"
+	"useradmin/api/models"
+)
+
+// 授权判断的原因类型
+const (
+	ReasonTenantDisabled = "tenant_disabled" // 租户已停用
+	ReasonUserDisabled   = "user_disabled"   // 用户已被禁用
+	ReasonSuperuser      = "superuser"       // 超级管理员拥有所有权限
+	ReasonRoleGrant      = "role_grant"      // 角色授予的权限覆盖所需权限
+	ReasonNoGrant        = "no_grant"        // 没有角色授予该权限
+	ReasonPolicyDeny     = "policy_deny"     // 拒绝策略生效
+	ReasonPolicyAllow    = "policy_allow"    // 没有角色授予，但允许策略生效
+)
+
+// AuthzReason 授权判断过程中的一步
+type AuthzReason struct {
+	Type    string
+	Message string
+	// role_grant：覆盖所需权限的授予代码，Wildcard 表示通配或上级权限
+	Grant    string
+	Wildcard bool
+	// role_grant：授予该代码的角色，由 ExplainAuthorization 填充
+	Sources []GrantSource
+	// policy_deny、policy_allow：生效的策略
+	Policy *PolicyMatch
+}
+
+// AuthzDecision 授权结果
+type AuthzDecision struct {
+	Allowed   bool
+	Superuser bool // 超级管理员拥有所有权限，不评估策略
+	Granted   bool // 角色权限（含继承、通配符）是否覆盖所需权限
+	Policy    PolicyResult
+	// 判断过程，最后一项为决定结果的原因
+	Reasons []AuthzReason
+}
+
+// Decisive 返回决定结果的原因
+func (d *AuthzDecision) Decisive() AuthzReason {
+	if len(d.Reasons) == 0 {
+		return AuthzReason{}
+	}
+	return d.Reasons[len(d.Reasons)-1]
+}
+
+// Authorize 判断用户能否执行需要 permission 的操作，CheckPermission 和授权检查接口共用：
+//  1. 租户停用、用户禁用时拒绝
+//  2. 超级管理员直接放行，不评估策略
+//  3. 按角色权限（含继承、通配符）判断，再评估租户的策略：拒绝策略生效时一律拒绝，
+//     角色权限未覆盖时允许策略生效也可放行
+//
+// resource 为空表示尚未加载资源（路由级检查），此时跳过使用了资源属性的策略，由控制器加载资源后
+// 再次调用时评估（见 IsResourcePermission）。drafts 为尚未保存的策略，用于试运行。
+func Authorize(subject *Subject, permission string, resource, env Attributes, drafts ...CompiledPolicy) (*AuthzDecision, error) {
+	decision := &AuthzDecision{Superuser: subject.Superuser}
+	deny := func(reason AuthzReason) (*AuthzDecision, error) {
+		decision.Reasons = append(decision.Reasons, reason)
+		return decision, nil
+	}
+
+	if !subject.TenantActive {
+		return deny(AuthzReason{Type: ReasonTenantDisabled, Message: ErrTenantDisabled.Error()})
+	}
+	if subject.Status != 1 {
+		return deny(AuthzReason{Type: ReasonUserDisabled, Message: "用户已被禁用"})
+	}
+	if subject.Superuser {
+		decision.Allowed = true
+		decision.Reasons = append(decision.Reasons, AuthzReason{Type: ReasonSuperuser, Message: "超级管理员拥有所有权限"})
+		return decision, nil
+	}
+
+	for _, grant := range subject.Permissions {
+		if !MatchPermission(grant, permission) {
+			continue
+		}
+		decision.Granted = true
+		reason := AuthzReason{Type: ReasonRoleGrant, Grant: grant, Message: fmt.Sprintf("角色授予了 %s", grant)}
+		if grant != permission {
+			reason.Wildcard = true
+			reason.Message = fmt.Sprintf("角色授予的 %s 覆盖 %s", grant, permission)
+		}
+		decision.Reasons = append(decision.Reasons, reason)
+	}
+	noGrant := AuthzReason{Type: ReasonNoGrant, Message: fmt.Sprintf("没有角色授予 %s", permission)}
+
+	policies, err := LoadPolicies(subject.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	if len(drafts) > 0 {
+		policies = append(append([]CompiledPolicy(nil), policies...), drafts...)
+	}
+	if resource == nil {
+		policies = withoutResourcePolicies(policies)
+	}
+	decision.Policy = EvaluatePolicies(policies, permission, PolicyContext{
+		Subject:  subject.Attributes(),
+		Resource: resource,
+		Env:      env,
+	})
+	switch {
+	case decision.Policy.Denied():
+		if !decision.Granted {
+			decision.Reasons = append(decision.Reasons, noGrant)
+		}
+		return deny(AuthzReason{
+			Type:    ReasonPolicyDeny,
+			Message: fmt.Sprintf("策略「%s」拒绝", decision.Policy.Decisive.Name),
+			Policy:  decision.Policy.Decisive,
+		})
+	case decision.Policy.Allowed() && !decision.Granted:
+		decision.Reasons = append(decision.Reasons, noGrant, AuthzReason{
+			Type:    ReasonPolicyAllow,
+			Message: fmt.Sprintf("策略「%s」允许", decision.Policy.Decisive.Name),
+			Policy:  decision.Policy.Decisive,
+		})
+	}
+	decision.Allowed = decision.Granted || decision.Policy.Allowed()
+	if !decision.Allowed {
+		decision.Reasons = append(decision.Reasons, noGrant)
+	}
+	return decision, nil
+}
+
+// withoutResourcePolicies 去掉条件中使用了资源属性的策略
+func withoutResourcePolicies(policies []CompiledPolicy) []CompiledPolicy {
+	result := make([]CompiledPolicy, 0, len(policies))
+	for _, p := range policies {
+		if !p.UsesResource() {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// ExplainAuthorization 与 Authorize 相同，并为角色授予的原因补充授予该权限的角色（含继承来源）
+func ExplainAuthorization(tx *gorm.DB, subject *Subject, permission string, resource, env Attributes, drafts ...CompiledPolicy) (*AuthzDecision, error) {
+	decision, err := Authorize(subject, permission, resource, env, drafts...)
+	if err != nil {
+		return nil, err
+	}
+	if !decision.Granted || decision.Superuser {
+		return decision, nil
+	}
+
+	grants, err := ResolveSubjectGrants(tx, subject)
+	if err != nil {
+		return nil, err
+	}
+	byCode := make(map[string][]GrantSource, len(grants))
+	for _, g := range grants {
+		byCode[g.Permission.Code] = g.Sources
+	}
+	for i := range decision.Reasons {
+		if decision.Reasons[i].Type == ReasonRoleGrant {
+			decision.Reasons[i].Sources = byCode[decision.Reasons[i].Grant]
+		}
+	}
+	return decision, nil
+}
+
+// GrantSource 授予权限的角色：用户直接拥有的角色，继承时 From 为实际授予该权限的祖先角色
+type GrantSource struct {
+	Role      models.Role
+	Inherited bool
+	From      []models.Role
+}
+
+// PermissionGrant 用户获得的一个权限代码及其全部来源
+type PermissionGrant struct {
+	Permission models.Permission
+	Wildcard   bool
+	Sources    []GrantSource
+}
+
+// ResolveSubjectGrants 按权限代码汇总用户通过各个角色（含继承）获得的权限
+func ResolveSubjectGrants(tx *gorm.DB, subject *Subject) ([]PermissionGrant, error) {
+	grants := make(map[string]*PermissionGrant)
+	add := func(p models.Permission, source GrantSource) {
+		g, ok := grants[p.Code]
+		if !ok {
+			g = &PermissionGrant{Permission: p, Wildcard: IsWildcardPermission(p.Code)}
+			grants[p.Code] = g
+		}
+		g.Sources = append(g.Sources, source)
+	}
+
+	for _, roleID := range subject.RoleIDs {
+		set, err := ResolveRolePermissions(tx, roleID)
+		if err != nil {
+			return nil, err
+		}
+		role := set.Role
+		role.Permissions = nil
+		role.Parents = nil
+		for _, p := range set.Direct {
+			add(p, GrantSource{Role: role})
+		}
+		for _, p := range set.Inherited {
+			add(p.Permission, GrantSource{Role: role, Inherited: true, From: p.From})
+		}
+	}
+
+	result := make([]PermissionGrant, 0, len(grants))
+	for _, g := range grants {
+		result = append(result, *g)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Permission.Code < result[j].Permission.Code })
+	return result, nil
+}
+
+// EffectivePermissions 用户的有效权限及其来源
+type EffectivePermissions struct {
+	Subject        *Subject
+	SuperuserFlag  bool          // 用户本身带有超级管理员标记
+	SuperuserRoles []models.Role // 带有超级管理员标记的直接角色
+	Roles          []models.Role // 直接拥有的角色
+	InheritedRoles []models.Role // 通过继承获得的祖先角色
+	Grants         []PermissionGrant
+	// 展开通配符后的具体权限代码，超级管理员为全部权限
+	Permissions []string
+}
+
+// ResolveEffectivePermissions 解析用户的有效权限，tx 应限定在用户所属租户
+func ResolveEffectivePermissions(tx *gorm.DB, user models.User) (*EffectivePermissions, error) {
+	subject, err := LoadSubject(user.Username)
+	if err != nil {
+		return nil, err
+	}
+	result := &EffectivePermissions{
+		Subject:        subject,
+		SuperuserFlag:  user.IsSuperuser,
+		SuperuserRoles: []models.Role{},
+		Roles:          []models.Role{},
+		InheritedRoles: []models.Role{},
+	}
+
+	if len(subject.EffectiveRoleIDs) > 0 {
+		var roles []models.Role
+		if err := tx.Where("id IN ?", subject.EffectiveRoleIDs).Order("id").Find(&roles).Error; err != nil {
+			return nil, err
+		}
+		for _, role := range roles {
+			if !subject.HasRole(role.ID) {
+				result.InheritedRoles = append(result.InheritedRoles, role)
+				continue
+			}
+			result.Roles = append(result.Roles, role)
+			if role.IsSuperuser {
+				result.SuperuserRoles = append(result.SuperuserRoles, role)
+			}
+		}
+	}
+
+	if result.Grants, err = ResolveSubjectGrants(tx, subject); err != nil {
+		return nil, err
+	}
+
+	grants := subject.Permissions
+	if subject.Superuser {
+		grants = []string{permissionWildcard}
+	}
+	if result.Permissions, err = ExpandPermissions(grants); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
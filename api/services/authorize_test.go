@@ -0,0 +1,416 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"useradmin/api/models"
+)
+
+// seedPolicies 直接写入租户的策略缓存，Authorize 不会查询数据库
+func seedPolicies(t *testing.T, tenantID uint, policies ...CompiledPolicy) {
+	t.Helper()
+	tenantPolicies.mu.Lock()
+	tenantPolicies.entries[tenantID] = policyCacheEntry{policies: policies, expiresAt: time.Now().Add(time.Hour)}
+	tenantPolicies.mu.Unlock()
+	t.Cleanup(func() { InvalidatePolicies(tenantID) })
+}
+
+func TestMatchPermission(t *testing.T) {
+	tests := []struct {
+		grant, required string
+		want            bool
+	}{
+		{"user:list", "user:list", true},
+		{"user:list", "user:create", false},
+		{"*", "user:list", true},
+		{"*", "product:image:upload", true},
+		{"*:list", "user:list", true},
+		{"*:list", "user:create", false},
+		{"*:list", "product:image:list", false},
+		{"product:*", "product:update", true},
+		{"product:*", "product:image:upload", true},
+		{"product:*", "user:update", false},
+		{"product:image", "product:image:upload", true},
+		{"product:image:upload", "product:image", false},
+		{"user:*:view", "user:profile:view", true},
+		{"user:*:view", "user:profile:edit", false},
+	}
+	for _, tt := range tests {
+		if got := MatchPermission(tt.grant, tt.required); got != tt.want {
+			t.Errorf("MatchPermission(%q, %q) = %v, want %v", tt.grant, tt.required, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateCondition(t *testing.T) {
+	ctx := PolicyContext{
+		Subject: Attributes{
+			"id":            uint(7),
+			"department_id": uint(3),
+			"role_ids":      []uint{1, 2},
+			"roles":         []string{"editor", "auditor"},
+			"username":      "alice",
+		},
+		Resource: Attributes{
+			"owner_id":      uint(7),
+			"department_id": uint(4),
+			"status":        1,
+			"name":          "测试商品",
+		},
+		Env: Attributes{
+			"ip":      "10.1.2.3",
+			"hour":    10,
+			"time":    "09:30",
+			"weekday": 6,
+		},
+	}
+
+	tests := []struct {
+		name string
+		cond PolicyCondition
+		want bool
+	}{
+		{"eq 数值类型不同", PolicyCondition{Attr: "resource.status", Op: PolicyOpEq, Value: float64(1)}, true},
+		{"eq 不相等", PolicyCondition{Attr: "resource.status", Op: PolicyOpEq, Value: 0}, false},
+		{"ne", PolicyCondition{Attr: "subject.username", Op: PolicyOpNe, Value: "bob"}, true},
+		{"gt", PolicyCondition{Attr: "env.hour", Op: PolicyOpGt, Value: 9}, true},
+		{"lte", PolicyCondition{Attr: "env.hour", Op: PolicyOpLte, Value: 9}, false},
+		{"lt 字符串", PolicyCondition{Attr: "env.time", Op: PolicyOpLt, Value: "18:00"}, true},
+		{"gt 类型不同不满足", PolicyCondition{Attr: "env.hour", Op: PolicyOpGt, Value: "9"}, false},
+		{"in", PolicyCondition{Attr: "env.weekday", Op: PolicyOpIn, Value: []any{float64(0), float64(6)}}, true},
+		{"not_in", PolicyCondition{Attr: "env.weekday", Op: PolicyOpNotIn, Value: []any{0, 6}}, false},
+		{"in 值不是列表", PolicyCondition{Attr: "env.weekday", Op: PolicyOpIn, Value: 6}, false},
+		{"contains 列表", PolicyCondition{Attr: "subject.roles", Op: PolicyOpContains, Value: "auditor"}, true},
+		{"contains 数值列表", PolicyCondition{Attr: "subject.role_ids", Op: PolicyOpContains, Value: float64(2)}, true},
+		{"not_contains 列表", PolicyCondition{Attr: "subject.roles", Op: PolicyOpNotContains, Value: "admin"}, true},
+		{"contains 字符串", PolicyCondition{Attr: "resource.name", Op: PolicyOpContains, Value: "测试"}, true},
+		{"between 含两端", PolicyCondition{Attr: "env.hour", Op: PolicyOpBetween, Value: []any{10, 18}}, true},
+		{"between 字符串", PolicyCondition{Attr: "env.time", Op: PolicyOpBetween, Value: []any{"09:00", "18:00"}}, true},
+		{"not_between", PolicyCondition{Attr: "env.hour", Op: PolicyOpNotBetween, Value: []any{9, 18}}, false},
+		{"between 边界数量错误", PolicyCondition{Attr: "env.hour", Op: PolicyOpBetween, Value: []any{9}}, false},
+		{"cidr 单个网段", PolicyCondition{Attr: "env.ip", Op: PolicyOpCIDR, Value: "10.0.0.0/8"}, true},
+		{"cidr 多个网段", PolicyCondition{Attr: "env.ip", Op: PolicyOpCIDR, Value: []any{"192.168.0.0/16", "10.1.0.0/16"}}, true},
+		{"cidr 不在网段内", PolicyCondition{Attr: "env.ip", Op: PolicyOpCIDR, Value: "192.168.0.0/16"}, false},
+		{"ref 相等", PolicyCondition{Attr: "resource.owner_id", Op: PolicyOpEq, Ref: "subject.id"}, true},
+		{"ref 不相等", PolicyCondition{Attr: "resource.department_id", Op: PolicyOpEq, Ref: "subject.department_id"}, false},
+		{"ref 不存在", PolicyCondition{Attr: "resource.owner_id", Op: PolicyOpEq, Ref: "subject.manager_id"}, false},
+		{"属性不存在", PolicyCondition{Attr: "resource.created_by", Op: PolicyOpEq, Value: 7}, false},
+		{"属性不存在时 ne 也不满足", PolicyCondition{Attr: "resource.created_by", Op: PolicyOpNe, Value: 7}, false},
+		{"未知命名空间", PolicyCondition{Attr: "request.ip", Op: PolicyOpEq, Value: "10.1.2.3"}, false},
+		{"exists true", PolicyCondition{Attr: "resource.owner_id", Op: PolicyOpExists, Value: true}, true},
+		{"exists false", PolicyCondition{Attr: "resource.created_by", Op: PolicyOpExists, Value: false}, true},
+		{"exists 属性存在时 false 不满足", PolicyCondition{Attr: "resource.owner_id", Op: PolicyOpExists, Value: false}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := evaluateCondition(tt.cond, ctx).Matched; got != tt.want {
+				t.Errorf("evaluateCondition(%+v) = %v, want %v", tt.cond, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluatePolicies(t *testing.T) {
+	ctx := PolicyContext{Env: Attributes{"hour": 22, "ip": "10.1.2.3"}}
+	night := PolicyCondition{Attr: "env.hour", Op: PolicyOpGte, Value: 20}
+	office := PolicyCondition{Attr: "env.ip", Op: PolicyOpCIDR, Value: "10.0.0.0/8"}
+	remote := PolicyCondition{Attr: "env.ip", Op: PolicyOpCIDR, Value: "192.168.0.0/16"}
+
+	tests := []struct {
+		name         string
+		policies     []CompiledPolicy
+		permission   string
+		wantEffect   string
+		wantDecisive uint
+		wantApplied  int
+	}{
+		{
+			name:       "没有策略",
+			permission: "user:update",
+		},
+		{
+			name: "允许策略生效",
+			policies: []CompiledPolicy{
+				{ID: 1, Effect: models.PolicyEffectAllow, Permission: "user:update", Conditions: []PolicyCondition{office}},
+			},
+			permission:   "user:update",
+			wantEffect:   models.PolicyEffectAllow,
+			wantDecisive: 1,
+			wantApplied:  1,
+		},
+		{
+			name: "拒绝优先于允许",
+			policies: []CompiledPolicy{
+				{ID: 1, Effect: models.PolicyEffectAllow, Permission: "user:update", Conditions: []PolicyCondition{office}},
+				{ID: 2, Effect: models.PolicyEffectDeny, Permission: "user:update", Conditions: []PolicyCondition{night}},
+			},
+			permission:   "user:update",
+			wantEffect:   models.PolicyEffectDeny,
+			wantDecisive: 2,
+			wantApplied:  2,
+		},
+		{
+			name: "条件不全满足的拒绝策略不生效",
+			policies: []CompiledPolicy{
+				{ID: 1, Effect: models.PolicyEffectDeny, Permission: "user:update", Conditions: []PolicyCondition{night, remote}},
+				{ID: 2, Effect: models.PolicyEffectAllow, Permission: "user:update"},
+			},
+			permission:   "user:update",
+			wantEffect:   models.PolicyEffectAllow,
+			wantDecisive: 2,
+			wantApplied:  2,
+		},
+		{
+			name: "多个拒绝策略时取第一个",
+			policies: []CompiledPolicy{
+				{ID: 1, Effect: models.PolicyEffectAllow, Permission: "user:update"},
+				{ID: 2, Effect: models.PolicyEffectDeny, Permission: "user:*"},
+				{ID: 3, Effect: models.PolicyEffectDeny, Permission: "*"},
+			},
+			permission:   "user:update",
+			wantEffect:   models.PolicyEffectDeny,
+			wantDecisive: 2,
+			wantApplied:  3,
+		},
+		{
+			name: "通配策略覆盖下级权限",
+			policies: []CompiledPolicy{
+				{ID: 1, Effect: models.PolicyEffectDeny, Permission: "product:*", Conditions: []PolicyCondition{night}},
+			},
+			permission:   "product:image:upload",
+			wantEffect:   models.PolicyEffectDeny,
+			wantDecisive: 1,
+			wantApplied:  1,
+		},
+		{
+			name: "不适用的策略不参与评估",
+			policies: []CompiledPolicy{
+				{ID: 1, Effect: models.PolicyEffectDeny, Permission: "*:list"},
+				{ID: 2, Effect: models.PolicyEffectDeny, Permission: "product:*"},
+				{ID: 3, Effect: models.PolicyEffectDeny, Permission: "user:update:extra"},
+			},
+			permission: "user:update",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := EvaluatePolicies(tt.policies, tt.permission, ctx)
+			if result.Effect != tt.wantEffect {
+				t.Fatalf("Effect = %q, want %q", result.Effect, tt.wantEffect)
+			}
+			if len(result.Policies) != tt.wantApplied {
+				t.Errorf("评估了 %d 个策略, want %d", len(result.Policies), tt.wantApplied)
+			}
+			if tt.wantEffect == "" {
+				if result.Decisive != nil {
+					t.Errorf("Decisive = %+v, want nil", result.Decisive)
+				}
+				return
+			}
+			if result.Decisive == nil || result.Decisive.ID != tt.wantDecisive {
+				t.Errorf("Decisive = %+v, want policy %d", result.Decisive, tt.wantDecisive)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	const tenantID = 900001
+	office := PolicyCondition{Attr: "env.ip", Op: PolicyOpCIDR, Value: "10.0.0.0/8"}
+	ownDepartment := PolicyCondition{Attr: "resource.department_id", Op: PolicyOpNe, Ref: "subject.department_id"}
+	seedPolicies(t, tenantID,
+		CompiledPolicy{ID: 1, Name: "办公网可查看日志", Effect: models.PolicyEffectAllow, Permission: "log:list", Conditions: []PolicyCondition{office}},
+		CompiledPolicy{ID: 2, Name: "办公网外不能删除", Effect: models.PolicyEffectDeny, Permission: "*:delete",
+			Conditions: []PolicyCondition{{Attr: "env.ip", Op: PolicyOpCIDR, Value: "0.0.0.0/0"}, {Attr: "env.ip", Op: PolicyOpNotIn, Value: []any{"10.1.2.3"}}}},
+		CompiledPolicy{ID: 3, Name: "只能修改本部门用户", Effect: models.PolicyEffectDeny, Permission: "user:update", Conditions: []PolicyCondition{ownDepartment}},
+	)
+
+	subject := func(permissions ...string) *Subject {
+		return &Subject{UserID: 7, Status: 1, TenantID: tenantID, TenantActive: true, DepartmentID: 3, Permissions: permissions}
+	}
+	inOffice := Attributes{"ip": "10.1.2.3"}
+	outside := Attributes{"ip": "192.168.1.5"}
+
+	tests := []struct {
+		name       string
+		subject    *Subject
+		permission string
+		resource   Attributes
+		env        Attributes
+		drafts     []CompiledPolicy
+		want       bool
+		wantReason string
+	}{
+		{
+			name:       "租户停用",
+			subject:    &Subject{Status: 1, TenantID: tenantID, Superuser: true},
+			permission: "user:list",
+			wantReason: ReasonTenantDisabled,
+		},
+		{
+			name:       "用户禁用",
+			subject:    &Subject{Status: 0, TenantID: tenantID, TenantActive: true, Permissions: []string{"*"}},
+			permission: "user:list",
+			wantReason: ReasonUserDisabled,
+		},
+		{
+			name:       "超级管理员不评估拒绝策略",
+			subject:    &Subject{Status: 1, TenantID: tenantID, TenantActive: true, Superuser: true},
+			permission: "user:delete",
+			env:        outside,
+			want:       true,
+			wantReason: ReasonSuperuser,
+		},
+		{
+			name:       "角色精确授予",
+			subject:    subject("user:list"),
+			permission: "user:list",
+			want:       true,
+			wantReason: ReasonRoleGrant,
+		},
+		{
+			name:       "角色通配授予",
+			subject:    subject("product:*"),
+			permission: "product:image:upload",
+			want:       true,
+			wantReason: ReasonRoleGrant,
+		},
+		{
+			name:       "没有授予",
+			subject:    subject("product:*"),
+			permission: "user:list",
+			wantReason: ReasonNoGrant,
+		},
+		{
+			name:       "允许策略补充授予",
+			subject:    subject(),
+			permission: "log:list",
+			env:        inOffice,
+			want:       true,
+			wantReason: ReasonPolicyAllow,
+		},
+		{
+			name:       "允许策略条件不满足",
+			subject:    subject(),
+			permission: "log:list",
+			env:        outside,
+			wantReason: ReasonNoGrant,
+		},
+		{
+			name:       "通配拒绝策略覆盖角色授予",
+			subject:    subject("*"),
+			permission: "product:delete",
+			env:        outside,
+			wantReason: ReasonPolicyDeny,
+		},
+		{
+			name:       "拒绝策略条件不满足",
+			subject:    subject("*"),
+			permission: "product:delete",
+			env:        inOffice,
+			want:       true,
+			wantReason: ReasonRoleGrant,
+		},
+		{
+			name:       "路由级检查跳过资源策略",
+			subject:    subject("user:update"),
+			permission: "user:update",
+			env:        inOffice,
+			want:       true,
+			wantReason: ReasonRoleGrant,
+		},
+		{
+			name:       "加载资源后资源策略拒绝",
+			subject:    subject("user:update"),
+			permission: "user:update",
+			resource:   Attributes{"department_id": uint(4)},
+			env:        inOffice,
+			wantReason: ReasonPolicyDeny,
+		},
+		{
+			name:       "加载资源后资源策略不满足",
+			subject:    subject("user:update"),
+			permission: "user:update",
+			resource:   Attributes{"department_id": uint(3)},
+			env:        inOffice,
+			want:       true,
+			wantReason: ReasonRoleGrant,
+		},
+		{
+			name:       "草稿拒绝策略参与评估",
+			subject:    subject("user:list"),
+			permission: "user:list",
+			drafts:     []CompiledPolicy{{Name: "草稿", Effect: models.PolicyEffectDeny, Permission: "user:*"}},
+			wantReason: ReasonPolicyDeny,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := Authorize(tt.subject, tt.permission, tt.resource, tt.env, tt.drafts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.Allowed != tt.want {
+				t.Errorf("Allowed = %v, want %v (reasons %+v)", decision.Allowed, tt.want, decision.Reasons)
+			}
+			if got := decision.Decisive().Type; got != tt.wantReason {
+				t.Errorf("Decisive().Type = %q, want %q", got, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestValidatePolicyScope(t *testing.T) {
+	byOwner := []PolicyCondition{{Attr: "resource.owner_id", Op: PolicyOpNe, Ref: "subject.id"}}
+	tests := []struct {
+		name    string
+		policy  CompiledPolicy
+		wantErr bool
+	}{
+		{"不使用资源属性", CompiledPolicy{Effect: models.PolicyEffectAllow, Permission: "*", Conditions: []PolicyCondition{{Attr: "env.hour", Op: PolicyOpLt, Value: 18}}}, false},
+		{"资源权限的拒绝策略", CompiledPolicy{Effect: models.PolicyEffectDeny, Permission: "product:update", Conditions: byOwner}, false},
+		{"引用资源属性的拒绝策略", CompiledPolicy{Effect: models.PolicyEffectDeny, Permission: "user:list",
+			Conditions: []PolicyCondition{{Attr: "subject.id", Op: PolicyOpEq, Ref: "resource.owner_id"}}}, true},
+		{"允许策略使用资源属性", CompiledPolicy{Effect: models.PolicyEffectAllow, Permission: "product:update", Conditions: byOwner}, true},
+		{"通配权限的拒绝策略使用资源属性", CompiledPolicy{Effect: models.PolicyEffectDeny, Permission: "product:*", Conditions: byOwner}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePolicyScope(tt.policy)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidatePolicyScope() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidPolicy) {
+				t.Errorf("error %v is not ErrInvalidPolicy", err)
+			}
+		})
+	}
+}
+
+func TestGuardPolicyGrant(t *testing.T) {
+	admin := &Subject{Permissions: []string{"user:*", "product:list"}}
+	tests := []struct {
+		name   string
+		actor  *Subject
+		policy CompiledPolicy
+		want   error
+	}{
+		{"超级管理员可以创建通配允许策略", &Subject{Superuser: true}, CompiledPolicy{Effect: models.PolicyEffectAllow, Permission: "*"}, nil},
+		{"拒绝策略不授予权限", admin, CompiledPolicy{Effect: models.PolicyEffectDeny, Permission: "*"}, nil},
+		{"全部权限通配", admin, CompiledPolicy{Effect: models.PolicyEffectAllow, Permission: "*"}, ErrPolicyGrant},
+		{"模块通配", admin, CompiledPolicy{Effect: models.PolicyEffectAllow, Permission: "user:*"}, ErrPolicyGrant},
+		{"自己通配拥有的权限", admin, CompiledPolicy{Effect: models.PolicyEffectAllow, Permission: "user:update"}, nil},
+		{"自己拥有的权限", admin, CompiledPolicy{Effect: models.PolicyEffectAllow, Permission: "product:list"}, nil},
+		{"自己没有的权限", admin, CompiledPolicy{Effect: models.PolicyEffectAllow, Permission: "product:delete"}, ErrPolicyGrant},
+		{"没有操作人", nil, CompiledPolicy{Effect: models.PolicyEffectAllow, Permission: "user:list"}, ErrPolicyGrant},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GuardPolicyGrant(tt.actor, tt.policy); got != tt.want {
+				t.Errorf("GuardPolicyGrant() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"time"
+
+	"useradmin/api/config"
+	"useradmin/api/models"
+)
+
+// Subject 权限判断所需的用户信息快照，由 LoadSubject 加载并缓存
+type Subject struct {
+	UserID   uint
+	Username string
+	Status   int
+
+	TenantID       uint
+	TenantActive   bool // 租户处于启用状态
+	TenantPlatform bool // 平台租户的用户可以管理所有租户
+
+	RoleIDs   []uint
+	RoleNames []string
+	// 直接拥有的角色及其继承的全部祖先角色
+	EffectiveRoleIDs []uint
+	Permissions      []string // 所有角色（含继承）权限的并集
+	Superuser        bool     // 用户或其任一角色带有超级管理员标记
+	DepartmentID     uint
+	DataScope        DataScopeRule // 所有角色数据范围的并集
+	// 临时授权最早开始或到期的时间，缓存不能超过该时间
+	GrantsChangeAt time.Time
+}
+
+// HasPermission 判断用户是否拥有指定权限，支持通配符和上级权限
+func (s *Subject) HasPermission(code string) bool {
+	return MatchAnyPermission(s.Permissions, code)
+}
+
+// Allows 判断用户能否执行需要 code 权限的操作，超级管理员拥有所有权限
+func (s *Subject) Allows(code string) bool {
+	return s.Superuser || s.HasPermission(code)
+}
+
+// HasRole 判断用户是否拥有指定角色
+func (s *Subject) HasRole(roleID uint) bool {
+	for _, id := range s.RoleIDs {
+		if id == roleID {
+			return true
+		}
+	}
+	return false
+}
+
+// InheritsRole 判断用户是否直接拥有或通过继承拥有指定角色
+func (s *Subject) InheritsRole(roleID uint) bool {
+	for _, id := range s.EffectiveRoleIDs {
+		if id == roleID {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadSubject 获取用户的权限快照，优先从缓存读取
+func LoadSubject(username string) (*Subject, error) {
+	if subject, ok := permissionCache.get(username); ok {
+		return subject, nil
+	}
+
+	generation := permissionCache.generation.Load()
+	subject, err := loadSubjectFromDB(username)
+	if err != nil {
+		return nil, err
+	}
+	permissionCache.set(username, subject, generation)
+	return subject, nil
+}
+
+func loadSubjectFromDB(username string) (*Subject, error) {
+	var user models.User
+	if err := config.DB.Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, err
+	}
+	// 只有在有效期内的角色和权限参与判断
+	now := time.Now()
+	roles, err := ActiveUserRoles(config.DB, user.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	user.Roles = roles
+
+	subject := &Subject{
+		UserID:       user.ID,
+		Username:     user.Username,
+		Status:       user.Status,
+		TenantID:     user.TenantID,
+		Superuser:    user.IsSuperuser,
+		DepartmentID: user.DepartmentID,
+	}
+
+	var tenant models.Tenant
+	if err := config.DB.First(&tenant, user.TenantID).Error; err == nil {
+		subject.TenantActive = tenant.Status == 1
+		subject.TenantPlatform = tenant.IsPlatform
+	}
+	// 超级管理员标记不通过继承传递，只看用户直接拥有的角色
+	for _, role := range user.Roles {
+		subject.RoleIDs = append(subject.RoleIDs, role.ID)
+		subject.RoleNames = append(subject.RoleNames, role.Name)
+		if role.IsSuperuser {
+			subject.Superuser = true
+		}
+	}
+
+	permissions, effectiveIDs, err := effectivePermissionCodesAt(config.DB, subject.RoleIDs, now)
+	if err != nil {
+		return nil, err
+	}
+	subject.Permissions = permissions
+	subject.EffectiveRoleIDs = effectiveIDs
+	subject.GrantsChangeAt, _ = nextGrantChange(config.DB, user.ID, effectiveIDs, now)
+
+	if err := loadDataScope(config.DB, subject, user.Roles); err != nil {
+		return nil, err
+	}
+	return subject, nil
+}
+
+// RolePermissionCodes 返回多个角色权限代码的并集，roles 需要预加载 Permissions
+func RolePermissionCodes(roles []models.Role) []string {
+	seen := make(map[string]bool)
+	var codes []string
+	for _, role := range roles {
+		for _, p := range role.Permissions {
+			if !seen[p.Code] {
+				seen[p.Code] = true
+				codes = append(codes, p.Code)
+			}
+		}
+	}
+	return codes
+}
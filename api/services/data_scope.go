@@ -0,0 +1,174 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+	"useradmin/api/models"
+)
+
+// ErrInvalidDataScope 数据范围取值错误
+var ErrInvalidDataScope = errors.New("数据范围只能是 all、own、department、subtree 或 custom")
+
+// 受数据范围限制的资源
+const (
+	DataResourceProduct = "product"
+	DataResourceUser    = "user"
+)
+
+// dataScopeResource 资源的归属条件，参数分别为用户ID和部门ID列表
+type dataScopeResource struct {
+	owner      string
+	department string
+}
+
+var dataScopeResources = map[string]dataScopeResource{
+	DataResourceProduct: {
+		owner:      "products.created_by = ?",
+		department: "products.created_by IN (SELECT id FROM users WHERE department_id IN ? AND deleted_at IS NULL)",
+	},
+	DataResourceUser: {
+		owner:      "users.id = ?",
+		department: "users.department_id IN ?",
+	},
+}
+
+// DataScopeRule 用户所有角色数据范围的并集
+type DataScopeRule struct {
+	All           bool
+	Own           bool
+	Department    bool
+	DepartmentIDs []uint // 自定义数据范围的部门，以及 subtree 范围展开后的本部门及下级部门
+}
+
+// ValidateDataScope 校验角色的数据范围，空值视为 all
+func ValidateDataScope(scope string) error {
+	switch scope {
+	case "", models.DataScopeAll, models.DataScopeOwn, models.DataScopeDepartment, models.DataScopeSubtree, models.DataScopeCustom:
+		return nil
+	}
+	return ErrInvalidDataScope
+}
+
+// SetRoleDataDepartments 设置自定义数据范围的部门，非自定义范围时清空
+func SetRoleDataDepartments(tx *gorm.DB, role *models.Role, departmentIDs []uint) error {
+	if err := tx.Where("role_id = ?", role.ID).Delete(&models.RoleDataDepartment{}).Error; err != nil {
+		return err
+	}
+	if role.DataScope != models.DataScopeCustom {
+		return nil
+	}
+	for _, id := range uniqueIDs(departmentIDs) {
+		if err := tx.Create(&models.RoleDataDepartment{RoleID: role.ID, DepartmentID: id}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RoleDataDepartmentIDs 查询角色自定义数据范围的部门
+func RoleDataDepartmentIDs(tx *gorm.DB, roleIDs []uint) ([]uint, error) {
+	ids := []uint{}
+	if len(roleIDs) == 0 {
+		return ids, nil
+	}
+	err := tx.Model(&models.RoleDataDepartment{}).Where("role_id IN ?", roleIDs).Distinct().Order("department_id").Pluck("department_id", &ids).Error
+	return ids, err
+}
+
+// loadDataScope 合并用户直接拥有的角色的数据范围，数据范围不通过角色继承传递
+func loadDataScope(tx *gorm.DB, subject *Subject, roles []models.Role) error {
+	if subject.Superuser {
+		subject.DataScope.All = true
+		return nil
+	}
+
+	var customRoleIDs []uint
+	subtree := false
+	for _, role := range roles {
+		switch role.DataScope {
+		case "", models.DataScopeAll:
+			subject.DataScope.All = true
+		case models.DataScopeOwn:
+			subject.DataScope.Own = true
+		case models.DataScopeDepartment:
+			subject.DataScope.Department = true
+		case models.DataScopeSubtree:
+			subtree = true
+		case models.DataScopeCustom:
+			customRoleIDs = append(customRoleIDs, role.ID)
+		}
+	}
+	if subject.DataScope.All {
+		return nil
+	}
+
+	ids, err := RoleDataDepartmentIDs(tx, customRoleIDs)
+	if err != nil {
+		return err
+	}
+	// 部门树变化时会清除全部权限缓存，因此可以在加载时展开子树
+	if subtree && subject.DepartmentID != 0 {
+		subtreeIDs, err := DepartmentSubtreeIDs(tx, []uint{subject.DepartmentID})
+		if err != nil {
+			return err
+		}
+		ids = append(ids, subtreeIDs...)
+	}
+	subject.DataScope.DepartmentIDs = uniqueIDs(ids)
+	return nil
+}
+
+// scopeDepartmentIDs 返回数据范围内的部门（本部门、下级部门和自定义部门）
+func (s *Subject) scopeDepartmentIDs() []uint {
+	ids := append([]uint{}, s.DataScope.DepartmentIDs...)
+	if s.DataScope.Department && s.DepartmentID != 0 {
+		ids = append(ids, s.DepartmentID)
+	}
+	return uniqueIDs(ids)
+}
+
+// CanAccessDepartment 判断部门是否在用户的数据范围内，用于创建用户或调整用户部门
+func (s *Subject) CanAccessDepartment(departmentID uint) bool {
+	if s.DataScope.All {
+		return true
+	}
+	if departmentID == 0 {
+		return false
+	}
+	for _, id := range s.scopeDepartmentIDs() {
+		if id == departmentID {
+			return true
+		}
+	}
+	return false
+}
+
+// DataScope 返回按数据范围过滤 resource 的查询条件，配合 db.Scopes 使用；subject 为空时不返回任何数据
+func DataScope(subject *Subject, resource string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		res, ok := dataScopeResources[resource]
+		if subject == nil || !ok {
+			return db.Where("1 = 0")
+		}
+		if subject.DataScope.All {
+			return db
+		}
+
+		var conditions []string
+		var args []interface{}
+		if subject.DataScope.Own {
+			conditions = append(conditions, res.owner)
+			args = append(args, subject.UserID)
+		}
+		if ids := subject.scopeDepartmentIDs(); len(ids) > 0 {
+			conditions = append(conditions, res.department)
+			args = append(args, ids)
+		}
+		if len(conditions) == 0 {
+			return db.Where("1 = 0")
+		}
+		return db.Where("("+strings.Join(conditions, " OR ")+")", args...)
+	}
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"useradmin/api/models"
+)
+
+var (
+	ErrDepartmentNotFound = errors.New("部门不存在")
+	ErrDepartmentCycle    = errors.New("不能将部门移动到自身或其下级部门下")
+	ErrDepartmentInUse    = errors.New("部门下还有子部门或用户，无法删除")
+)
+
+// CreateDepartment 创建部门并计算路径
+func CreateDepartment(tx *gorm.DB, dept *models.Department) error {
+	parentPath, err := departmentParentPath(tx, dept.ParentID)
+	if err != nil {
+		return err
+	}
+	if err := tx.Create(dept).Error; err != nil {
+		return err
+	}
+	dept.Path = departmentPath(parentPath, dept.ID)
+	return tx.Model(dept).Update("path", dept.Path).Error
+}
+
+// MoveDepartment 修改上级部门，同时更新所有下级部门的路径
+func MoveDepartment(tx *gorm.DB, dept *models.Department, parentID uint) error {
+	if parentID == dept.ParentID {
+		return nil
+	}
+	parentPath, err := departmentParentPath(tx, parentID)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(parentPath, dept.Path) {
+		return ErrDepartmentCycle
+	}
+
+	oldPath := dept.Path
+	newPath := departmentPath(parentPath, dept.ID)
+
+	var subtree []models.Department
+	if err := tx.Where("path LIKE ?", oldPath+"%").Find(&subtree).Error; err != nil {
+		return err
+	}
+	for _, d := range subtree {
+		path := newPath + strings.TrimPrefix(d.Path, oldPath)
+		if err := tx.Model(&models.Department{}).Where("id = ?", d.ID).Update("path", path).Error; err != nil {
+			return err
+		}
+	}
+
+	dept.ParentID = parentID
+	dept.Path = newPath
+	return tx.Model(dept).Update("parent_id", parentID).Error
+}
+
+// DeleteDepartment 删除部门，存在子部门或用户时返回 ErrDepartmentInUse
+func DeleteDepartment(tx *gorm.DB, dept *models.Department) error {
+	var children, users int64
+	if err := tx.Model(&models.Department{}).Where("parent_id = ?", dept.ID).Count(&children).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.User{}).Where("department_id = ?", dept.ID).Count(&users).Error; err != nil {
+		return err
+	}
+	if children > 0 || users > 0 {
+		return ErrDepartmentInUse
+	}
+	if err := tx.Where("department_id = ?", dept.ID).Delete(&models.RoleDataDepartment{}).Error; err != nil {
+		return err
+	}
+	return tx.Delete(dept).Error
+}
+
+// DepartmentSubtreeIDs 返回部门及其全部下级部门的ID
+func DepartmentSubtreeIDs(tx *gorm.DB, departmentIDs []uint) ([]uint, error) {
+	ids := []uint{}
+	departmentIDs = uniqueIDs(departmentIDs)
+	if len(departmentIDs) == 0 {
+		return ids, nil
+	}
+
+	var paths []string
+	if err := tx.Model(&models.Department{}).Where("id IN ?", departmentIDs).Pluck("path", &paths).Error; err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return ids, nil
+	}
+
+	query := tx.Model(&models.Department{})
+	conditions := tx.Where("path LIKE ?", paths[0]+"%")
+	for _, path := range paths[1:] {
+		conditions = conditions.Or("path LIKE ?", path+"%")
+	}
+	err := query.Where(conditions).Order("id").Pluck("id", &ids).Error
+	return ids, err
+}
+
+// CheckDepartmentExists 校验部门存在，0 表示不属于任何部门
+func CheckDepartmentExists(tx *gorm.DB, departmentID uint) error {
+	if departmentID == 0 {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(&models.Department{}).Where("id = ?", departmentID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrDepartmentNotFound
+	}
+	return nil
+}
+
+// departmentParentPath 返回上级部门的路径，顶级部门的上级路径为 "/"
+func departmentParentPath(tx *gorm.DB, parentID uint) (string, error) {
+	if parentID == 0 {
+		return "/", nil
+	}
+	var parent models.Department
+	if err := tx.First(&parent, parentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrDepartmentNotFound
+		}
+		return "", err
+	}
+	return parent.Path, nil
+}
+
+func departmentPath(parentPath string, id uint) string {
+	return fmt.Sprintf("%s%d/", parentPath, id)
+}
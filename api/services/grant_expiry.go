@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"useradmin/api/config"
+	"useradmin/api/models"
+)
+
+// 临时授权的类型
+const (
+	GrantKindUserRole       = "user_role"
+	GrantKindRolePermission = "role_permission"
+)
+
+// TimedGrant 设置了结束时间的用户角色或角色权限
+type TimedGrant struct {
+	Kind       string
+	TenantID   uint
+	User       *models.User // user_role
+	Role       models.Role
+	Permission *models.Permission // role_permission
+	ValidFrom  *time.Time
+	ValidUntil time.Time
+}
+
+// ListExpiringGrants 返回在 (from, until] 内到期的临时授权，按到期时间排序。
+// tx 携带租户时只返回该租户的授权
+func ListExpiringGrants(tx *gorm.DB, from, until time.Time) ([]TimedGrant, error) {
+	grants := []TimedGrant{}
+
+	// user_roles 没有 tenant_id，通过用户限定租户
+	userRoles := tx.Model(&models.UserRole{}).
+		Joins("JOIN users ON users.id = user_roles.user_id AND users.deleted_at IS NULL").
+		Where("user_roles.valid_until > ? AND user_roles.valid_until <= ?", from, until)
+	if tenantID, ok := TenantFromContext(tx.Statement.Context); ok {
+		userRoles = userRoles.Where("users.tenant_id = ?", tenantID)
+	}
+	var links []models.UserRole
+	if err := userRoles.Preload("User").Preload("Role").Find(&links).Error; err != nil {
+		return nil, err
+	}
+	for _, link := range links {
+		user := link.User
+		grants = append(grants, TimedGrant{
+			Kind:       GrantKindUserRole,
+			TenantID:   user.TenantID,
+			User:       &user,
+			Role:       link.Role,
+			ValidFrom:  link.ValidFrom,
+			ValidUntil: *link.ValidUntil,
+		})
+	}
+
+	var rolePermissions []models.RolePermission
+	if err := tx.Preload("Role").Preload("Permission").
+		Where("valid_until > ? AND valid_until <= ?", from, until).
+		Find(&rolePermissions).Error; err != nil {
+		return nil, err
+	}
+	for _, link := range rolePermissions {
+		permission := link.Permission
+		grants = append(grants, TimedGrant{
+			Kind:       GrantKindRolePermission,
+			TenantID:   link.TenantID,
+			Role:       link.Role,
+			Permission: &permission,
+			ValidFrom:  link.ValidFrom,
+			ValidUntil: *link.ValidUntil,
+		})
+	}
+
+	// 到期时间相同时保持用户角色在前
+	sort.SliceStable(grants, func(i, j int) bool { return grants[i].ValidUntil.Before(grants[j].ValidUntil) })
+	return grants, nil
+}
+
+// ExpireGrants 删除在 now 之前到期的临时授权，清除相关权限缓存并记录审计事件，返回删除的数量
+func ExpireGrants(now time.Time) (int, error) {
+	grants, err := ListExpiringGrants(config.DB, time.Time{}, now)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, grant := range grants {
+		var removed bool
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			var result *gorm.DB
+			switch grant.Kind {
+			case GrantKindUserRole:
+				// 带上到期条件，避免删除刚被续期的授权
+				result = tx.Where("user_id = ? AND role_id = ? AND valid_until <= ?", grant.User.ID, grant.Role.ID, now).Delete(&models.UserRole{})
+			default:
+				result = tx.Where("role_id = ? AND permission_id = ? AND valid_until <= ?", grant.Role.ID, grant.Permission.ID, now).Delete(&models.RolePermission{})
+			}
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			removed = true
+			if grant.Kind == GrantKindUserRole {
+				roleIDs, err := UserRoleIDs(tx, grant.User.ID)
+				if err != nil {
+					return err
+				}
+				if err := syncPrimaryRole(tx, grant.User, roleIDs); err != nil {
+					return err
+				}
+			}
+			return recordGrantExpiry(tx, grant)
+		})
+		if err != nil {
+			return expired, err
+		}
+		if !removed {
+			continue
+		}
+
+		expired++
+		if grant.Kind == GrantKindUserRole {
+			InvalidateUserPermissions(grant.User.ID)
+		} else {
+			InvalidateRolePermissions(grant.Role.ID)
+		}
+	}
+	return expired, nil
+}
+
+// recordGrantExpiry 在删除授权的事务中将临时授权到期记录为审计事件
+func recordGrantExpiry(tx *gorm.DB, grant TimedGrant) error {
+	event := AuditEvent{
+		TenantID:  grant.TenantID,
+		Type:      AuditGrantExpired,
+		ActorName: "system",
+	}
+	if grant.Kind == GrantKindUserRole {
+		event.TargetType = AuditTargetUser
+		event.TargetID = grant.User.ID
+		event.Changes = []FieldChange{{
+			Field:  "role",
+			Before: map[string]any{"id": grant.Role.ID, "name": grant.Role.Name, "valid_from": grant.ValidFrom, "valid_until": grant.ValidUntil},
+		}}
+	} else {
+		event.TargetType = AuditTargetRole
+		event.TargetID = grant.Role.ID
+		event.Changes = []FieldChange{{
+			Field:  "permission",
+			Before: map[string]any{"id": grant.Permission.ID, "code": grant.Permission.Code, "valid_from": grant.ValidFrom, "valid_until": grant.ValidUntil},
+		}}
+	}
+	return RecordAudit(tx, event)
+}
+
+// StartGrantExpiry 启动后台任务，每隔 interval 清理一次到期的临时授权，ctx 取消后停止
+func StartGrantExpiry(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if n, err := ExpireGrants(time.Now()); err != nil {
+				log.Printf("清理到期授权失败: %v", err)
+			} else if n > 0 {
+				log.Printf("已清理 %d 个到期的临时授权", n)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"useradmin/api/models"
+)
+
+// ErrInvalidGrantWindow 授权有效期无效
+var ErrInvalidGrantWindow = errors.New("授权有效期无效：结束时间必须晚于开始时间和当前时间")
+
+// GrantWindow 用户角色、角色权限的有效期，为空表示立即生效、长期有效
+type GrantWindow struct {
+	ValidFrom  *time.Time `json:"valid_from"`
+	ValidUntil *time.Time `json:"valid_until"`
+}
+
+// Validate 校验有效期，结束时间必须在开始时间和当前时间之后
+func (w GrantWindow) Validate(now time.Time) error {
+	if w.ValidUntil == nil {
+		return nil
+	}
+	if !w.ValidUntil.After(now) || (w.ValidFrom != nil && !w.ValidUntil.After(*w.ValidFrom)) {
+		return ErrInvalidGrantWindow
+	}
+	return nil
+}
+
+// activeGrantSQL 关联表 table 中在某一时刻生效的授权，参数为两次该时刻
+func activeGrantSQL(table string) string {
+	return fmt.Sprintf("(%[1]s.valid_from IS NULL OR %[1]s.valid_from <= ?) AND (%[1]s.valid_until IS NULL OR %[1]s.valid_until > ?)", table)
+}
+
+// ActiveUserRoles 返回用户在 now 时生效的角色
+func ActiveUserRoles(tx *gorm.DB, userID uint, now time.Time) ([]models.Role, error) {
+	var roles []models.Role
+	err := tx.Joins("JOIN user_roles ON user_roles.role_id = roles.id").
+		Where("user_roles.user_id = ?", userID).
+		Where(activeGrantSQL("user_roles"), now, now).
+		Order("roles.id").
+		Find(&roles).Error
+	return roles, err
+}
+
+// withActivePermissions 为角色加载在 now 时生效的权限。
+// 多对多的 Preload("Permissions") 不能按关联表的有效期过滤，权限判断都应使用该函数
+func withActivePermissions(tx *gorm.DB, roles []models.Role, now time.Time) error {
+	if len(roles) == 0 {
+		return nil
+	}
+	roleIDs := make([]uint, len(roles))
+	for i, role := range roles {
+		roleIDs[i] = role.ID
+	}
+
+	var links []models.RolePermission
+	if err := tx.Where("role_id IN ?", roleIDs).Where(activeGrantSQL("role_permissions"), now, now).Find(&links).Error; err != nil {
+		return err
+	}
+	permissionIDs := make([]uint, 0, len(links))
+	for _, link := range links {
+		permissionIDs = append(permissionIDs, link.PermissionID)
+	}
+
+	byID := make(map[uint]models.Permission)
+	if len(permissionIDs) > 0 {
+		var permissions []models.Permission
+		if err := tx.Where("id IN ?", uniqueIDs(permissionIDs)).Order("id").Find(&permissions).Error; err != nil {
+			return err
+		}
+		for _, p := range permissions {
+			byID[p.ID] = p
+		}
+	}
+
+	byRole := make(map[uint][]models.Permission)
+	for _, link := range links {
+		if p, ok := byID[link.PermissionID]; ok {
+			byRole[link.RoleID] = append(byRole[link.RoleID], p)
+		}
+	}
+	for i := range roles {
+		roles[i].Permissions = byRole[roles[i].ID]
+	}
+	return nil
+}
+
+// nextGrantChange 返回用户角色或角色权限在 now 之后最早开始或到期的时间，用于让权限缓存及时失效
+func nextGrantChange(tx *gorm.DB, userID uint, roleIDs []uint, now time.Time) (time.Time, bool) {
+	var next time.Time
+	consider := func(query *gorm.DB, column string) {
+		var t sql.NullTime
+		if err := query.Where(column+" > ?", now).Select("MIN(" + column + ")").Row().Scan(&t); err == nil && t.Valid {
+			if next.IsZero() || t.Time.Before(next) {
+				next = t.Time
+			}
+		}
+	}
+	for _, column := range []string{"valid_from", "valid_until"} {
+		consider(tx.Model(&models.UserRole{}).Where("user_id = ?", userID), column)
+		if len(roleIDs) > 0 {
+			consider(tx.Model(&models.RolePermission{}).Where("role_id IN ?", roleIDs), column)
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// UserRoleWindows 返回用户设置了有效期的角色，键为角色ID
+func UserRoleWindows(tx *gorm.DB, userID uint) (map[uint]GrantWindow, error) {
+	var links []models.UserRole
+	if err := tx.Where("user_id = ? AND (valid_from IS NOT NULL OR valid_until IS NOT NULL)", userID).Find(&links).Error; err != nil {
+		return nil, err
+	}
+	windows := make(map[uint]GrantWindow, len(links))
+	for _, link := range links {
+		windows[link.RoleID] = GrantWindow{ValidFrom: link.ValidFrom, ValidUntil: link.ValidUntil}
+	}
+	return windows, nil
+}
+
+// RolePermissionWindows 返回角色设置了有效期的权限，键为权限ID
+func RolePermissionWindows(tx *gorm.DB, roleID uint) (map[uint]GrantWindow, error) {
+	var links []models.RolePermission
+	if err := tx.Where("role_id = ? AND (valid_from IS NOT NULL OR valid_until IS NOT NULL)", roleID).Find(&links).Error; err != nil {
+		return nil, err
+	}
+	windows := make(map[uint]GrantWindow, len(links))
+	for _, link := range links {
+		windows[link.PermissionID] = GrantWindow{ValidFrom: link.ValidFrom, ValidUntil: link.ValidUntil}
+	}
+	return windows, nil
+}
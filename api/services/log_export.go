@@ -0,0 +1,145 @@
+package services
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"useradmin/api/models"
+)
+
+// ErrUnknownExportFormat 不支持的导出格式
+var ErrUnknownExportFormat = errors.New("导出格式只能是 csv、xlsx 或 ndjson")
+
+// LogExporter 将日志逐批写入导出文件，写完后调用 Close 写入文件结尾
+type LogExporter interface {
+	ContentType() string
+	Extension() string
+	WriteLogs(entries []models.Log) error
+	Close() error
+}
+
+// logExportColumns CSV、XLSX 的表头，与 logExportRow 的列一一对应
+var logExportColumns = []string{"ID", "时间", "请求ID", "租户ID", "用户名", "方法", "路径", "状态码", "IP", "User-Agent", "请求内容", "响应内容"}
+
+// logExportTimeLayout CSV、XLSX 中的时间格式
+const logExportTimeLayout = "2006-01-02 15:04:05"
+
+// NewLogExporter 按格式创建导出，format 为 csv、xlsx 或 ndjson。
+// 创建时不向 w 写入任何内容，文件头在第一次 WriteLogs 或 Close 时写入，调用方可以在创建后再设置响应头
+func NewLogExporter(format string, w io.Writer) (LogExporter, error) {
+	switch format {
+	case "csv":
+		return &csvLogExporter{out: w, w: csv.NewWriter(w)}, nil
+	case "xlsx":
+		return &xlsxLogExporter{zw: zip.NewWriter(w)}, nil
+	case "ndjson":
+		encoder := json.NewEncoder(w)
+		encoder.SetEscapeHTML(false)
+		return &ndjsonLogExporter{encoder: encoder}, nil
+	}
+	return nil, ErrUnknownExportFormat
+}
+
+// logExportRow 一条日志在 CSV、XLSX 中的各列
+func logExportRow(l models.Log) []string {
+	r := NewLogRecord(l)
+	return []string{
+		strconv.FormatUint(uint64(r.ID), 10),
+		r.Time.Format(logExportTimeLayout),
+		r.RequestID,
+		strconv.FormatUint(uint64(r.TenantID), 10),
+		r.Username,
+		r.Method,
+		r.Path,
+		strconv.Itoa(r.Status),
+		r.IP,
+		r.UserAgent,
+		r.Request,
+		r.Response,
+	}
+}
+
+// ndjsonLogExporter 每行一条 LogRecord，与日志文件、归档文件的格式相同
+type ndjsonLogExporter struct {
+	encoder *json.Encoder
+}
+
+func (e *ndjsonLogExporter) ContentType() string { return "application/x-ndjson; charset=utf-8" }
+func (e *ndjsonLogExporter) Extension() string   { return "ndjson" }
+
+func (e *ndjsonLogExporter) WriteLogs(entries []models.Log) error {
+	for _, entry := range entries {
+		if err := e.encoder.Encode(NewLogRecord(entry)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *ndjsonLogExporter) Close() error { return nil }
+
+// csvLogExporter 带 UTF-8 BOM 的 CSV，Excel 直接打开时中文不会乱码
+type csvLogExporter struct {
+	out     io.Writer
+	w       *csv.Writer
+	started bool
+}
+
+// start 写入 BOM 和表头
+func (e *csvLogExporter) start() error {
+	if e.started {
+		return nil
+	}
+	e.started = true
+	if _, err := io.WriteString(e.out, "\ufeff"); err != nil {
+		return err
+	}
+	return e.w.Write(logExportColumns)
+}
+
+func (e *csvLogExporter) ContentType() string { return "text/csv; charset=utf-8" }
+func (e *csvLogExporter) Extension() string   { return "csv" }
+
+func (e *csvLogExporter) WriteLogs(entries []models.Log) error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		row := logExportRow(entry)
+		for i, cell := range row {
+			row[i] = escapeCSVFormula(cell)
+		}
+		if err := e.w.Write(row); err != nil {
+			return err
+		}
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvLogExporter) Close() error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// escapeCSVFormula 以 = + - @ 等开头的内容在 Excel 中会被当作公式执行，前面加单引号
+func escapeCSVFormula(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+// LogExportFilename 导出文件名，如 logs-20060102T150405.csv
+func LogExportFilename(e LogExporter, now time.Time) string {
+	return "logs-" + now.Format("20060102T150405") + "." + e.Extension()
+}
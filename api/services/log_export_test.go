@@ -0,0 +1,91 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+
+	"useradmin/api/models"
+)
+
+func TestLogExporterWritesNothingUntilFirstWrite(t *testing.T) {
+	for _, format := range []string{"csv", "xlsx", "ndjson"} {
+		var buf bytes.Buffer
+		e, err := NewLogExporter(format, &buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("%s: NewLogExporter wrote %d bytes before the first WriteLogs", format, buf.Len())
+		}
+		if err := e.WriteLogs([]models.Log{testLog(1, 200)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCSVLogExporter(t *testing.T) {
+	var buf bytes.Buffer
+	e, _ := NewLogExporter("csv", &buf)
+	entry := testLog(1, 200)
+	entry.Username = "=cmd"
+	for _, batch := range [][]models.Log{{entry}, {testLog(2, 404)}} {
+		if err := e.WriteLogs(batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	body, ok := strings.CutPrefix(buf.String(), "\ufeff")
+	if !ok {
+		t.Fatal("missing UTF-8 BOM")
+	}
+	rows, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[0][0] != "ID" {
+		t.Fatalf("rows = %v, want header and 2 rows", rows)
+	}
+	if rows[1][4] != "'=cmd" {
+		t.Errorf("username = %q, want formula escaped", rows[1][4])
+	}
+	if rows[2][7] != "404" {
+		t.Errorf("status = %q, want 404", rows[2][7])
+	}
+}
+
+func TestEmptyLogExportHasHeader(t *testing.T) {
+	var buf bytes.Buffer
+	e, _ := NewLogExporter("csv", &buf)
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "\ufeff" + strings.Join(logExportColumns, ",") + "\n"; buf.String() != want {
+		t.Errorf("empty csv = %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	e, _ = NewLogExporter("xlsx", &buf)
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if !strings.Contains(strings.Join(names, " "), "xl/worksheets/sheet1.xml") {
+		t.Errorf("empty xlsx files = %v, want sheet1", names)
+	}
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"useradmin/api/models"
+)
+
+// Excel 单个工作表最多 1048576 行，单元格最多 32767 个字符
+const (
+	xlsxMaxRows      = 1048576
+	xlsxMaxCellChars = 32767
+)
+
+// xlsxNumericColumns 按数字写入的列：ID、租户ID、状态码
+var xlsxNumericColumns = map[int]bool{0: true, 3: true, 7: true}
+
+// xlsxLogExporter 边查询边写入 XLSX。工作表先写入压缩包，写满一个工作表后另起一个，
+// 工作簿、内容类型等需要知道工作表数量的文件在 Close 时最后写入
+type xlsxLogExporter struct {
+	zw     *zip.Writer
+	sheet  *bufio.Writer
+	sheets int
+	rows   int // 当前工作表已写入的行数，包括表头
+}
+
+func (e *xlsxLogExporter) ContentType() string {
+	return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+}
+func (e *xlsxLogExporter) Extension() string { return "xlsx" }
+
+func (e *xlsxLogExporter) WriteLogs(entries []models.Log) error {
+	if e.sheets == 0 {
+		if err := e.startSheet(); err != nil {
+			return err
+		}
+	}
+	for _, entry := range entries {
+		if e.rows >= xlsxMaxRows {
+			if err := e.endSheet(); err != nil {
+				return err
+			}
+			if err := e.startSheet(); err != nil {
+				return err
+			}
+		}
+		if err := e.writeRow(logExportRow(entry), true); err != nil {
+			return err
+		}
+	}
+	return e.sheet.Flush()
+}
+
+// Close 结束当前工作表并写入工作簿结构，没有日志时也写入只有表头的工作表
+func (e *xlsxLogExporter) Close() error {
+	if e.sheets == 0 {
+		if err := e.startSheet(); err != nil {
+			return err
+		}
+	}
+	if err := e.endSheet(); err != nil {
+		return err
+	}
+
+	var contentTypes, workbookSheets, workbookRels string
+	for i := 1; i <= e.sheets; i++ {
+		contentTypes += fmt.Sprintf(`<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i)
+		workbookSheets += fmt.Sprintf(`<sheet name="日志%d" sheetId="%d" r:id="rId%d"/>`, i, i, i)
+		workbookRels += fmt.Sprintf(`<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i, i)
+	}
+	files := []struct{ name, body string }{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			contentTypes + `</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+			`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>` +
+			workbookSheets + `</sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			workbookRels + `</Relationships>`},
+	}
+	for _, f := range files {
+		w, err := e.create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, f.body); err != nil {
+			return err
+		}
+	}
+	return e.zw.Close()
+}
+
+// create 在压缩包中新建文件，zip.Writer.Create 不设置修改时间，解压后显示为 1980 年
+func (e *xlsxLogExporter) create(name string) (io.Writer, error) {
+	return e.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+}
+
+// startSheet 新建工作表并写入表头
+func (e *xlsxLogExporter) startSheet() error {
+	e.sheets++
+	w, err := e.create(fmt.Sprintf("xl/worksheets/sheet%d.xml", e.sheets))
+	if err != nil {
+		return err
+	}
+	e.sheet = bufio.NewWriterSize(w, 64<<10)
+	e.rows = 0
+	e.sheet.WriteString(xml.Header)
+	e.sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	// 冻结表头
+	e.sheet.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`)
+	e.sheet.WriteString(`<sheetData>`)
+	return e.writeRow(logExportColumns, false)
+}
+
+func (e *xlsxLogExporter) endSheet() error {
+	e.sheet.WriteString(`</sheetData></worksheet>`)
+	return e.sheet.Flush()
+}
+
+// writeRow 写入一行，数据行的 ID、租户ID、状态码按数字写入，其它列按内联字符串写入
+func (e *xlsxLogExporter) writeRow(cells []string, data bool) error {
+	e.rows++
+	e.sheet.WriteString(`<row r="` + strconv.Itoa(e.rows) + `">`)
+	for i, cell := range cells {
+		if data && xlsxNumericColumns[i] {
+			e.sheet.WriteString(`<c><v>` + cell + `</v></c>`)
+			continue
+		}
+		e.sheet.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+		// EscapeText 会把 XML 中不允许出现的控制字符替换为 U+FFFD
+		if err := xml.EscapeText(e.sheet, []byte(truncateCell(cell))); err != nil {
+			return err
+		}
+		e.sheet.WriteString(`</t></is></c>`)
+	}
+	_, err := e.sheet.WriteString(`</row>`)
+	return err
+}
+
+// truncateCell 超过 Excel 单元格上限的内容截断，否则 Excel 会拒绝打开文件。
+// Excel 按 UTF-16 计算长度，BMP 以外的字符占两个
+func truncateCell(v string) string {
+	if len(v) <= xlsxMaxCellChars {
+		return v
+	}
+	n := 0
+	for i, r := range v {
+		w := 1
+		if r > 0xFFFF {
+			w = 2
+		}
+		if n+w > xlsxMaxCellChars {
+			return v[:i]
+		}
+		n += w
+	}
+	return v
+}
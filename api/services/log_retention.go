@@ -0,0 +1,404 @@
+package services
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"useradmin/api/config"
+	"useradmin/api/models"
+)
+
+var (
+	ErrLogRetentionRunning  = errors.New("日志清理任务正在执行")
+	ErrLogRetentionDisabled = errors.New("未配置日志保留天数，日志清理已关闭")
+	ErrLogArchiveNotFound   = errors.New("归档文件不存在")
+)
+
+// 日志清理任务的触发方式和状态
+const (
+	LogRetentionTriggerSchedule = "schedule"
+	LogRetentionTriggerManual   = "manual"
+
+	LogRetentionRunning   = "running"
+	LogRetentionSucceeded = "succeeded"
+	LogRetentionFailed    = "failed"
+)
+
+// 归档文件名为 logs-20060102T150405.000.ndjson.gz，写入过程中使用 .tmp 后缀
+const (
+	logArchivePrefix = "logs-"
+	logArchiveExt    = ".ndjson.gz"
+)
+
+// logRetentionHistory 内存中保留的最近执行记录条数
+const logRetentionHistory = 20
+
+// LogRetentionRun 一次日志清理的执行记录
+type LogRetentionRun struct {
+	ID          uint64     `json:"id"`
+	Trigger     string     `json:"trigger"`
+	TriggeredBy string     `json:"triggered_by"`
+	Status      string     `json:"status"`
+	Cutoff      time.Time  `json:"cutoff"`       // 早于该时间的日志被清理
+	ErrorCutoff time.Time  `json:"error_cutoff"` // 早于该时间的错误日志被清理
+	Archived    int64      `json:"archived"`
+	Deleted     int64      `json:"deleted"`
+	ArchiveFile string     `json:"archive_file,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// LogArchive 归档目录中的一个归档文件
+type LogArchive struct {
+	Name       string    `json:"name"`
+	Size       int64     `json:"size"`
+	ModifiedAt time.Time `json:"modified_at"`
+}
+
+// LogRetentionStatus 保留策略、执行记录和归档文件
+type LogRetentionStatus struct {
+	Policy   config.LogRetentionConfig `json:"policy"`
+	Running  *LogRetentionRun          `json:"running"`
+	Runs     []LogRetentionRun         `json:"runs"` // 最近的执行记录，新的在前
+	Pending  int64                     `json:"pending"`
+	Archives []LogArchive              `json:"archives"`
+}
+
+// logRetention 同一时间只允许一个清理任务执行，执行记录只保存在内存中。
+// 多个实例共用数据库时由数据库命名锁保证只有一个实例执行，release 释放该锁
+var logRetention struct {
+	mu      sync.Mutex
+	nextID  uint64
+	running *LogRetentionRun
+	release func()
+	history []LogRetentionRun
+}
+
+// logRetentionLockName 日志清理任务的 MySQL 命名锁
+const logRetentionLockName = "useradmin.log_retention"
+
+// StartLogRetention 按 log.retention.interval 定时清理过期日志，interval 或 days 为 0 时不启动
+func StartLogRetention(ctx context.Context) {
+	cfg := config.GetConfig().Log.Retention
+	if cfg.Interval <= 0 || cfg.Days <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(time.Duration(cfg.Interval) * time.Second)
+		defer ticker.Stop()
+		for {
+			run, err := beginLogRetention(LogRetentionTriggerSchedule, "system", time.Now())
+			if err == nil {
+				finishLogRetention(ctx, run)
+			} else if !errors.Is(err, ErrLogRetentionRunning) {
+				log.Printf("清理过期日志失败: %v", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// TriggerLogRetention 在后台立即执行一次清理，返回本次执行记录；已有任务执行时返回 ErrLogRetentionRunning
+func TriggerLogRetention(triggeredBy string) (LogRetentionRun, error) {
+	run, err := beginLogRetention(LogRetentionTriggerManual, triggeredBy, time.Now())
+	if err != nil {
+		return LogRetentionRun{}, err
+	}
+	snapshot := *run
+	go finishLogRetention(context.Background(), run)
+	return snapshot, nil
+}
+
+// GetLogRetentionStatus 返回保留策略、最近的执行记录、当前待清理的条数和归档文件列表
+func GetLogRetentionStatus() (LogRetentionStatus, error) {
+	cfg := config.GetConfig().Log.Retention
+	status := LogRetentionStatus{Policy: cfg, Runs: []LogRetentionRun{}, Archives: []LogArchive{}}
+
+	logRetention.mu.Lock()
+	if logRetention.running != nil {
+		running := *logRetention.running
+		status.Running = &running
+	}
+	for i := len(logRetention.history) - 1; i >= 0; i-- {
+		status.Runs = append(status.Runs, logRetention.history[i])
+	}
+	logRetention.mu.Unlock()
+
+	if cfg.Days > 0 {
+		cutoff, errorCutoff := retentionCutoffs(cfg, time.Now())
+		if err := expiredLogs(config.DB, cutoff, errorCutoff).Count(&status.Pending).Error; err != nil {
+			return status, err
+		}
+	}
+
+	archives, err := ListLogArchives()
+	if err != nil {
+		return status, err
+	}
+	status.Archives = archives
+	return status, nil
+}
+
+// ListLogArchives 列出归档目录中的归档文件，新的在前
+func ListLogArchives() ([]LogArchive, error) {
+	dir := config.GetConfig().Log.Retention.ArchiveDir
+	archives := []LogArchive{}
+	if dir == "" {
+		return archives, nil
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, logArchivePrefix+"*"+logArchiveExt))
+	if err != nil {
+		return nil, err
+	}
+	// 文件名中的时间可以按字符串排序
+	sort.Sort(sort.Reverse(sort.StringSlice(paths)))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		archives = append(archives, LogArchive{Name: filepath.Base(path), Size: info.Size(), ModifiedAt: info.ModTime()})
+	}
+	return archives, nil
+}
+
+// LogArchivePath 返回归档文件的路径，name 只能是 ListLogArchives 返回的文件名
+func LogArchivePath(name string) (string, error) {
+	dir := config.GetConfig().Log.Retention.ArchiveDir
+	if dir == "" || name != filepath.Base(name) ||
+		!strings.HasPrefix(name, logArchivePrefix) || !strings.HasSuffix(name, logArchiveExt) {
+		return "", ErrLogArchiveNotFound
+	}
+	path := filepath.Join(dir, name)
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return "", ErrLogArchiveNotFound
+	}
+	return path, nil
+}
+
+// retentionCutoffs 普通日志和错误日志（状态码 >= 400）的清理截止时间
+func retentionCutoffs(cfg config.LogRetentionConfig, now time.Time) (time.Time, time.Time) {
+	errorDays := cfg.ErrorDays
+	if errorDays < cfg.Days {
+		errorDays = cfg.Days
+	}
+	return now.AddDate(0, 0, -cfg.Days), now.AddDate(0, 0, -errorDays)
+}
+
+// expiredLogs 超过保留期的日志，包括已软删除的日志。后台任务不携带租户，清理全部租户的日志。
+// 错误日志的截止时间不晚于 cutoff，先按 created_at < cutoff 限定范围，走 (created_at, status) 索引
+func expiredLogs(tx *gorm.DB, cutoff, errorCutoff time.Time) *gorm.DB {
+	return tx.Unscoped().Model(&models.Log{}).
+		Where("created_at < ? AND (status < 400 OR created_at < ?)", cutoff, errorCutoff)
+}
+
+// beginLogRetention 登记一次执行，已有任务执行时返回 ErrLogRetentionRunning
+func beginLogRetention(trigger, triggeredBy string, now time.Time) (*LogRetentionRun, error) {
+	cfg := config.GetConfig().Log.Retention
+	if cfg.Days <= 0 {
+		return nil, ErrLogRetentionDisabled
+	}
+
+	logRetention.mu.Lock()
+	defer logRetention.mu.Unlock()
+	if logRetention.running != nil {
+		return nil, ErrLogRetentionRunning
+	}
+	release, err := acquireLogRetentionLock()
+	if err != nil {
+		return nil, err
+	}
+	logRetention.release = release
+	logRetention.nextID++
+	cutoff, errorCutoff := retentionCutoffs(cfg, now)
+	run := &LogRetentionRun{
+		ID:          logRetention.nextID,
+		Trigger:     trigger,
+		TriggeredBy: triggeredBy,
+		Status:      LogRetentionRunning,
+		Cutoff:      cutoff,
+		ErrorCutoff: errorCutoff,
+		StartedAt:   now,
+	}
+	logRetention.running = run
+	return run, nil
+}
+
+// acquireLogRetentionLock 获取数据库命名锁，其它实例正在清理时返回 ErrLogRetentionRunning。
+// 命名锁属于连接，这里单独占用一个连接直到释放；实例崩溃、连接断开时锁自动释放
+func acquireLogRetentionLock() (func(), error) {
+	sqlDB, err := config.DB.DB()
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", logRetentionLockName).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if acquired.Int64 != 1 {
+		conn.Close()
+		return nil, ErrLogRetentionRunning
+	}
+	return func() {
+		if _, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", logRetentionLockName); err != nil {
+			log.Printf("释放日志清理锁失败: %v", err)
+		}
+		conn.Close()
+	}, nil
+}
+
+// finishLogRetention 执行清理并保存执行记录
+func finishLogRetention(ctx context.Context, run *LogRetentionRun) {
+	err := purgeExpiredLogs(ctx, run)
+
+	logRetention.mu.Lock()
+	defer logRetention.mu.Unlock()
+	finished := time.Now()
+	run.FinishedAt = &finished
+	run.Status = LogRetentionSucceeded
+	if err != nil {
+		run.Status = LogRetentionFailed
+		run.Error = err.Error()
+		log.Printf("清理过期日志失败，已归档 %d 条，已删除 %d 条: %v", run.Archived, run.Deleted, err)
+	} else if run.Deleted > 0 {
+		log.Printf("已清理 %d 条过期日志", run.Deleted)
+	}
+	logRetention.running = nil
+	logRetention.release()
+	logRetention.release = nil
+	logRetention.history = append(logRetention.history, *run)
+	if len(logRetention.history) > logRetentionHistory {
+		logRetention.history = logRetention.history[len(logRetention.history)-logRetentionHistory:]
+	}
+}
+
+// purgeExpiredLogs 先把过期日志按ID顺序写入归档文件，归档文件完整写入后再删除已归档的日志。
+// 截止时间在任务开始时确定，删除时限定 id 不超过已归档的最大ID，不会删除未归档的日志
+func purgeExpiredLogs(ctx context.Context, run *LogRetentionRun) error {
+	cfg := config.GetConfig().Log.Retention
+
+	var maxID uint
+	if cfg.Archive {
+		var err error
+		if maxID, err = archiveExpiredLogs(ctx, cfg, run); err != nil {
+			return err
+		}
+		if maxID == 0 {
+			return nil
+		}
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		query := expiredLogs(config.DB, run.Cutoff, run.ErrorCutoff)
+		if cfg.Archive {
+			query = query.Where("id <= ?", maxID)
+		}
+		var ids []uint
+		if err := query.Order("id").Limit(cfg.BatchSize).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		result := config.DB.Unscoped().Where("id IN ?", ids).Delete(&models.Log{})
+		if result.Error != nil {
+			return result.Error
+		}
+		setRunProgress(run, func() { run.Deleted += result.RowsAffected })
+	}
+}
+
+// archiveExpiredLogs 将过期日志写入 gzip 压缩的 NDJSON 文件，返回已归档的最大ID，没有过期日志时返回 0
+func archiveExpiredLogs(ctx context.Context, cfg config.LogRetentionConfig, run *LogRetentionRun) (uint, error) {
+	if err := os.MkdirAll(cfg.ArchiveDir, 0o755); err != nil {
+		return 0, fmt.Errorf("创建归档目录失败: %w", err)
+	}
+	name := logArchivePrefix + run.StartedAt.Format("20060102T150405.000") + logArchiveExt
+	path := filepath.Join(cfg.ArchiveDir, name)
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return 0, fmt.Errorf("创建归档文件失败: %w", err)
+	}
+	defer os.Remove(tmp)
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	encoder := json.NewEncoder(gz)
+	encoder.SetEscapeHTML(false)
+
+	var lastID uint
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		var batch []models.Log
+		err := expiredLogs(config.DB, run.Cutoff, run.ErrorCutoff).
+			Where("id > ?", lastID).Order("id").Limit(cfg.BatchSize).Find(&batch).Error
+		if err != nil {
+			return 0, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+		for _, entry := range batch {
+			if err := encoder.Encode(NewLogRecord(entry)); err != nil {
+				return 0, fmt.Errorf("写入归档文件失败: %w", err)
+			}
+		}
+		lastID = batch[len(batch)-1].ID
+		setRunProgress(run, func() { run.Archived += int64(len(batch)) })
+	}
+	if lastID == 0 {
+		return 0, nil
+	}
+
+	if err := gz.Close(); err != nil {
+		return 0, fmt.Errorf("写入归档文件失败: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return 0, fmt.Errorf("写入归档文件失败: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return 0, fmt.Errorf("写入归档文件失败: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return 0, fmt.Errorf("保存归档文件失败: %w", err)
+	}
+	setRunProgress(run, func() { run.ArchiveFile = name })
+	return lastID, nil
+}
+
+// setRunProgress 更新执行中的记录，GetLogRetentionStatus 同时读取
+func setRunProgress(run *LogRetentionRun, update func()) {
+	logRetention.mu.Lock()
+	defer logRetention.mu.Unlock()
+	update()
+}
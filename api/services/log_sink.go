@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"useradmin/api/config"
+	"useradmin/api/models"
+)
+
+// LogSink 请求日志的输出目标，WriteLogs 由该输出自己的写入协程按批调用，不需要支持并发
+type LogSink interface {
+	Name() string
+	WriteLogs(entries []models.Log) error
+	Close() error
+}
+
+// LogRecord 日志文件、syslog 等外部输出使用的日志格式
+type LogRecord struct {
+	ID        uint      `json:"id,omitempty"`
+	Time      time.Time `json:"time"`
+	TenantID  uint      `json:"tenant_id"`
+	RequestID string    `json:"request_id"`
+	Username  string    `json:"username"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Request   string    `json:"request,omitempty"`
+	Response  string    `json:"response,omitempty"`
+}
+
+// NewLogRecord 转换日志，尚未写入数据库的日志使用当前时间
+func NewLogRecord(l models.Log) LogRecord {
+	t := l.CreatedAt
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return LogRecord{
+		ID:        l.ID,
+		Time:      t,
+		TenantID:  l.TenantID,
+		RequestID: l.RequestID,
+		Username:  l.Username,
+		Method:    l.Action,
+		Path:      l.Resource,
+		Status:    l.Status,
+		IP:        l.IP,
+		UserAgent: l.UserAgent,
+		Request:   l.Request,
+		Response:  l.Response,
+	}
+}
+
+// NewLogSinks 按配置创建日志输出，多个输出时同时写入。每个输出最多排队
+// queue_size / batch_size 批，与总队列能容纳的日志条数相当
+func NewLogSinks(cfg config.LogConfig) (*LogFanout, error) {
+	fanout := &LogFanout{}
+	queueSize := cfg.QueueSize / cfg.BatchSize
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	for _, name := range cfg.Sinks {
+		var sink LogSink
+		var err error
+		switch name {
+		case "database":
+			sink = databaseSink{}
+		case "file":
+			sink, err = NewFileSink(cfg.File)
+		case "syslog":
+			sink, err = NewSyslogSink(cfg.Syslog)
+		default:
+			err = fmt.Errorf("未知的日志输出: %s", name)
+		}
+		if err != nil {
+			fanout.Close(context.Background())
+			return nil, err
+		}
+		fanout.add(sink, queueSize)
+	}
+	return fanout, nil
+}
+
+// databaseSink 写入 logs 表
+type databaseSink struct{}
+
+func (databaseSink) Name() string { return "database" }
+
+func (databaseSink) WriteLogs(entries []models.Log) error {
+	return config.DB.CreateInBatches(entries, len(entries)).Error
+}
+
+func (databaseSink) Close() error { return nil }
+
+// LogSinkStats 单个日志输出的统计信息
+type LogSinkStats struct {
+	Name    string `json:"name"`
+	Written uint64 `json:"written"`
+	Failed  uint64 `json:"failed"`
+	Dropped uint64 `json:"dropped"` // 该输出的队列已满时丢弃的条数
+	Queued  int    `json:"queued"`  // 排队等待写入的批数
+}
+
+// sinkWorker 一个输出的队列和写入协程
+type sinkWorker struct {
+	LogSink
+	batches chan []models.Log
+	done    chan struct{}
+
+	written atomic.Uint64
+	failed  atomic.Uint64
+	dropped atomic.Uint64
+}
+
+// run 逐批写入，失败时只计数并记录错误，不重试
+func (w *sinkWorker) run() {
+	defer close(w.done)
+	for batch := range w.batches {
+		if err := w.WriteLogs(batch); err != nil {
+			w.failed.Add(uint64(len(batch)))
+			log.Printf("写入 %d 条请求日志到 %s 失败: %v", len(batch), w.Name(), err)
+			continue
+		}
+		w.written.Add(uint64(len(batch)))
+	}
+}
+
+// finished 写入协程是否已退出
+func (w *sinkWorker) finished() bool {
+	select {
+	case <-w.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// LogFanout 将每批日志写入全部输出。每个输出有自己的队列和写入协程，
+// 某个输出失败或变慢（例如 syslog 连接超时）不影响其它输出，只会填满自己的队列
+type LogFanout struct {
+	sinks []*sinkWorker
+}
+
+// add 添加输出并启动其写入协程
+func (f *LogFanout) add(sink LogSink, queueSize int) {
+	w := &sinkWorker{LogSink: sink, batches: make(chan []models.Log, queueSize), done: make(chan struct{})}
+	f.sinks = append(f.sinks, w)
+	go w.run()
+}
+
+// WriteLogs 将一批日志放入各输出的队列，不等待写入完成；输出的队列已满时丢弃这批日志并返回错误
+func (f *LogFanout) WriteLogs(entries []models.Log) error {
+	var errs []error
+	for _, sink := range f.sinks {
+		// 数据库输出会回写ID，每个输出使用独立的副本
+		batch := append([]models.Log(nil), entries...)
+		select {
+		case sink.batches <- batch:
+		default:
+			sink.dropped.Add(uint64(len(entries)))
+			errs = append(errs, fmt.Errorf("%s: 队列已满", sink.Name()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close 停止接收日志，等待各输出写完队列中的日志后关闭输出。
+// ctx 到期时不再等待，尚未写完的输出不关闭，返回 ctx 的错误
+func (f *LogFanout) Close(ctx context.Context) error {
+	for _, sink := range f.sinks {
+		close(sink.batches)
+	}
+	var errs []error
+	for _, sink := range f.sinks {
+		select {
+		case <-sink.done:
+		case <-ctx.Done():
+			// 等待其它输出时超时，已经写完的输出仍然关闭
+			if sink.finished() {
+				break
+			}
+			log.Printf("日志输出 %s 写入超时，%d 批日志未写入", sink.Name(), len(sink.batches))
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), ctx.Err()))
+			continue
+		}
+		if err := sink.LogSink.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Stats 返回各输出的统计信息
+func (f *LogFanout) Stats() []LogSinkStats {
+	stats := make([]LogSinkStats, 0, len(f.sinks))
+	for _, sink := range f.sinks {
+		stats = append(stats, LogSinkStats{
+			Name:    sink.Name(),
+			Written: sink.written.Load(),
+			Failed:  sink.failed.Load(),
+			Dropped: sink.dropped.Load(),
+			Queued:  len(sink.batches),
+		})
+	}
+	return stats
+}
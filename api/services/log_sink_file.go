@@ -0,0 +1,140 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"useradmin/api/config"
+	"useradmin/api/models"
+)
+
+// FileSink 以 JSON Lines 格式追加写入日志文件，文件超过 MaxSize 后改名为
+// access-20060102T150405.000.log（精确到毫秒）并重新创建，只保留最近 MaxBackups 个轮转文件。
+// 同一毫秒内多次轮转时顺延到下一个未使用的毫秒，文件名的顺序仍与轮转顺序一致
+type FileSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	file *os.File
+	size int64
+}
+
+// NewFileSink 打开日志文件，目录不存在时自动创建
+func NewFileSink(cfg config.LogFileConfig) (*FileSink, error) {
+	s := &FileSink{
+		path:       cfg.Path,
+		maxSize:    int64(cfg.MaxSize) << 20,
+		maxBackups: cfg.MaxBackups,
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return nil, fmt.Errorf("创建日志目录失败: %w", err)
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) Name() string { return "file" }
+
+// WriteLogs 逐行写入，写满时在两条日志之间轮转。上次轮转后没能重新打开文件时先重新打开
+func (s *FileSink) WriteLogs(entries []models.Log) error {
+	if s.file == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	w := bufio.NewWriter(s.file)
+	for _, entry := range entries {
+		line, err := json.Marshal(NewLogRecord(entry))
+		if err != nil {
+			return err
+		}
+		line = append(line, '\n')
+
+		if s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			if err := s.rotate(); err != nil {
+				return err
+			}
+			w.Reset(s.file)
+		}
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+		s.size += int64(len(line))
+	}
+	return w.Flush()
+}
+
+func (s *FileSink) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("打开日志文件失败: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	s.file, s.size = file, info.Size()
+	return nil
+}
+
+// rotate 将当前文件改名为带时间的备份文件，重新创建日志文件并清理多余的备份
+func (s *FileSink) rotate() error {
+	// 关闭后无论成功与否都不再使用该文件，之后的步骤失败时由下次 WriteLogs 重新打开
+	err := s.file.Close()
+	s.file = nil
+	if err != nil {
+		return err
+	}
+	ext := filepath.Ext(s.path)
+	prefix := strings.TrimSuffix(s.path, ext) + "-"
+	backup := ""
+	for t := time.Now(); ; t = t.Add(time.Millisecond) {
+		backup = prefix + t.Format("20060102T150405.000") + ext
+		if _, err := os.Lstat(backup); err != nil {
+			break
+		}
+	}
+	if err := os.Rename(s.path, backup); err != nil {
+		return err
+	}
+	if err := s.open(); err != nil {
+		return err
+	}
+
+	if s.maxBackups <= 0 {
+		return nil
+	}
+	backups, err := filepath.Glob(prefix + "*" + ext)
+	if err != nil {
+		return err
+	}
+	// 文件名中的时间可以按字符串排序
+	sort.Strings(backups)
+	for len(backups) > s.maxBackups {
+		if err := os.Remove(backups[0]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"useradmin/api/config"
+	"useradmin/api/models"
+)
+
+// syslogSDID 结构化数据的ID，32473 为 RFC 5612 中保留给文档示例的企业号
+const syslogSDID = "request@32473"
+
+// syslogMaxMessage UDP 每条消息的最大长度，超出时截断消息正文
+const syslogMaxMessage = 2048
+
+// SyslogSink 以 RFC 5424 格式发送日志，UDP 每条日志一个数据包，
+// TCP 按 RFC 6587 的长度前缀分帧。首次写入时才连接，发送失败时重新连接一次再重试
+type SyslogSink struct {
+	network  string
+	address  string
+	facility int
+	appName  string
+	hostname string
+	procID   string
+
+	dial func(network, address string, timeout time.Duration) (net.Conn, error)
+	conn net.Conn
+}
+
+// NewSyslogSink 创建 syslog 输出。启动时不连接，syslog 暂时不可用不影响服务启动
+func NewSyslogSink(cfg config.LogSyslogConfig) (*SyslogSink, error) {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	appName := cfg.AppName
+	if appName == "" {
+		appName = "-"
+	}
+	s := &SyslogSink{
+		network:  cfg.Network,
+		address:  cfg.Address,
+		facility: cfg.Facility,
+		appName:  appName,
+		hostname: hostname,
+		procID:   strconv.Itoa(os.Getpid()),
+		dial:     net.DialTimeout,
+	}
+	return s, nil
+}
+
+func (s *SyslogSink) Name() string { return "syslog" }
+
+// WriteLogs 逐条发送，没有连接时先连接；发送失败时关闭连接，重新连接后重试该条，仍失败则返回错误
+func (s *SyslogSink) WriteLogs(entries []models.Log) error {
+	for _, entry := range entries {
+		msg := s.format(entry)
+		if s.network == "tcp" {
+			msg = strconv.Itoa(len(msg)) + " " + msg
+		}
+		// 连接可能已被对端关闭（例如 syslog 重启），重新连接后重试一次
+		if err := s.send([]byte(msg)); err != nil {
+			if err := s.send([]byte(msg)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// send 发送一条消息，失败时关闭连接，下次发送重新连接
+func (s *SyslogSink) send(msg []byte) error {
+	if s.conn == nil {
+		if err := s.connect(); err != nil {
+			return err
+		}
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if _, err := s.conn.Write(msg); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (s *SyslogSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+func (s *SyslogSink) connect() error {
+	conn, err := s.dial(s.network, s.address, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("连接 syslog 失败: %w", err)
+	}
+	s.conn = conn
+	return nil
+}
+
+// format 生成 RFC 5424 消息：<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] MSG
+func (s *SyslogSink) format(entry models.Log) string {
+	r := NewLogRecord(entry)
+	severity := 6 // informational
+	switch {
+	case r.Status >= 500:
+		severity = 3 // error
+	case r.Status >= 400:
+		severity = 4 // warning
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %s access ",
+		s.facility*8+severity, r.Time.Format(time.RFC3339Nano), s.hostname, s.appName, s.procID)
+	b.WriteString("[" + syslogSDID)
+	for _, param := range [][2]string{
+		{"request_id", r.RequestID},
+		{"tenant_id", strconv.FormatUint(uint64(r.TenantID), 10)},
+		{"username", r.Username},
+		{"method", r.Method},
+		{"path", r.Path},
+		{"status", strconv.Itoa(r.Status)},
+		{"ip", r.IP},
+		{"user_agent", r.UserAgent},
+	} {
+		b.WriteString(" " + param[0] + `="` + escapeSDValue(param[1]) + `"`)
+	}
+	b.WriteString("] ")
+	msg := fmt.Sprintf("%s %s %d %s", r.Method, r.Path, r.Status, r.Username)
+	if s.network == "udp" && b.Len()+len(msg) > syslogMaxMessage {
+		room := syslogMaxMessage - b.Len()
+		if room < 0 {
+			room = 0
+		}
+		// MSG 必须是有效的 UTF-8，不能从多字节字符中间截断
+		for room > 0 && !utf8.RuneStart(msg[room]) {
+			room--
+		}
+		msg = msg[:room]
+	}
+	b.WriteString(msg)
+	return b.String()
+}
+
+// escapeSDValue 转义结构化数据参数值中的 "、\ 和 ]
+func escapeSDValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(v)
+}
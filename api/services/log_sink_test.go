@@ -0,0 +1,405 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"useradmin/api/config"
+	"useradmin/api/models"
+)
+
+func testLog(id uint, status int) models.Log {
+	return models.Log{
+		Model:     gorm.Model{ID: id},
+		CreatedAt: time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC),
+		TenantID:  1,
+		RequestID: "req-" + strconv.Itoa(int(id)),
+		Username:  "admin",
+		Action:    "GET",
+		Resource:  "/api/users",
+		IP:        "10.0.0.1",
+		Status:    status,
+	}
+}
+
+// readLogIDs 读取 JSON Lines 日志文件中各条日志的ID
+func readLogIDs(t *testing.T, path string) []uint {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []uint
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var r LogRecord
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("invalid line %q: %v", line, err)
+		}
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logs", "access.log")
+	sink, err := NewFileSink(config.LogFileConfig{Path: path, MaxSize: 1, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	// 每个文件写两条，第三条写入前轮转
+	line, _ := json.Marshal(NewLogRecord(testLog(1, 200)))
+	sink.maxSize = int64(len(line)+1) * 2
+
+	for id := uint(1); id <= 7; id++ {
+		if err := sink.WriteLogs([]models.Log{testLog(id, 200)}); err != nil {
+			t.Fatal(err)
+		}
+		// 备份文件名精确到毫秒
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	if got := readLogIDs(t, path); len(got) != 1 || got[0] != 7 {
+		t.Errorf("current file ids = %v, want [7]", got)
+	}
+	backups, err := filepath.Glob(filepath.Join(dir, "logs", "access-*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("backups = %v, want 2 files", backups)
+	}
+	for i, want := range [][]uint{{3, 4}, {5, 6}} {
+		name := filepath.Base(backups[i])
+		if _, err := time.Parse("20060102T150405.000", strings.TrimSuffix(strings.TrimPrefix(name, "access-"), ".log")); err != nil {
+			t.Errorf("backup name %q: %v", name, err)
+		}
+		if got := readLogIDs(t, backups[i]); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+			t.Errorf("backup %s ids = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestFileSinkAppendsToExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	for id := uint(1); id <= 2; id++ {
+		sink, err := NewFileSink(config.LogFileConfig{Path: path, MaxSize: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := sink.WriteLogs([]models.Log{testLog(id, 200)}); err != nil {
+			t.Fatal(err)
+		}
+		sink.Close()
+	}
+	if got := readLogIDs(t, path); len(got) != 2 || got[0] != 1 || got[1] != 2 {
+		t.Errorf("ids = %v, want [1 2]", got)
+	}
+}
+
+func TestSyslogSinkUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	sink, err := NewSyslogSink(config.LogSyslogConfig{Network: "udp", Address: pc.LocalAddr().String(), Facility: 16, AppName: "useradmin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	entry := testLog(1, 500)
+	entry.Username = `a"b]c\`
+	long := testLog(2, 200)
+	long.Resource = "/api/" + strings.Repeat("x", 1500)
+	if err := sink.WriteLogs([]models.Log{entry, long}); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 65536)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(buf[:n])
+	// local0 (16) * 8 + error (3)
+	if !strings.HasPrefix(msg, "<131>1 2024-05-01T08:30:00Z ") {
+		t.Errorf("unexpected header: %q", msg)
+	}
+	for _, want := range []string{
+		" useradmin " + strconv.Itoa(os.Getpid()) + " access [request@32473 ",
+		`request_id="req-1"`,
+		`username="a\"b\]c\\"`,
+		`status="500"`,
+		`] GET /api/users 500 a"b]c\`,
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message %q does not contain %q", msg, want)
+		}
+	}
+
+	n, _, err = pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != syslogMaxMessage {
+		t.Errorf("long message size = %d, want truncated to %d", n, syslogMaxMessage)
+	}
+	if !strings.HasPrefix(string(buf[:n]), "<134>1 ") {
+		t.Errorf("unexpected header: %q", buf[:20])
+	}
+}
+
+// readSyslogFrame 读取一条 RFC 6587 长度前缀分帧的消息
+func readSyslogFrame(r *bufio.Reader) (string, error) {
+	size, err := r.ReadString(' ')
+	if err != nil {
+		return "", err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(size))
+	if err != nil {
+		return "", err
+	}
+	msg := make([]byte, n)
+	_, err = io.ReadFull(r, msg)
+	return string(msg), err
+}
+
+func TestSyslogSinkTCPConnectsLazily(t *testing.T) {
+	// 先占用一个端口再释放，syslog 尚未启动
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	sink, err := NewSyslogSink(config.LogSyslogConfig{Network: "tcp", Address: addr, Facility: 16, AppName: "useradmin"})
+	if err != nil {
+		t.Fatalf("NewSyslogSink should not dial: %v", err)
+	}
+	defer sink.Close()
+	if err := sink.WriteLogs([]models.Log{testLog(1, 200)}); err == nil {
+		t.Fatal("WriteLogs should fail while syslog is down")
+	}
+
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("port %s was taken: %v", addr, err)
+	}
+	defer l.Close()
+	frames := make(chan []string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			frames <- nil
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var got []string
+		for len(got) < 2 {
+			msg, err := readSyslogFrame(r)
+			if err != nil {
+				break
+			}
+			got = append(got, msg)
+		}
+		frames <- got
+	}()
+
+	if err := sink.WriteLogs([]models.Log{testLog(2, 200), testLog(3, 404)}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case got := <-frames:
+		if len(got) != 2 {
+			t.Fatalf("received %d frames, want 2", len(got))
+		}
+		if !strings.HasPrefix(got[0], "<134>1 ") || !strings.Contains(got[0], `request_id="req-2"`) {
+			t.Errorf("frame 1 = %q", got[0])
+		}
+		if !strings.HasPrefix(got[1], "<132>1 ") || !strings.Contains(got[1], `request_id="req-3"`) {
+			t.Errorf("frame 2 = %q", got[1])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for syslog frames")
+	}
+}
+
+func TestFileSinkRotatesWithinSameMillisecond(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "access.log")
+	sink, err := NewFileSink(config.LogFileConfig{Path: path, MaxSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	// 每条日志写入前都轮转，不等待时间变化
+	sink.maxSize = 1
+
+	for id := uint(1); id <= 5; id++ {
+		if err := sink.WriteLogs([]models.Log{testLog(id, 200)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	backups, err := filepath.Glob(filepath.Join(dir, "access-*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 4 {
+		t.Fatalf("backups = %v, want 4 files", backups)
+	}
+	for i, backup := range backups {
+		if got := readLogIDs(t, backup); len(got) != 1 || got[0] != uint(i+1) {
+			t.Errorf("backup %s ids = %v, want [%d]", filepath.Base(backup), got, i+1)
+		}
+	}
+}
+
+func TestFileSinkReopensAfterFailedRotation(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "logs")
+	path := filepath.Join(dir, "access.log")
+	sink, err := NewFileSink(config.LogFileConfig{Path: path, MaxSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	sink.maxSize = 1
+	if err := sink.WriteLogs([]models.Log{testLog(1, 200)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 目录被删除后轮转失败
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.WriteLogs([]models.Log{testLog(2, 200)}); err == nil {
+		t.Fatal("WriteLogs should fail when rotation fails")
+	}
+
+	// 目录恢复后重新打开文件继续写入
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.WriteLogs([]models.Log{testLog(3, 200)}); err != nil {
+		t.Fatalf("WriteLogs after the directory is restored: %v", err)
+	}
+	if got := readLogIDs(t, path); len(got) != 1 || got[0] != 3 {
+		t.Errorf("ids = %v, want [3]", got)
+	}
+}
+
+func TestSyslogSinkUDPTruncatesAtRuneBoundary(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	sink, err := NewSyslogSink(config.LogSyslogConfig{Network: "udp", Address: pc.LocalAddr().String(), Facility: 16, AppName: "useradmin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+
+	// 路径同时出现在结构化数据和正文中，逐个增加前缀长度，覆盖截断位置落在多字节字符各个字节上的情况
+	for pad := 0; pad < 3; pad++ {
+		entry := testLog(uint(pad+1), 200)
+		entry.Resource = "/api/" + strings.Repeat("x", pad) + strings.Repeat("商品", 200)
+		if err := sink.WriteLogs([]models.Log{entry}); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 65536)
+		pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n > syslogMaxMessage {
+			t.Errorf("pad %d: message size = %d, want at most %d", pad, n, syslogMaxMessage)
+		}
+		if !utf8.Valid(buf[:n]) {
+			t.Errorf("pad %d: message is not valid UTF-8: %q", pad, buf[n-8:n])
+		}
+	}
+}
+
+// recordingSink 记录每批日志的写入时间
+type recordingSink struct {
+	writes chan time.Time
+}
+
+func (s *recordingSink) Name() string { return "database" }
+func (s *recordingSink) WriteLogs([]models.Log) error {
+	s.writes <- time.Now()
+	return nil
+}
+func (s *recordingSink) Close() error { return nil }
+
+func TestLogFanoutSlowSyslogDoesNotDelayOtherSinks(t *testing.T) {
+	syslog, err := NewSyslogSink(config.LogSyslogConfig{Network: "tcp", Address: "192.0.2.1:514", Facility: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 地址不可达且没有响应，连接一直等到超时
+	blackhole := make(chan struct{})
+	defer close(blackhole)
+	syslog.dial = func(network, address string, timeout time.Duration) (net.Conn, error) {
+		select {
+		case <-blackhole:
+		case <-time.After(timeout):
+		}
+		return nil, errors.New("i/o timeout")
+	}
+	db := &recordingSink{writes: make(chan time.Time, 10)}
+
+	fanout := &LogFanout{}
+	fanout.add(db, 10)
+	fanout.add(syslog, 10)
+
+	start := time.Now()
+	for id := uint(1); id <= 3; id++ {
+		if err := fanout.WriteLogs([]models.Log{testLog(id, 200)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		select {
+		case at := <-db.writes:
+			if d := at.Sub(start); d > time.Second {
+				t.Errorf("batch %d written to the database after %v", i+1, d)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("batch %d was not written to the database while syslog was unreachable", i+1)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := fanout.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Close = %v, want the syslog sink to time out", err)
+	}
+	for _, stats := range fanout.Stats() {
+		if stats.Name == "database" && stats.Written != 3 {
+			t.Errorf("database written = %d, want 3", stats.Written)
+		}
+	}
+}
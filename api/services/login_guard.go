@@ -0,0 +1,242 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"useradmin/api/config"
+	"useradmin/api/models"
+)
+
+// LoginAttempt 某个用户名或IP的登录失败状态
+type LoginAttempt struct {
+	Failures    int
+	LastFailure time.Time
+	NextAllowed time.Time // 指数退避，此时间之前拒绝登录
+	LockedUntil time.Time // 达到失败上限后的锁定截止时间
+}
+
+// LoginAttemptStore 登录失败计数的存储，默认使用内存，多节点部署时可替换为共享存储。
+// 并发的登录失败不能丢失计数，Incr、Block 必须是原子操作（如 Redis 的 INCR+EXPIRE）
+type LoginAttemptStore interface {
+	Get(key string) (LoginAttempt, bool)
+	// Incr 失败次数加一并返回加一后的次数，记录至少保留 ttl
+	Incr(key string, ttl time.Duration) (int, error)
+	// Block 设置退避和锁定的截止时间，只会延后不会提前，记录至少保留 ttl
+	Block(key string, nextAllowed, lockedUntil time.Time, ttl time.Duration) error
+	Delete(key string)
+}
+
+// MemoryAttemptStore 基于内存的登录失败计数存储
+type MemoryAttemptStore struct {
+	mu    sync.Mutex
+	items map[string]memoryAttempt
+}
+
+type memoryAttempt struct {
+	attempt   LoginAttempt
+	expiresAt time.Time
+}
+
+// NewMemoryAttemptStore 创建内存存储
+func NewMemoryAttemptStore() *MemoryAttemptStore {
+	return &MemoryAttemptStore{items: make(map[string]memoryAttempt)}
+}
+
+func (s *MemoryAttemptStore) Get(key string) (LoginAttempt, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, ok := s.items[key]
+	if !ok {
+		return LoginAttempt{}, false
+	}
+	if time.Now().After(item.expiresAt) {
+		delete(s.items, key)
+		return LoginAttempt{}, false
+	}
+	return item.attempt, true
+}
+
+func (s *MemoryAttemptStore) Incr(key string, ttl time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	item := s.live(key, now)
+	item.attempt.Failures++
+	item.attempt.LastFailure = now
+	item.extend(now.Add(ttl))
+	s.items[key] = item
+
+	// 顺便清理过期的记录，避免大量随机用户名撑爆内存
+	for k, item := range s.items {
+		if now.After(item.expiresAt) {
+			delete(s.items, k)
+		}
+	}
+	return item.attempt.Failures, nil
+}
+
+func (s *MemoryAttemptStore) Block(key string, nextAllowed, lockedUntil time.Time, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	item := s.live(key, now)
+	if nextAllowed.After(item.attempt.NextAllowed) {
+		item.attempt.NextAllowed = nextAllowed
+	}
+	if lockedUntil.After(item.attempt.LockedUntil) {
+		item.attempt.LockedUntil = lockedUntil
+	}
+	item.extend(now.Add(ttl))
+	s.items[key] = item
+	return nil
+}
+
+// live 返回未过期的记录，调用方需持有锁
+func (s *MemoryAttemptStore) live(key string, now time.Time) memoryAttempt {
+	item, ok := s.items[key]
+	if !ok || now.After(item.expiresAt) {
+		return memoryAttempt{}
+	}
+	return item
+}
+
+func (m *memoryAttempt) extend(expiresAt time.Time) {
+	if expiresAt.After(m.expiresAt) {
+		m.expiresAt = expiresAt
+	}
+}
+
+func (s *MemoryAttemptStore) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items, key)
+}
+
+var attemptStore LoginAttemptStore = NewMemoryAttemptStore()
+
+// SetLoginAttemptStore 替换登录失败计数的存储
+func SetLoginAttemptStore(store LoginAttemptStore) {
+	attemptStore = store
+}
+
+func userKey(username string) string { return "user:" + username }
+func ipKey(ip string) string         { return "ip:" + ip }
+
+// CheckLogin 在校验密码前调用，返回需要等待的时长，0 表示允许尝试
+func CheckLogin(username, ip string) time.Duration {
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range []string{userKey(username), ipKey(ip)} {
+		attempt, ok := attemptStore.Get(key)
+		if !ok {
+			continue
+		}
+		for _, until := range []time.Time{attempt.LockedUntil, attempt.NextAllowed} {
+			if d := until.Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+	return wait
+}
+
+// RecordLoginFailure 记录一次登录失败，达到上限时锁定并记录锁定事件
+func RecordLoginFailure(username, ip string) {
+	cfg := config.GetConfig().Security
+	recordFailure(userKey(username), cfg.LoginMaxFailures, username, ip)
+	recordFailure(ipKey(ip), cfg.LoginIPMaxFailures, username, ip)
+}
+
+// RecordLoginSuccess 登录成功后清除该用户名的失败计数
+// IP 的计数不清除，避免攻击者用自己的账号重置计数
+func RecordLoginSuccess(username string) {
+	attemptStore.Delete(userKey(username))
+}
+
+// UnlockLogin 管理员解除用户名的锁定
+func UnlockLogin(username, operator, ip string) {
+	attemptStore.Delete(userKey(username))
+	recordSecurityEvent(username, "UNLOCK", ip, 200, fmt.Sprintf("用户 %s 被 %s 解除锁定", username, operator))
+}
+
+func recordFailure(key string, maxFailures int, username, ip string) {
+	cfg := config.GetConfig().Security
+	now := time.Now()
+	window := time.Minute * time.Duration(cfg.LoginFailureWindow)
+	lockout := time.Minute * time.Duration(cfg.LoginLockout)
+
+	// 以 Incr 返回的次数判断是否锁定，并发的失败各自得到不同的次数
+	failures, err := attemptStore.Incr(key, window)
+	if err != nil {
+		log.Printf("记录登录失败次数失败: %v", err)
+		return
+	}
+
+	var lockedUntil time.Time
+	ttl := window
+	if failures >= maxFailures {
+		lockedUntil = now.Add(lockout)
+		if lockout > ttl {
+			ttl = lockout
+		}
+	}
+	if err := attemptStore.Block(key, now.Add(backoff(failures)), lockedUntil, ttl); err != nil {
+		log.Printf("记录登录锁定状态失败: %v", err)
+		return
+	}
+	// 只在刚达到上限时记录一次锁定事件
+	if failures == maxFailures {
+		recordSecurityEvent(username, "LOCKOUT", ip, 429,
+			fmt.Sprintf("%s 连续登录失败 %d 次，锁定至 %s", key, failures, lockedUntil.Format(time.DateTime)))
+	}
+}
+
+// backoff 第 n 次失败后需要等待的时长：base * 2^(n-1)，不超过 max
+func backoff(failures int) time.Duration {
+	cfg := config.GetConfig().Security
+	if cfg.LoginBackoffBase <= 0 || failures <= 0 {
+		return 0
+	}
+	wait := time.Second * time.Duration(cfg.LoginBackoffBase)
+	max := time.Second * time.Duration(cfg.LoginBackoffMax)
+	for i := 1; i < failures && wait < max; i++ {
+		wait *= 2
+	}
+	if max > 0 && wait > max {
+		wait = max
+	}
+	return wait
+}
+
+// recordSecurityEvent 将锁定/解锁事件写入日志表
+func recordSecurityEvent(username, action, ip string, status int, message string) {
+	entry := models.Log{
+		TenantID: userTenantID(username),
+		Username: username,
+		Action:   action,
+		Resource: "/api/login",
+		IP:       ip,
+		Status:   status,
+		Response: message,
+	}
+	if err := config.DB.Create(&entry).Error; err != nil {
+		log.Printf("记录安全事件失败: %v", err)
+	}
+}
+
+// userTenantID 返回用户所属的租户，用户不存在时归入平台租户
+func userTenantID(username string) uint {
+	var tenantIDs []uint
+	config.DB.Model(&models.User{}).Where("username = ?", username).Limit(1).Pluck("tenant_id", &tenantIDs)
+	if len(tenantIDs) > 0 {
+		return tenantIDs[0]
+	}
+	tenantID, _ := PlatformTenantID()
+	return tenantID
+}
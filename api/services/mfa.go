@@ -0,0 +1,187 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"useradmin/api/config"
+	"useradmin/api/models"
+)
+
+const recoveryCodeCount = 10
+
+var (
+	ErrMFAInvalidCode    = errors.New("验证码错误")
+	ErrMFANotEnrolled    = errors.New("尚未设置两步验证")
+	ErrMFAAlreadyEnabled = errors.New("两步验证已启用")
+	ErrMFARequiredByRole = errors.New("当前角色要求必须启用两步验证")
+)
+
+// MFARequired 判断用户当前生效的任一角色是否强制要求两步验证，与 CheckPermission 一样不计未生效和已到期的角色
+func MFARequired(tx *gorm.DB, userID uint) (bool, error) {
+	roles, err := ActiveUserRoles(tx, userID, time.Now())
+	if err != nil {
+		return false, err
+	}
+	for _, role := range roles {
+		if role.RequireMFA {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// StartMFAEnrollment 为用户生成新的TOTP密钥，验证通过前不会启用
+func StartMFAEnrollment(user *models.User) (secret string, uri string, err error) {
+	if user.TOTPEnabled {
+		return "", "", ErrMFAAlreadyEnabled
+	}
+
+	secret, err = GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	if err := config.DB.Model(user).Updates(map[string]interface{}{
+		"totp_secret":       secret,
+		"totp_last_counter": 0,
+	}).Error; err != nil {
+		return "", "", err
+	}
+
+	return secret, TOTPURI(config.GetConfig().Security.MFAIssuer, user.Username, secret), nil
+}
+
+// ActivateMFA 校验首个验证码后启用两步验证，返回一次性恢复码
+func ActivateMFA(user *models.User, code string) ([]string, error) {
+	if user.TOTPEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrMFANotEnrolled
+	}
+
+	counter, ok := VerifyTOTP(user.TOTPSecret, code, time.Now(), user.TOTPLastCounter)
+	if !ok {
+		return nil, ErrMFAInvalidCode
+	}
+
+	var codes []string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_enabled":      true,
+			"totp_last_counter": counter,
+		}).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	return codes, err
+}
+
+// VerifyMFA 使用TOTP验证码或恢复码完成第二步验证
+func VerifyMFA(user *models.User, code, recoveryCode string) error {
+	if !user.TOTPEnabled {
+		return ErrMFANotEnrolled
+	}
+
+	if recoveryCode != "" {
+		result := config.DB.Model(&models.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashRecoveryCode(recoveryCode)).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrMFAInvalidCode
+		}
+		return nil
+	}
+
+	counter, ok := VerifyTOTP(user.TOTPSecret, code, time.Now(), user.TOTPLastCounter)
+	if !ok {
+		return ErrMFAInvalidCode
+	}
+	// 只有时间步大于已使用的时间步时才更新，并发提交同一个验证码时只有一个请求通过
+	result := config.DB.Model(&models.User{}).
+		Where("id = ? AND totp_last_counter < ?", user.ID, counter).
+		Update("totp_last_counter", counter)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrMFAInvalidCode
+	}
+	user.TOTPLastCounter = counter
+	return nil
+}
+
+// DisableMFA 关闭两步验证并删除恢复码，db 为调用方的事务时在其中执行
+func DisableMFA(db *gorm.DB, user *models.User) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]interface{}{
+			"totp_enabled":      false,
+			"totp_secret":       "",
+			"totp_last_counter": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，旧的恢复码全部作废
+func RegenerateRecoveryCodes(user *models.User) ([]string, error) {
+	if !user.TOTPEnabled {
+		return nil, ErrMFANotEnrolled
+	}
+
+	var codes []string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	return codes, err
+}
+
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		if err := tx.Create(&models.RecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(code)}).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// newRecoveryCode 生成形如 abcde-fghij 的恢复码
+func newRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(totpEncoding.EncodeToString(b))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"useradmin/api/config"
+	"useradmin/api/models"
+)
+
+// ErrPasswordReused 新密码与最近使用过的密码相同
+var ErrPasswordReused = errors.New("不能使用最近用过的密码")
+
+// PasswordPolicyError 密码不符合策略，包含所有未满足的规则
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "密码不符合要求: " + strings.Join(e.Violations, "; ")
+}
+
+// ValidatePassword 按配置的密码策略校验密码
+func ValidatePassword(username, password string) error {
+	policy := config.GetConfig().Password
+
+	var violations []string
+	if len([]rune(password)) < policy.MinLength {
+		violations = append(violations, "长度不能少于"+strconv.Itoa(policy.MinLength)+"位")
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || r == ' ':
+			hasSymbol = true
+		}
+	}
+	if policy.RequireUpper && !hasUpper {
+		violations = append(violations, "必须包含大写字母")
+	}
+	if policy.RequireLower && !hasLower {
+		violations = append(violations, "必须包含小写字母")
+	}
+	if policy.RequireDigit && !hasDigit {
+		violations = append(violations, "必须包含数字")
+	}
+	if policy.RequireSymbol && !hasSymbol {
+		violations = append(violations, "必须包含特殊字符")
+	}
+
+	if username != "" && strings.EqualFold(password, username) {
+		violations = append(violations, "不能与用户名相同")
+	}
+	for _, banned := range policy.Banned {
+		if strings.EqualFold(password, banned) {
+			violations = append(violations, "密码过于常见")
+			break
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// SetPassword 校验并设置用户密码，记录密码历史
+// mustChange 为 true 时用户下次登录需要修改密码（例如由管理员设置的密码）
+// 新建用户时 user.ID 为 0，只设置字段，调用方保存用户后需要调用 RecordPasswordHistory
+func SetPassword(tx *gorm.DB, user *models.User, password string, mustChange bool) error {
+	if err := ValidatePassword(user.Username, password); err != nil {
+		return err
+	}
+
+	if user.ID != 0 {
+		if err := checkPasswordHistory(tx, user, password); err != nil {
+			return err
+		}
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	user.Password = string(hashedPassword)
+	user.PasswordChangedAt = &now
+	user.MustChangePassword = mustChange
+
+	if user.ID == 0 {
+		return nil
+	}
+	if err := tx.Model(user).Updates(map[string]interface{}{
+		"password":             user.Password,
+		"password_changed_at":  user.PasswordChangedAt,
+		"must_change_password": user.MustChangePassword,
+	}).Error; err != nil {
+		return err
+	}
+	return RecordPasswordHistory(tx, user)
+}
+
+// RecordPasswordHistory 记录当前密码哈希，只保留最近 history_size 条
+func RecordPasswordHistory(tx *gorm.DB, user *models.User) error {
+	size := config.GetConfig().Password.HistorySize
+	if size <= 0 {
+		return nil
+	}
+
+	if err := tx.Create(&models.PasswordHistory{UserID: user.ID, Hash: user.Password}).Error; err != nil {
+		return err
+	}
+
+	var keep []uint
+	if err := tx.Model(&models.PasswordHistory{}).
+		Where("user_id = ?", user.ID).
+		Order("id DESC").
+		Limit(size).
+		Pluck("id", &keep).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().
+		Where("user_id = ? AND id NOT IN ?", user.ID, keep).
+		Delete(&models.PasswordHistory{}).Error
+}
+
+// PasswordExpired 判断用户密码是否需要修改（已过期或被要求修改）
+func PasswordExpired(user models.User) bool {
+	if user.MustChangePassword {
+		return true
+	}
+	maxAge := config.GetConfig().Password.MaxAge
+	if maxAge <= 0 || user.PasswordChangedAt == nil {
+		return false
+	}
+	return time.Since(*user.PasswordChangedAt) > time.Hour*24*time.Duration(maxAge)
+}
+
+// checkPasswordHistory 新密码不能与当前密码及最近使用过的密码相同
+func checkPasswordHistory(tx *gorm.DB, user *models.User, password string) error {
+	size := config.GetConfig().Password.HistorySize
+
+	hashes := []string{user.Password}
+	if size > 0 {
+		var history []models.PasswordHistory
+		if err := tx.Where("user_id = ?", user.ID).Order("id DESC").Limit(size).Find(&history).Error; err != nil {
+			return err
+		}
+		for _, h := range history {
+			hashes = append(hashes, h.Hash)
+		}
+	}
+
+	for _, hash := range hashes {
+		if hash != "" && bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+			return ErrPasswordReused
+		}
+	}
+	return nil
+}
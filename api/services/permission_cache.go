@@ -0,0 +1,136 @@
+package services
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"useradmin/api/config"
+)
+
+// PermissionCacheStats 权限缓存的统计信息
+type PermissionCacheStats struct {
+	Size          int     `json:"size"`
+	Hits          uint64  `json:"hits"`
+	Misses        uint64  `json:"misses"`
+	HitRate       float64 `json:"hit_rate"`
+	Invalidations uint64  `json:"invalidations"`
+	TTLSeconds    int     `json:"ttl_seconds"`
+}
+
+type cacheEntry struct {
+	subject   *Subject
+	expiresAt time.Time
+}
+
+// subjectCache 以用户名为键的进程内权限缓存
+type subjectCache struct {
+	mu      sync.RWMutex
+	entries map[string]cacheEntry
+
+	hits          atomic.Uint64
+	misses        atomic.Uint64
+	invalidations atomic.Uint64
+
+	// 每次失效时递增，避免失效前从数据库读到的旧数据在失效后写入缓存
+	generation atomic.Uint64
+}
+
+var permissionCache = &subjectCache{entries: make(map[string]cacheEntry)}
+
+func (c *subjectCache) ttl() time.Duration {
+	return time.Second * time.Duration(config.GetConfig().Security.PermissionCacheTTL)
+}
+
+func (c *subjectCache) get(username string) (*Subject, bool) {
+	c.mu.RLock()
+	entry, ok := c.entries[username]
+	c.mu.RUnlock()
+
+	if !ok || time.Now().After(entry.expiresAt) {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.hits.Add(1)
+	return entry.subject, true
+}
+
+func (c *subjectCache) set(username string, subject *Subject, generation uint64) {
+	ttl := c.ttl()
+	if ttl <= 0 {
+		return
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	if !subject.GrantsChangeAt.IsZero() && subject.GrantsChangeAt.Before(expiresAt) {
+		expiresAt = subject.GrantsChangeAt
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generation.Load() != generation {
+		return
+	}
+	c.entries[username] = cacheEntry{subject: subject, expiresAt: expiresAt}
+
+	// 缓存条目数量较多时顺便清理过期条目
+	if len(c.entries) > 1024 {
+		for k, e := range c.entries {
+			if now.After(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+	}
+}
+
+// invalidate 删除满足条件的缓存条目
+func (c *subjectCache) invalidate(match func(*Subject) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, e := range c.entries {
+		if match(e.subject) {
+			delete(c.entries, k)
+		}
+	}
+	c.generation.Add(1)
+	c.invalidations.Add(1)
+}
+
+// InvalidateUserPermissions 用户信息、状态或角色变更后清除该用户的缓存
+func InvalidateUserPermissions(userID uint) {
+	permissionCache.invalidate(func(s *Subject) bool { return s.UserID == userID })
+}
+
+// InvalidateRolePermissions 角色权限、继承关系变更或删除后清除该角色及其子角色下所有用户的缓存
+func InvalidateRolePermissions(roleID uint) {
+	permissionCache.invalidate(func(s *Subject) bool { return s.InheritsRole(roleID) })
+}
+
+// InvalidateTenantPermissions 租户启用、停用后清除该租户所有用户的缓存
+func InvalidateTenantPermissions(tenantID uint) {
+	permissionCache.invalidate(func(s *Subject) bool { return s.TenantID == tenantID })
+}
+
+// InvalidateAllPermissions 权限定义变更后清除全部缓存
+func InvalidateAllPermissions() {
+	permissionCache.invalidate(func(*Subject) bool { return true })
+}
+
+// GetPermissionCacheStats 返回权限缓存的命中率等统计信息
+func GetPermissionCacheStats() PermissionCacheStats {
+	permissionCache.mu.RLock()
+	size := len(permissionCache.entries)
+	permissionCache.mu.RUnlock()
+
+	stats := PermissionCacheStats{
+		Size:          size,
+		Hits:          permissionCache.hits.Load(),
+		Misses:        permissionCache.misses.Load(),
+		Invalidations: permissionCache.invalidations.Load(),
+		TTLSeconds:    config.GetConfig().Security.PermissionCacheTTL,
+	}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
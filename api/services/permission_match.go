@@ -0,0 +1,119 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"useradmin/api/config"
+	"useradmin/api/models"
+)
+
+// 权限代码由":"分隔的多段组成，例如 product:image:upload
+const (
+	permissionSeparator = ":"
+	permissionWildcard  = "*"
+)
+
+// ErrInvalidPermissionCode 权限代码格式错误
+var ErrInvalidPermissionCode = errors.New("权限代码格式错误，应为 module:action，可使用 * 通配，例如 product:* 或 product:image:upload")
+
+// MatchPermission 判断授予的权限 grant 是否覆盖所需的权限 required
+//   - 各段逐一比较，"*" 匹配任意一段：*:list 覆盖 user:list
+//   - 末段为 "*" 时覆盖后续任意层级：product:* 覆盖 product:image:upload
+//   - 上级权限覆盖下级权限：product:image 覆盖 product:image:upload
+func MatchPermission(grant, required string) bool {
+	if grant == required {
+		return true
+	}
+
+	g := strings.Split(grant, permissionSeparator)
+	r := strings.Split(required, permissionSeparator)
+	if len(g) > len(r) {
+		return false
+	}
+	for i, seg := range g {
+		if seg == permissionWildcard {
+			if i == len(g)-1 {
+				return true
+			}
+			continue
+		}
+		if seg != r[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchAnyPermission 判断一组授予的权限中是否有覆盖 required 的
+func MatchAnyPermission(grants []string, required string) bool {
+	for _, grant := range grants {
+		if MatchPermission(grant, required) {
+			return true
+		}
+	}
+	return false
+}
+
+// PermissionModule 返回权限代码所属的模块（第一段）
+func PermissionModule(code string) string {
+	return strings.SplitN(code, permissionSeparator, 2)[0]
+}
+
+// IsWildcardPermission 判断权限代码是否包含通配符
+func IsWildcardPermission(code string) bool {
+	for _, seg := range strings.Split(code, permissionSeparator) {
+		if seg == permissionWildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidatePermissionCode 校验权限代码格式：至少两段，每段为小写字母、数字、下划线、中划线或单独的 *
+func ValidatePermissionCode(code string) error {
+	segments := strings.Split(code, permissionSeparator)
+	if len(segments) < 2 {
+		return ErrInvalidPermissionCode
+	}
+	for _, seg := range segments {
+		if seg == permissionWildcard {
+			continue
+		}
+		if seg == "" {
+			return ErrInvalidPermissionCode
+		}
+		for _, r := range seg {
+			if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+				return ErrInvalidPermissionCode
+			}
+		}
+	}
+	return nil
+}
+
+// ExpandPermissions 将授予的权限（可能含通配符）展开为权限表中具体的权限代码，
+// 供前端按具体代码判断菜单和按钮
+func ExpandPermissions(grants []string) ([]string, error) {
+	if len(grants) == 0 {
+		return []string{}, nil
+	}
+
+	var codes []string
+	if err := config.DB.Model(&models.Permission{}).Pluck("code", &codes).Error; err != nil {
+		return nil, err
+	}
+
+	result := []string{}
+	for _, code := range codes {
+		if IsWildcardPermission(code) {
+			continue
+		}
+		if MatchAnyPermission(grants, code) {
+			result = append(result, code)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+
+	"gorm.io/gorm"
+	"useradmin/api/models"
+)
+
+// PermissionDefinition 系统内置的权限定义，启动时写入权限表
+type PermissionDefinition struct {
+	Code        string
+	Name        string
+	Description string
+}
+
+// RoutePermission 接口与所需权限的对应关系
+type RoutePermission struct {
+	Method     string
+	Path       string
+	Permission string
+}
+
+// permissionRegistry 权限注册表：内置权限定义，以及每个接口声明的权限
+type permissionRegistry struct {
+	mu          sync.RWMutex
+	definitions []PermissionDefinition
+	index       map[string]int
+	routes      []RoutePermission
+}
+
+var registry = &permissionRegistry{index: make(map[string]int)}
+
+// DefinePermissions 声明内置权限，权限代码格式错误或重复声明时返回错误
+func DefinePermissions(defs ...PermissionDefinition) error {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	for _, def := range defs {
+		if err := ValidatePermissionCode(def.Code); err != nil {
+			return fmt.Errorf("权限 %s: %w", def.Code, err)
+		}
+		if _, ok := registry.index[def.Code]; ok {
+			return fmt.Errorf("权限 %s 重复声明", def.Code)
+		}
+		registry.index[def.Code] = len(registry.definitions)
+		registry.definitions = append(registry.definitions, def)
+	}
+	return nil
+}
+
+// BindRoutePermission 登记接口所需的权限，权限必须已经声明且不能含通配符
+func BindRoutePermission(method, path, code string) error {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if _, ok := registry.index[code]; !ok {
+		return fmt.Errorf("%s %s 使用了未声明的权限 %s", method, path, code)
+	}
+	if IsWildcardPermission(code) {
+		return fmt.Errorf("%s %s 不能要求通配权限 %s", method, path, code)
+	}
+	registry.routes = append(registry.routes, RoutePermission{Method: method, Path: path, Permission: code})
+	return nil
+}
+
+// PermissionDefinitions 返回全部内置权限，按声明顺序
+func PermissionDefinitions() []PermissionDefinition {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	return append([]PermissionDefinition(nil), registry.definitions...)
+}
+
+// LookupPermissionDefinition 查询内置权限定义
+func LookupPermissionDefinition(code string) (PermissionDefinition, bool) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	i, ok := registry.index[code]
+	if !ok {
+		return PermissionDefinition{}, false
+	}
+	return registry.definitions[i], true
+}
+
+// RoutePermissions 返回全部接口与权限的对应关系，按注册顺序
+func RoutePermissions() []RoutePermission {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	return append([]RoutePermission(nil), registry.routes...)
+}
+
+// SeedPermissions 将内置权限写入权限表，已存在的权限保持不变（管理员可能修改过名称）
+func SeedPermissions(db *gorm.DB) error {
+	for _, def := range PermissionDefinitions() {
+		var count int64
+		if err := db.Model(&models.Permission{}).Where("code = ?", def.Code).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		permission := models.Permission{Code: def.Code, Name: def.Name, Description: def.Description}
+		if err := db.Create(&permission).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,457 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+
+	"useradmin/api/models"
+)
+
+// ErrInvalidPolicy 策略格式错误
+var ErrInvalidPolicy = errors.New("策略格式错误")
+
+// 属性命名空间：条件中的属性写作 命名空间.属性名，例如 subject.id、resource.status、env.hour
+const (
+	AttrSubject  = "subject"
+	AttrResource = "resource"
+	AttrEnv      = "env"
+)
+
+// 条件运算符
+const (
+	PolicyOpEq          = "eq"
+	PolicyOpNe          = "ne"
+	PolicyOpGt          = "gt"
+	PolicyOpGte         = "gte"
+	PolicyOpLt          = "lt"
+	PolicyOpLte         = "lte"
+	PolicyOpIn          = "in"           // 属性值在 value 列表中
+	PolicyOpNotIn       = "not_in"       // 属性值不在 value 列表中
+	PolicyOpContains    = "contains"     // 属性（列表或字符串）包含 value
+	PolicyOpNotContains = "not_contains" // 属性（列表或字符串）不包含 value
+	PolicyOpBetween     = "between"      // value 为 [最小值, 最大值]，包含两端
+	PolicyOpNotBetween  = "not_between"
+	PolicyOpCIDR        = "cidr"   // IP 属性在 value 指定的网段内，value 可以是一个或多个网段
+	PolicyOpExists      = "exists" // value 为 true 时要求属性存在，false 时要求属性不存在
+)
+
+var policyOps = map[string]bool{
+	PolicyOpEq: true, PolicyOpNe: true, PolicyOpGt: true, PolicyOpGte: true, PolicyOpLt: true, PolicyOpLte: true,
+	PolicyOpIn: true, PolicyOpNotIn: true, PolicyOpContains: true, PolicyOpNotContains: true,
+	PolicyOpBetween: true, PolicyOpNotBetween: true, PolicyOpCIDR: true, PolicyOpExists: true,
+}
+
+// PolicyCondition 策略条件：将属性 Attr 与常量 Value 或另一个属性 Ref 比较
+type PolicyCondition struct {
+	Attr  string `json:"attr"`
+	Op    string `json:"op"`
+	Value any    `json:"value,omitempty"`
+	Ref   string `json:"ref,omitempty"`
+}
+
+// Attributes 某一命名空间下的属性
+type Attributes map[string]any
+
+// PolicyContext 策略评估时可用的全部属性，资源属性只有在控制器加载了资源后才有
+type PolicyContext struct {
+	Subject  Attributes
+	Resource Attributes
+	Env      Attributes
+}
+
+// Lookup 按 命名空间.属性名 查找属性
+func (ctx PolicyContext) Lookup(path string) (any, bool) {
+	ns, name, ok := strings.Cut(path, ".")
+	if !ok {
+		return nil, false
+	}
+	var attrs Attributes
+	switch ns {
+	case AttrSubject:
+		attrs = ctx.Subject
+	case AttrResource:
+		attrs = ctx.Resource
+	case AttrEnv:
+		attrs = ctx.Env
+	}
+	v, ok := attrs[name]
+	return v, ok
+}
+
+// resourcePermissions 控制器加载资源后会带上资源属性再次调用 Authorize 的权限（见 authorizeResource），
+// 使用这些权限、操作已有资源的接口都必须再次检查；只有这些权限的策略可以使用 resource. 属性
+var resourcePermissions = map[string]bool{
+	"user:update":    true,
+	"user:delete":    true,
+	"product:update": true,
+	"product:status": true,
+	"product:delete": true,
+}
+
+// IsResourcePermission 该权限在加载资源后是否会再次评估策略
+func IsResourcePermission(permission string) bool {
+	return resourcePermissions[permission]
+}
+
+// usesResource 条件的属性或引用是否为资源属性
+func (cond PolicyCondition) usesResource() bool {
+	return strings.HasPrefix(cond.Attr, AttrResource+".") || strings.HasPrefix(cond.Ref, AttrResource+".")
+}
+
+// CompiledPolicy 已解析条件的策略
+type CompiledPolicy struct {
+	ID         uint
+	Name       string
+	Effect     string
+	Permission string
+	Conditions []PolicyCondition
+}
+
+// UsesResource 策略是否有条件使用了资源属性
+func (p CompiledPolicy) UsesResource() bool {
+	for _, cond := range p.Conditions {
+		if cond.usesResource() {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidatePolicyScope 保存策略前校验资源属性的使用：路由级检查时还没有资源属性，
+// 使用资源属性的允许策略无法放行请求；拒绝策略只能针对加载资源后会再次评估的具体权限
+func ValidatePolicyScope(p CompiledPolicy) error {
+	if !p.UsesResource() {
+		return nil
+	}
+	if p.Effect == models.PolicyEffectAllow {
+		return fmt.Errorf("%w：允许策略不能使用 resource. 属性，请通过角色授权，再用拒绝策略按资源限制", ErrInvalidPolicy)
+	}
+	if IsResourcePermission(p.Permission) {
+		return nil
+	}
+	codes := make([]string, 0, len(resourcePermissions))
+	for code := range resourcePermissions {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return fmt.Errorf("%w：只有 %s 的拒绝策略可以使用 resource. 属性", ErrInvalidPolicy, strings.Join(codes, "、"))
+}
+
+// CompilePolicy 校验策略并解析条件
+func CompilePolicy(p models.Policy) (CompiledPolicy, error) {
+	if p.Effect != models.PolicyEffectAllow && p.Effect != models.PolicyEffectDeny {
+		return CompiledPolicy{}, fmt.Errorf("%w：effect 只能是 allow 或 deny", ErrInvalidPolicy)
+	}
+	if err := ValidatePermissionCode(p.Permission); err != nil {
+		return CompiledPolicy{}, err
+	}
+	var conditions []PolicyCondition
+	if strings.TrimSpace(p.Conditions) != "" {
+		if err := json.Unmarshal([]byte(p.Conditions), &conditions); err != nil {
+			return CompiledPolicy{}, fmt.Errorf("%w：条件不是有效的 JSON 数组", ErrInvalidPolicy)
+		}
+	}
+	for i, cond := range conditions {
+		if err := validateCondition(cond); err != nil {
+			return CompiledPolicy{}, fmt.Errorf("%w：第 %d 个条件%s", ErrInvalidPolicy, i+1, err.Error())
+		}
+	}
+	return CompiledPolicy{ID: p.ID, Name: p.Name, Effect: p.Effect, Permission: p.Permission, Conditions: conditions}, nil
+}
+
+func validateCondition(cond PolicyCondition) error {
+	if !validAttrPath(cond.Attr) {
+		return fmt.Errorf("的属性 %q 无效，应以 subject.、resource. 或 env. 开头", cond.Attr)
+	}
+	if !policyOps[cond.Op] {
+		return fmt.Errorf("的运算符 %q 不支持", cond.Op)
+	}
+	if cond.Ref != "" {
+		if !validAttrPath(cond.Ref) {
+			return fmt.Errorf("引用的属性 %q 无效", cond.Ref)
+		}
+		return nil
+	}
+	switch cond.Op {
+	case PolicyOpIn, PolicyOpNotIn:
+		if _, ok := asList(cond.Value); !ok {
+			return errors.New("的值应为列表")
+		}
+	case PolicyOpBetween, PolicyOpNotBetween:
+		if list, ok := asList(cond.Value); !ok || len(list) != 2 {
+			return errors.New("的值应为 [最小值, 最大值]")
+		}
+	case PolicyOpCIDR:
+		if _, err := parseCIDRs(cond.Value); err != nil {
+			return errors.New("的值应为网段，例如 10.0.0.0/8")
+		}
+	case PolicyOpExists:
+		if _, ok := cond.Value.(bool); !ok {
+			return errors.New("的值应为 true 或 false")
+		}
+	default:
+		if cond.Value == nil {
+			return errors.New("缺少比较的值")
+		}
+	}
+	return nil
+}
+
+func validAttrPath(path string) bool {
+	ns, name, ok := strings.Cut(path, ".")
+	return ok && name != "" && (ns == AttrSubject || ns == AttrResource || ns == AttrEnv)
+}
+
+// ConditionResult 单个条件的评估结果
+type ConditionResult struct {
+	PolicyCondition
+	Actual  any
+	Found   bool // 属性是否存在
+	Matched bool
+}
+
+// PolicyMatch 单个策略的评估结果
+type PolicyMatch struct {
+	ID         uint
+	Name       string
+	Effect     string
+	Permission string
+	Matched    bool // 全部条件满足
+	Conditions []ConditionResult
+}
+
+// PolicyResult 策略评估结果
+type PolicyResult struct {
+	// 最终效果：deny 优先于 allow，没有策略生效时为空
+	Effect string
+	// 决定最终效果的策略
+	Decisive *PolicyMatch
+	// 适用于该权限的全部策略及其条件的评估过程
+	Policies []PolicyMatch
+}
+
+// Denied 是否有拒绝策略生效
+func (r PolicyResult) Denied() bool {
+	return r.Effect == models.PolicyEffectDeny
+}
+
+// Allowed 是否有允许策略生效（且没有拒绝策略生效）
+func (r PolicyResult) Allowed() bool {
+	return r.Effect == models.PolicyEffectAllow
+}
+
+// EvaluatePolicies 评估适用于 permission 的策略。
+// 策略的全部条件满足时生效；任一拒绝策略生效则结果为拒绝，否则任一允许策略生效则结果为允许。
+// 条件中的属性或引用不存在时条件不满足（exists 运算符除外）。
+func EvaluatePolicies(policies []CompiledPolicy, permission string, ctx PolicyContext) PolicyResult {
+	result := PolicyResult{Policies: []PolicyMatch{}}
+	for _, p := range policies {
+		if !MatchPermission(p.Permission, permission) {
+			continue
+		}
+		match := PolicyMatch{ID: p.ID, Name: p.Name, Effect: p.Effect, Permission: p.Permission, Matched: true}
+		for _, cond := range p.Conditions {
+			cr := evaluateCondition(cond, ctx)
+			match.Conditions = append(match.Conditions, cr)
+			if !cr.Matched {
+				match.Matched = false
+			}
+		}
+		result.Policies = append(result.Policies, match)
+	}
+
+	for _, effect := range []string{models.PolicyEffectDeny, models.PolicyEffectAllow} {
+		for i := range result.Policies {
+			if result.Policies[i].Matched && result.Policies[i].Effect == effect {
+				result.Effect = effect
+				result.Decisive = &result.Policies[i]
+				return result
+			}
+		}
+	}
+	return result
+}
+
+func evaluateCondition(cond PolicyCondition, ctx PolicyContext) ConditionResult {
+	actual, found := ctx.Lookup(cond.Attr)
+	cr := ConditionResult{PolicyCondition: cond, Actual: actual, Found: found}
+
+	if cond.Op == PolicyOpExists {
+		want, _ := cond.Value.(bool)
+		cr.Matched = found == want
+		return cr
+	}
+	if !found {
+		return cr
+	}
+
+	expected := cond.Value
+	if cond.Ref != "" {
+		var ok bool
+		if expected, ok = ctx.Lookup(cond.Ref); !ok {
+			return cr
+		}
+	}
+	cr.Matched = compare(cond.Op, actual, expected)
+	return cr
+}
+
+func compare(op string, actual, expected any) bool {
+	switch op {
+	case PolicyOpEq:
+		return equalValues(actual, expected)
+	case PolicyOpNe:
+		return !equalValues(actual, expected)
+	case PolicyOpGt, PolicyOpGte, PolicyOpLt, PolicyOpLte:
+		c, ok := orderValues(actual, expected)
+		if !ok {
+			return false
+		}
+		switch op {
+		case PolicyOpGt:
+			return c > 0
+		case PolicyOpGte:
+			return c >= 0
+		case PolicyOpLt:
+			return c < 0
+		default:
+			return c <= 0
+		}
+	case PolicyOpIn, PolicyOpNotIn:
+		list, ok := asList(expected)
+		if !ok {
+			return false
+		}
+		return containsValue(list, actual) == (op == PolicyOpIn)
+	case PolicyOpContains, PolicyOpNotContains:
+		var has bool
+		if s, ok := actual.(string); ok {
+			has = strings.Contains(s, fmt.Sprint(expected))
+		} else if list, ok := asList(actual); ok {
+			has = containsValue(list, expected)
+		} else {
+			return false
+		}
+		return has == (op == PolicyOpContains)
+	case PolicyOpBetween, PolicyOpNotBetween:
+		bounds, ok := asList(expected)
+		if !ok || len(bounds) != 2 {
+			return false
+		}
+		lo, ok1 := orderValues(actual, bounds[0])
+		hi, ok2 := orderValues(actual, bounds[1])
+		if !ok1 || !ok2 {
+			return false
+		}
+		return (lo >= 0 && hi <= 0) == (op == PolicyOpBetween)
+	case PolicyOpCIDR:
+		ip := net.ParseIP(fmt.Sprint(actual))
+		nets, err := parseCIDRs(expected)
+		if ip == nil || err != nil {
+			return false
+		}
+		for _, n := range nets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// equalValues 数值按大小比较，其他按字符串形式比较
+func equalValues(a, b any) bool {
+	if x, ok := toNumber(a); ok {
+		if y, ok := toNumber(b); ok {
+			return x == y
+		}
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+// orderValues 比较两个数值或两个字符串（例如 "09:00"），类型不同时无法比较
+func orderValues(a, b any) (int, bool) {
+	if x, ok := toNumber(a); ok {
+		y, ok := toNumber(b)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	}
+	x, ok1 := a.(string)
+	y, ok2 := b.(string)
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	return strings.Compare(x, y), true
+}
+
+func toNumber(v any) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	if n, ok := v.(json.Number); ok {
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// asList 将切片或数组转换为 []any，字符串不视为列表
+func asList(v any) ([]any, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	list := make([]any, rv.Len())
+	for i := range list {
+		list[i] = rv.Index(i).Interface()
+	}
+	return list, true
+}
+
+func containsValue(list []any, v any) bool {
+	for _, item := range list {
+		if equalValues(item, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseCIDRs(v any) ([]*net.IPNet, error) {
+	values, ok := asList(v)
+	if !ok {
+		values = []any{v}
+	}
+	nets := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		s, ok := value.(string)
+		if !ok {
+			return nil, ErrInvalidPolicy
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"useradmin/api/config"
+	"useradmin/api/models"
+)
+
+type policyCacheEntry struct {
+	policies  []CompiledPolicy
+	expiresAt time.Time
+}
+
+// policyCache 以租户为键缓存已启用的策略，有效期与权限缓存相同
+type policyCache struct {
+	mu         sync.RWMutex
+	entries    map[uint]policyCacheEntry
+	generation atomic.Uint64
+}
+
+var tenantPolicies = &policyCache{entries: make(map[uint]policyCacheEntry)}
+
+// LoadPolicies 加载租户已启用的策略（带缓存）
+func LoadPolicies(tenantID uint) ([]CompiledPolicy, error) {
+	tenantPolicies.mu.RLock()
+	entry, ok := tenantPolicies.entries[tenantID]
+	tenantPolicies.mu.RUnlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.policies, nil
+	}
+
+	generation := tenantPolicies.generation.Load()
+	var rows []models.Policy
+	if err := config.DB.Where("tenant_id = ? AND enabled = ?", tenantID, true).Order("id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	// 保存时已校验过，这里出错说明数据被直接修改过，拒绝使用以免漏掉拒绝策略
+	policies := make([]CompiledPolicy, 0, len(rows))
+	for _, row := range rows {
+		p, err := CompilePolicy(row)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+
+	ttl := time.Second * time.Duration(config.GetConfig().Security.PermissionCacheTTL)
+	if ttl > 0 {
+		tenantPolicies.mu.Lock()
+		if tenantPolicies.generation.Load() == generation {
+			tenantPolicies.entries[tenantID] = policyCacheEntry{policies: policies, expiresAt: time.Now().Add(ttl)}
+		}
+		tenantPolicies.mu.Unlock()
+	}
+	return policies, nil
+}
+
+// InvalidatePolicies 策略变更后清除租户的策略缓存
+func InvalidatePolicies(tenantID uint) {
+	tenantPolicies.mu.Lock()
+	defer tenantPolicies.mu.Unlock()
+	delete(tenantPolicies.entries, tenantID)
+	tenantPolicies.generation.Add(1)
+}
+
+// Attributes 返回策略条件可用的主体属性
+func (s *Subject) Attributes() Attributes {
+	return Attributes{
+		"id":                 s.UserID,
+		"username":           s.Username,
+		"status":             s.Status,
+		"tenant_id":          s.TenantID,
+		"department_id":      s.DepartmentID,
+		"role_ids":           s.RoleIDs,
+		"roles":              s.RoleNames,
+		"effective_role_ids": s.EffectiveRoleIDs,
+		"superuser":          s.Superuser,
+	}
+}
+
+// EnvironmentAttributes 返回策略条件可用的环境属性，时间使用服务器本地时区
+func EnvironmentAttributes(now time.Time, ip, method, path string) Attributes {
+	return Attributes{
+		"time":    now.Format("15:04"),
+		"date":    now.Format("2006-01-02"),
+		"hour":    now.Hour(),
+		"weekday": int(now.Weekday()), // 0 为周日
+		"ip":      ip,
+		"method":  method,
+		"path":    path,
+	}
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"useradmin/api/models"
+)
+
+// 受保护记录的规则集中在这里，控制器只负责调用
+var (
+	ErrSystemRole      = errors.New("系统内置角色不能修改或删除")
+	ErrSystemUser      = errors.New("系统内置用户不能删除或禁用")
+	ErrLastSuperuser   = errors.New("至少需要保留一个启用状态的超级管理员")
+	ErrSuperuserGrant  = errors.New("只有超级管理员可以授予超级管理员权限")
+	ErrDepartmentScope = errors.New("部门不在数据范围内")
+	ErrPolicyGrant     = errors.New("只有超级管理员可以为通配权限或自己没有的权限创建允许策略")
+)
+
+// GuardRoleChange 系统内置角色不能修改、删除或调整权限
+func GuardRoleChange(role models.Role) error {
+	if role.IsSystem {
+		return ErrSystemRole
+	}
+	return nil
+}
+
+// GuardUserDelete 系统内置用户不能删除
+func GuardUserDelete(user models.User) error {
+	if user.IsSystem {
+		return ErrSystemUser
+	}
+	return nil
+}
+
+// GuardUserStatus 系统内置用户不能禁用
+func GuardUserStatus(user models.User, status int) error {
+	if user.IsSystem && status != 1 {
+		return ErrSystemUser
+	}
+	return nil
+}
+
+// GuardDepartmentAccess 部门必须在操作人的数据范围内，用于设置用户部门以及创建、修改、移动、删除部门
+func GuardDepartmentAccess(actor *Subject, departmentID uint) error {
+	if actor == nil || !actor.CanAccessDepartment(departmentID) {
+		return ErrDepartmentScope
+	}
+	return nil
+}
+
+// GuardPolicyGrant 允许策略相当于授予权限：非超级管理员不能为通配权限创建允许策略，
+// 也不能通过策略授予自己没有的权限，否则可以绕过 GuardSuperuserGrant
+func GuardPolicyGrant(actor *Subject, policy CompiledPolicy) error {
+	if policy.Effect != models.PolicyEffectAllow || (actor != nil && actor.Superuser) {
+		return nil
+	}
+	if actor == nil || IsWildcardPermission(policy.Permission) {
+		return ErrPolicyGrant
+	}
+	for _, grant := range actor.Permissions {
+		if MatchPermission(grant, policy.Permission) {
+			return nil
+		}
+	}
+	return ErrPolicyGrant
+}
+
+// GuardSuperuserGrant 只有超级管理员可以分配超级管理员角色或设置超级管理员标记
+func GuardSuperuserGrant(tx *gorm.DB, actor *Subject, roleIDs []uint, grantUserFlag bool) error {
+	if actor != nil && actor.Superuser {
+		return nil
+	}
+	if grantUserFlag {
+		return ErrSuperuserGrant
+	}
+	if len(roleIDs) == 0 {
+		return nil
+	}
+
+	var count int64
+	if err := tx.Model(&models.Role{}).Where("id IN ? AND is_superuser = ?", roleIDs, true).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrSuperuserGrant
+	}
+	return nil
+}
+
+// EnsureSuperuserRemains 在修改用户、角色的事务末尾调用，修改后没有可用的超级管理员时返回错误使事务回滚
+func EnsureSuperuserRemains(tx *gorm.DB) error {
+	count, err := CountActiveSuperusers(tx)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrLastSuperuser
+	}
+	return nil
+}
+
+// CountActiveSuperusers 统计启用状态的超级管理员数量（用户标记或拥有超级管理员角色）。
+// 临时授予的超级管理员角色到期后会失效，不计入
+func CountActiveSuperusers(tx *gorm.DB) (int64, error) {
+	var count int64
+	err := tx.Model(&models.User{}).
+		Where("status = ?", 1).
+		Where(tx.Where("is_superuser = ?", true).
+			Or("EXISTS (SELECT 1 FROM user_roles ur JOIN roles r ON r.id = ur.role_id AND r.deleted_at IS NULL WHERE ur.user_id = users.id AND r.is_superuser = ? AND ur.valid_until IS NULL AND (ur.valid_from IS NULL OR ur.valid_from <= ?))", true, time.Now())).
+		Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"useradmin/api/models"
+)
+
+// ErrRoleCycle 设置父角色后形成循环继承
+var ErrRoleCycle = errors.New("角色继承不能形成循环")
+
+// RoleParentGraph 读取全部角色继承关系，返回 角色ID -> 父角色ID 列表
+func RoleParentGraph(tx *gorm.DB) (map[uint][]uint, error) {
+	var links []models.RoleParent
+	if err := tx.Order("role_id, parent_id").Find(&links).Error; err != nil {
+		return nil, err
+	}
+	graph := make(map[uint][]uint, len(links))
+	for _, link := range links {
+		graph[link.RoleID] = append(graph[link.RoleID], link.ParentID)
+	}
+	return graph, nil
+}
+
+// EffectiveRoleIDs 返回 roleIDs 及其所有祖先角色的ID（去重，按继承层级由近及远）
+func EffectiveRoleIDs(tx *gorm.DB, roleIDs []uint) ([]uint, error) {
+	graph, err := RoleParentGraph(tx)
+	if err != nil {
+		return nil, err
+	}
+	return closure(graph, roleIDs), nil
+}
+
+// closure 沿继承关系广度优先遍历，已访问的角色不再重复展开，因此即使数据中存在循环也会终止
+func closure(graph map[uint][]uint, roleIDs []uint) []uint {
+	seen := make(map[uint]bool)
+	queue := uniqueIDs(roleIDs)
+	result := make([]uint, 0, len(queue))
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		result = append(result, id)
+		queue = append(queue, graph[id]...)
+	}
+	return result
+}
+
+// SetRoleParents 将角色的父角色替换为 parentIDs，形成循环继承时返回 ErrRoleCycle
+func SetRoleParents(tx *gorm.DB, role *models.Role, parentIDs []uint) error {
+	parentIDs = uniqueIDs(parentIDs)
+	if err := checkRolesExist(tx, parentIDs); err != nil {
+		return err
+	}
+
+	graph, err := RoleParentGraph(tx)
+	if err != nil {
+		return err
+	}
+	// 父角色（含其祖先）中出现角色自身即为循环
+	for _, id := range closure(graph, parentIDs) {
+		if id == role.ID {
+			return ErrRoleCycle
+		}
+	}
+
+	if err := tx.Where("role_id = ?", role.ID).Delete(&models.RoleParent{}).Error; err != nil {
+		return err
+	}
+	for _, parentID := range parentIDs {
+		if err := tx.Create(&models.RoleParent{RoleID: role.ID, ParentID: parentID}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DetachRole 删除角色时清理其继承关系，子角色不再继承该角色的权限
+func DetachRole(tx *gorm.DB, roleID uint) error {
+	return tx.Where("role_id = ? OR parent_id = ?", roleID, roleID).Delete(&models.RoleParent{}).Error
+}
+
+// EffectivePermissionCodes 返回角色（含继承的祖先角色）当前生效的权限代码的并集，以及参与计算的全部角色ID
+func EffectivePermissionCodes(tx *gorm.DB, roleIDs []uint) ([]string, []uint, error) {
+	return effectivePermissionCodesAt(tx, roleIDs, time.Now())
+}
+
+func effectivePermissionCodesAt(tx *gorm.DB, roleIDs []uint, now time.Time) ([]string, []uint, error) {
+	effectiveIDs, err := EffectiveRoleIDs(tx, roleIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(effectiveIDs) == 0 {
+		return []string{}, effectiveIDs, nil
+	}
+
+	var roles []models.Role
+	if err := tx.Where("id IN ?", effectiveIDs).Find(&roles).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := withActivePermissions(tx, roles, now); err != nil {
+		return nil, nil, err
+	}
+	return RolePermissionCodes(roles), effectiveIDs, nil
+}
+
+// InheritedPermission 从祖先角色继承的权限及其来源角色
+type InheritedPermission struct {
+	Permission models.Permission
+	From       []models.Role
+}
+
+// RolePermissionSet 角色的直接授予权限与继承权限
+type RolePermissionSet struct {
+	Role      models.Role
+	Ancestors []models.Role
+	Direct    []models.Permission
+	Inherited []InheritedPermission // 不包含已直接授予的权限
+}
+
+// ResolveRolePermissions 解析角色的有效权限，区分直接授予和从祖先角色继承
+func ResolveRolePermissions(tx *gorm.DB, roleID uint) (*RolePermissionSet, error) {
+	now := time.Now()
+	var role models.Role
+	if err := tx.Preload("Parents").First(&role, roleID).Error; err != nil {
+		return nil, err
+	}
+	roles := []models.Role{role}
+	if err := withActivePermissions(tx, roles, now); err != nil {
+		return nil, err
+	}
+	role = roles[0]
+
+	effectiveIDs, err := EffectiveRoleIDs(tx, []uint{role.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	set := &RolePermissionSet{Role: role, Direct: role.Permissions}
+	direct := make(map[uint]bool, len(role.Permissions))
+	for _, p := range role.Permissions {
+		direct[p.ID] = true
+	}
+
+	ancestorIDs := effectiveIDs[1:]
+	if len(ancestorIDs) == 0 {
+		return set, nil
+	}
+	var ancestors []models.Role
+	if err := tx.Where("id IN ?", ancestorIDs).Find(&ancestors).Error; err != nil {
+		return nil, err
+	}
+	if err := withActivePermissions(tx, ancestors, now); err != nil {
+		return nil, err
+	}
+	// 按继承层级排序，来源角色由近及远
+	order := make(map[uint]int, len(ancestorIDs))
+	for i, id := range ancestorIDs {
+		order[id] = i
+	}
+	sort.Slice(ancestors, func(i, j int) bool { return order[ancestors[i].ID] < order[ancestors[j].ID] })
+
+	index := make(map[uint]int)
+	for _, ancestor := range ancestors {
+		for _, p := range ancestor.Permissions {
+			if direct[p.ID] {
+				continue
+			}
+			i, ok := index[p.ID]
+			if !ok {
+				i = len(set.Inherited)
+				index[p.ID] = i
+				set.Inherited = append(set.Inherited, InheritedPermission{Permission: p})
+			}
+			source := ancestor
+			source.Permissions = nil
+			set.Inherited[i].From = append(set.Inherited[i].From, source)
+		}
+		ancestor.Permissions = nil
+		set.Ancestors = append(set.Ancestors, ancestor)
+	}
+	sort.Slice(set.Inherited, func(i, j int) bool {
+		return set.Inherited[i].Permission.Code < set.Inherited[j].Permission.Code
+	})
+	return set, nil
+}
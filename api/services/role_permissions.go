@@ -0,0 +1,77 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"useradmin/api/models"
+)
+
+// ErrPermissionNotFound 授予的权限不存在
+var ErrPermissionNotFound = errors.New("权限不存在")
+
+// SetRolePermissions 将角色的权限替换为 permissionIDs，保留的权限沿用原有的有效期，新增的权限长期有效
+func SetRolePermissions(tx *gorm.DB, roleID uint, permissionIDs []uint) error {
+	permissionIDs = uniqueIDs(permissionIDs)
+
+	var current []uint
+	if err := tx.Model(&models.RolePermission{}).Where("role_id = ?", roleID).Pluck("permission_id", &current).Error; err != nil {
+		return err
+	}
+	keep := make(map[uint]bool, len(permissionIDs))
+	for _, id := range permissionIDs {
+		keep[id] = true
+	}
+	existing := make(map[uint]bool, len(current))
+	for _, id := range current {
+		existing[id] = true
+		if !keep[id] {
+			if err := tx.Where("role_id = ? AND permission_id = ?", roleID, id).Delete(&models.RolePermission{}).Error; err != nil {
+				return err
+			}
+		}
+	}
+	for _, id := range permissionIDs {
+		if existing[id] {
+			continue
+		}
+		if err := tx.Create(&models.RolePermission{RoleID: roleID, PermissionID: id}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GrantRolePermission 为角色授予一个权限并设置有效期，已授予时覆盖原有效期
+func GrantRolePermission(tx *gorm.DB, roleID, permissionID uint, window GrantWindow) error {
+	if err := window.Validate(time.Now()); err != nil {
+		return err
+	}
+	var count int64
+	if err := tx.Model(&models.Permission{}).Where("id = ?", permissionID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrPermissionNotFound
+	}
+
+	if err := tx.Model(&models.RolePermission{}).Where("role_id = ? AND permission_id = ?", roleID, permissionID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return tx.Create(&models.RolePermission{
+			RoleID:       roleID,
+			PermissionID: permissionID,
+			ValidFrom:    window.ValidFrom,
+			ValidUntil:   window.ValidUntil,
+		}).Error
+	}
+	return tx.Model(&models.RolePermission{}).Where("role_id = ? AND permission_id = ?", roleID, permissionID).
+		Updates(map[string]any{"valid_from": window.ValidFrom, "valid_until": window.ValidUntil}).Error
+}
+
+// RevokeRolePermission 收回角色的一个权限
+func RevokeRolePermission(tx *gorm.DB, roleID, permissionID uint) error {
+	return tx.Where("role_id = ? AND permission_id = ?", roleID, permissionID).Delete(&models.RolePermission{}).Error
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+	"useradmin/api/config"
+	"useradmin/api/models"
+)
+
+var (
+	ErrTenantDisabled   = errors.New("租户已停用")
+	ErrTenantCodeExists = errors.New("租户代码已存在")
+	ErrUsernameExists   = errors.New("用户名已存在")
+	ErrPlatformTenant   = errors.New("不能停用平台租户")
+)
+
+// platformTenantID 平台租户ID，启动后不会变化，首次查询后缓存
+var platformTenantID atomic.Uint64
+
+// PlatformTenantID 返回平台租户的ID
+func PlatformTenantID() (uint, error) {
+	if id := platformTenantID.Load(); id != 0 {
+		return uint(id), nil
+	}
+	var tenant models.Tenant
+	if err := config.DB.Where("is_platform = ?", true).Order("id").First(&tenant).Error; err != nil {
+		return 0, err
+	}
+	platformTenantID.Store(uint64(tenant.ID))
+	return tenant.ID, nil
+}
+
+// CheckTenantActive 租户不存在或已停用时返回 ErrTenantDisabled
+func CheckTenantActive(tenantID uint) error {
+	var tenant models.Tenant
+	if err := config.DB.First(&tenant, tenantID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTenantDisabled
+		}
+		return err
+	}
+	if tenant.Status != 1 {
+		return ErrTenantDisabled
+	}
+	return nil
+}
+
+// CreateTenant 创建租户，并为其初始化超级管理员角色和管理员账号。
+// db 不能携带租户，否则用户名、租户代码的唯一性检查只会在当前租户内进行
+func CreateTenant(db *gorm.DB, tenant *models.Tenant, adminUsername, adminPassword string) (*models.User, error) {
+	admin := &models.User{Username: adminUsername, Status: 1, IsSystem: true}
+	if err := SetPassword(db, admin, adminPassword, true); err != nil {
+		return nil, err
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Tenant{}).Where("code = ?", tenant.Code).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrTenantCodeExists
+		}
+		if err := tx.Model(&models.User{}).Where("username = ?", adminUsername).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrUsernameExists
+		}
+
+		tenant.IsPlatform = false
+		if err := tx.Create(tenant).Error; err != nil {
+			return err
+		}
+
+		// 以下数据都写入新租户
+		scoped := tx.WithContext(WithTenant(tx.Statement.Context, tenant.ID))
+
+		role := models.Role{
+			Name:        "超级管理员",
+			Description: "租户超级管理员",
+			IsSystem:    true,
+			IsSuperuser: true,
+			DataScope:   models.DataScopeAll,
+		}
+		if err := scoped.Omit("Parents", "Permissions").Create(&role).Error; err != nil {
+			return err
+		}
+		var permissionIDs []uint
+		if err := scoped.Model(&models.Permission{}).Pluck("id", &permissionIDs).Error; err != nil {
+			return err
+		}
+		for _, id := range permissionIDs {
+			if err := scoped.Create(&models.RolePermission{RoleID: role.ID, PermissionID: id}).Error; err != nil {
+				return err
+			}
+		}
+
+		admin.RoleID = role.ID
+		if err := scoped.Create(admin).Error; err != nil {
+			return err
+		}
+		if err := scoped.Create(&models.UserRole{UserID: admin.ID, RoleID: role.ID}).Error; err != nil {
+			return err
+		}
+		return RecordPasswordHistory(scoped, admin)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return admin, nil
+}
+
+// UpdateTenantStatus 启用或停用租户，停用后该租户的用户不能登录和访问接口。
+// db 可以是调用方的事务，提交后需要调用 InvalidateTenantPermissions 清除缓存
+func UpdateTenantStatus(db *gorm.DB, tenant *models.Tenant, status int) error {
+	if tenant.IsPlatform && status != 1 {
+		return ErrPlatformTenant
+	}
+	tenant.Status = status
+	return db.Model(tenant).Update("status", status).Error
+}
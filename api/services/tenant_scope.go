@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// tenantField 带有该字段的模型按租户隔离
+const tenantField = "TenantID"
+
+type tenantContextKey struct{}
+
+// WithTenant 返回带有租户的 context，使用该 context 的数据库操作只能访问该租户的数据
+func WithTenant(ctx context.Context, tenantID uint) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenantID)
+}
+
+// TenantFromContext 获取 context 中的租户
+func TenantFromContext(ctx context.Context) (uint, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	tenantID, ok := ctx.Value(tenantContextKey{}).(uint)
+	return tenantID, ok && tenantID != 0
+}
+
+// RegisterTenantScope 注册租户隔离的 GORM 回调：
+//   - 查询、更新、删除自动追加 tenant_id = 当前租户 的条件
+//   - 创建时 TenantID 一律设置为当前租户，忽略客户端传入的值
+//
+// 只对通过 db.WithContext(WithTenant(...)) 传入租户的操作生效；
+// 未携带租户的操作（启动初始化、登录前查找用户、后台任务）视为系统操作，不做限制。
+// 原生 SQL（Raw/Exec）不经过这些回调，需要自行带上 tenant_id 条件。
+func RegisterTenantScope(db *gorm.DB) error {
+	cb := db.Callback()
+	if err := cb.Query().Before("gorm:query").Register("tenant:query", tenantWhere); err != nil {
+		return err
+	}
+	if err := cb.Row().Before("gorm:row").Register("tenant:row", tenantWhere); err != nil {
+		return err
+	}
+	if err := cb.Update().Before("gorm:update").Register("tenant:update", tenantWhere); err != nil {
+		return err
+	}
+	if err := cb.Delete().Before("gorm:delete").Register("tenant:delete", tenantWhere); err != nil {
+		return err
+	}
+	return cb.Create().Before("gorm:create").Register("tenant:create", tenantAssign)
+}
+
+// tenantSchemaField 当前语句的模型需要按租户隔离时返回租户和字段
+func tenantSchemaField(db *gorm.DB) (uint, string, bool) {
+	tenantID, ok := TenantFromContext(db.Statement.Context)
+	if !ok || db.Statement.Schema == nil {
+		return 0, "", false
+	}
+	field := db.Statement.Schema.LookUpField(tenantField)
+	if field == nil {
+		return 0, "", false
+	}
+	return tenantID, field.DBName, true
+}
+
+func tenantWhere(db *gorm.DB) {
+	tenantID, column, ok := tenantSchemaField(db)
+	if !ok {
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: column}, Value: tenantID},
+	}})
+}
+
+func tenantAssign(db *gorm.DB) {
+	tenantID, _, ok := tenantSchemaField(db)
+	if !ok {
+		return
+	}
+	field := db.Statement.Schema.LookUpField(tenantField)
+	ctx := db.Statement.Context
+
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if err := field.Set(ctx, reflect.Indirect(rv.Index(i)), tenantID); err != nil {
+				db.AddError(err)
+				return
+			}
+		}
+	case reflect.Struct:
+		if err := field.Set(ctx, rv, tenantID); err != nil {
+			db.AddError(err)
+		}
+	}
+}
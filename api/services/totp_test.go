@@ -0,0 +1,48 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+// RFC 6238 附录 B 的测试密钥 "12345678901234567890"
+const rfcTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 给出的是 8 位验证码，取后 totpDigits 位
+	for _, tc := range []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+	} {
+		got, err := TOTPCode(rfcTOTPSecret, tc.unix/totpPeriod)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := tc.want[len(tc.want)-totpDigits:]; got != want {
+			t.Errorf("TOTPCode at %d = %s, want %s", tc.unix, got, want)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	code, err := TOTPCode(rfcTOTPSecret, now.Unix()/totpPeriod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter, ok := VerifyTOTP(rfcTOTPSecret, code, now, 0)
+	if !ok || counter != now.Unix()/totpPeriod {
+		t.Fatalf("VerifyTOTP = %d, %v", counter, ok)
+	}
+	if _, ok := VerifyTOTP(rfcTOTPSecret, code, now, counter); ok {
+		t.Error("reused code should be rejected")
+	}
+	if _, ok := VerifyTOTP(rfcTOTPSecret, code, now.Add(5*totpPeriod*time.Second), 0); ok {
+		t.Error("code outside the skew window should be rejected")
+	}
+}
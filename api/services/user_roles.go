@@ -0,0 +1,149 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"useradmin/api/models"
+)
+
+// ErrRoleNotFound 分配的角色不存在
+var ErrRoleNotFound = errors.New("角色不存在")
+
+// SetUserRoles 将用户的角色替换为 roleIDs，并保持主角色 role_id 在角色列表中。
+// 保留的角色沿用原有的有效期，新增的角色长期有效
+func SetUserRoles(tx *gorm.DB, user *models.User, roleIDs []uint) error {
+	roleIDs = uniqueIDs(roleIDs)
+	if err := checkRolesExist(tx, roleIDs); err != nil {
+		return err
+	}
+
+	current, err := UserRoleIDs(tx, user.ID)
+	if err != nil {
+		return err
+	}
+	keep := make(map[uint]bool, len(roleIDs))
+	for _, id := range roleIDs {
+		keep[id] = true
+	}
+	existing := make(map[uint]bool, len(current))
+	for _, id := range current {
+		existing[id] = true
+		if !keep[id] {
+			if err := tx.Where("user_id = ? AND role_id = ?", user.ID, id).Delete(&models.UserRole{}).Error; err != nil {
+				return err
+			}
+		}
+	}
+	for _, roleID := range roleIDs {
+		if existing[roleID] {
+			continue
+		}
+		if err := tx.Create(&models.UserRole{UserID: user.ID, RoleID: roleID}).Error; err != nil {
+			return err
+		}
+	}
+
+	return syncPrimaryRole(tx, user, roleIDs)
+}
+
+// AssignUserRole 为用户增加一个角色。window 不为空时设置有效期（已拥有该角色时覆盖原有效期），
+// 为空时新增的角色长期有效、已拥有的角色保持原有效期
+func AssignUserRole(tx *gorm.DB, user *models.User, roleID uint, window *GrantWindow) error {
+	if err := checkRolesExist(tx, []uint{roleID}); err != nil {
+		return err
+	}
+	if window != nil {
+		if err := window.Validate(time.Now()); err != nil {
+			return err
+		}
+	}
+
+	var count int64
+	if err := tx.Model(&models.UserRole{}).Where("user_id = ? AND role_id = ?", user.ID, roleID).Count(&count).Error; err != nil {
+		return err
+	}
+	switch {
+	case count == 0:
+		userRole := models.UserRole{UserID: user.ID, RoleID: roleID}
+		if window != nil {
+			userRole.ValidFrom, userRole.ValidUntil = window.ValidFrom, window.ValidUntil
+		}
+		if err := tx.Create(&userRole).Error; err != nil {
+			return err
+		}
+	case window != nil:
+		if err := tx.Model(&models.UserRole{}).Where("user_id = ? AND role_id = ?", user.ID, roleID).
+			Updates(map[string]any{"valid_from": window.ValidFrom, "valid_until": window.ValidUntil}).Error; err != nil {
+			return err
+		}
+	}
+
+	roleIDs, err := UserRoleIDs(tx, user.ID)
+	if err != nil {
+		return err
+	}
+	return syncPrimaryRole(tx, user, roleIDs)
+}
+
+// UnassignUserRole 移除用户的一个角色，移除的是主角色时改用剩余的第一个角色
+func UnassignUserRole(tx *gorm.DB, user *models.User, roleID uint) error {
+	if err := tx.Where("user_id = ? AND role_id = ?", user.ID, roleID).Delete(&models.UserRole{}).Error; err != nil {
+		return err
+	}
+
+	roleIDs, err := UserRoleIDs(tx, user.ID)
+	if err != nil {
+		return err
+	}
+	return syncPrimaryRole(tx, user, roleIDs)
+}
+
+// UserRoleIDs 查询用户拥有的角色ID
+func UserRoleIDs(tx *gorm.DB, userID uint) ([]uint, error) {
+	var roleIDs []uint
+	err := tx.Model(&models.UserRole{}).Where("user_id = ?", userID).Order("role_id").Pluck("role_id", &roleIDs).Error
+	return roleIDs, err
+}
+
+// syncPrimaryRole 主角色不在角色列表中时，改为列表中的第一个角色（没有角色时为0）
+func syncPrimaryRole(tx *gorm.DB, user *models.User, roleIDs []uint) error {
+	for _, id := range roleIDs {
+		if id == user.RoleID {
+			return nil
+		}
+	}
+
+	user.RoleID = 0
+	if len(roleIDs) > 0 {
+		user.RoleID = roleIDs[0]
+	}
+	return tx.Model(user).Update("role_id", user.RoleID).Error
+}
+
+func checkRolesExist(tx *gorm.DB, roleIDs []uint) error {
+	if len(roleIDs) == 0 {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(&models.Role{}).Where("id IN ?", roleIDs).Count(&count).Error; err != nil {
+		return err
+	}
+	if int(count) != len(roleIDs) {
+		return ErrRoleNotFound
+	}
+	return nil
+}
+
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if id != 0 && !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
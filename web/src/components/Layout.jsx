@@ -31,6 +31,7 @@ import {
   ExpandMore,
 } from '@mui/icons-material';
 import { hasPermission } from '../utils/auth';
+import { logout } from '../services/api';
 
 const drawerWidth = 260;
 
@@ -52,9 +53,12 @@ const Layout = () => {
     }
   };
 
-  const handleLogout = () => {
-    localStorage.removeItem('token');
-    localStorage.removeItem('user');
+  const handleLogout = async () => {
+    try {
+      await logout();
+    } catch (error) {
+      console.error('Logout error:', error);
+    }
     navigate('/login');
   };
 
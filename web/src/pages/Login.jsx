@@ -15,7 +15,7 @@ import {
   VisibilityOff,
   LockOutlined,
 } from '@mui/icons-material';
-import { login } from '../services/api';
+import { login, loginMfa, loginMfaEnroll, loginMfaActivate } from '../services/api';
 import { useNavigate } from 'react-router-dom';
 import Message from '../components/Message';
 
@@ -24,14 +24,36 @@ const Login = () => {
   const [password, setPassword] = useState('');
   const [showPassword, setShowPassword] = useState(false);
   const [message, setMessage] = useState({ open: false, type: 'error', text: '' });
+  const [mfa, setMfa] = useState(null);
+  const [mfaCode, setMfaCode] = useState('');
   const navigate = useNavigate();
 
   const handleSubmit = async (e) => {
     e.preventDefault();
     try {
+      // 第二步：提交两步验证码
+      if (mfa) {
+        const response = mfa.enroll
+          ? await loginMfaActivate(mfa.token, mfaCode)
+          : await loginMfa(mfa.token, mfaCode);
+        if (response.recovery_codes) {
+          window.alert(`请妥善保存恢复码：\n${response.recovery_codes.join('\n')}`);
+        }
+        navigate('/');
+        return;
+      }
+
       const response = await login(username, password);
-      localStorage.setItem('token', response.token);
-      localStorage.setItem('user', JSON.stringify(response.user));
+      if (response.mfa_required) {
+        const next = { token: response.mfa_token, enroll: response.mfa_enroll_required };
+        if (next.enroll) {
+          const setup = await loginMfaEnroll(next.token);
+          next.secret = setup.secret;
+          next.uri = setup.otpauth_uri;
+        }
+        setMfa(next);
+        return;
+      }
       navigate('/');
     } catch (err) {
       setMessage({
@@ -93,6 +115,31 @@ const Login = () => {
             onSubmit={handleSubmit}
             sx={{ width: '100%' }}
           >
+            {mfa ? (
+              <>
+                {mfa.enroll && (
+                  <Typography variant="body2" sx={{ wordBreak: 'break-all' }}>
+                    当前角色要求启用两步验证，请在身份验证器中添加密钥：{mfa.secret}
+                  </Typography>
+                )}
+                <TextField
+                  margin="normal"
+                  required
+                  fullWidth
+                  autoFocus
+                  label="两步验证码"
+                  autoComplete="one-time-code"
+                  value={mfaCode}
+                  onChange={(e) => setMfaCode(e.target.value)}
+                  sx={{
+                    '& .MuiOutlinedInput-root': {
+                      borderRadius: 2,
+                    },
+                  }}
+                />
+              </>
+            ) : (
+            <>
             <TextField
               margin="normal"
               required
@@ -136,6 +183,8 @@ const Login = () => {
                 ),
               }}
             />
+            </>
+            )}
             <Button
               type="submit"
               fullWidth
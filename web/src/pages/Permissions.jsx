@@ -88,7 +88,7 @@ const Permissions = () => {
       }
 
       if (editPermission) {
-        await updatePermission(editPermission.ID, formData);
+        await updatePermission(editPermission.id, formData);
         setMessage({ open: true, type: 'success', text: '更新权限成功' });
       } else {
         await createPermission(formData);
@@ -158,7 +158,7 @@ const Permissions = () => {
               </TableHead>
               <TableBody>
                 {perms.map((permission) => (
-                  <TableRow key={permission.ID} hover>
+                  <TableRow key={permission.id} hover>
                     <TableCell>{permission.name}</TableCell>
                     <TableCell>{permission.code}</TableCell>
                     <TableCell>{permission.description}</TableCell>
@@ -166,7 +166,7 @@ const Permissions = () => {
                       <IconButton size="small" onClick={() => handleOpen(permission)}>
                         <EditIcon />
                       </IconButton>
-                      <IconButton size="small" color="error" onClick={() => handleDelete(permission.ID)}>
+                      <IconButton size="small" color="error" onClick={() => handleDelete(permission.id)}>
                         <DeleteIcon />
                       </IconButton>
                     </TableCell>
@@ -178,8 +178,8 @@ const Products = () => {
           </TableHead>
           <TableBody>
             {products.map((product) => (
-              <TableRow key={product.ID} hover>
-                <TableCell>{product.ID}</TableCell>
+              <TableRow key={product.id} hover>
+                <TableCell>{product.id}</TableCell>
                 <TableCell>{product.title}</TableCell>
                 <TableCell>{product.images?.length || 0}</TableCell>
                 <TableCell>{product.specs?.length || 0}</TableCell>
@@ -200,20 +200,20 @@ const Products = () => {
                 <TableCell>
                   <IconButton
                     size="small"
-                    onClick={() => navigate(`/products/${product.ID}`)}
+                    onClick={() => navigate(`/products/${product.id}`)}
                   >
                     <VisibilityIcon />
                   </IconButton>
                   <IconButton
                     size="small"
-                    onClick={() => navigate(`/products/${product.ID}/edit`)}
+                    onClick={() => navigate(`/products/${product.id}/edit`)}
                   >
                     <EditIcon />
                   </IconButton>
                   <IconButton
                     size="small"
                     color="error"
-                    onClick={() => handleDelete(product.ID)}
+                    onClick={() => handleDelete(product.id)}
                   >
                     <DeleteIcon />
                   </IconButton>
@@ -19,6 +19,10 @@ import {
   Checkbox,
   Typography,
   Divider,
+  FormControl,
+  InputLabel,
+  Select,
+  MenuItem,
 } from '@mui/material';
 import { getRoles, getPermissions, createRole, updateRole, deleteRole, updateRolePermissions } from '../services/api';
 import Message from '../components/Message';
@@ -32,6 +36,8 @@ const Roles = () => {
     name: '',
     description: '',
     permission_ids: [],
+    parent_ids: [],
+    data_scope: 'all',
   });
   const [message, setMessage] = useState({ open: false, type: 'success', text: '' });
 
@@ -66,7 +72,9 @@ const Roles = () => {
       setFormData({
         name: role.name,
         description: role.description || '',
-        permission_ids: role.permissions?.map(p => p.ID) || [],
+        permission_ids: role.permissions?.map(p => p.id) || [],
+        parent_ids: role.parents?.map(r => r.id) || [],
+        data_scope: role.data_scope || 'all',
       });
     } else {
       setEditRole(null);
@@ -74,6 +82,8 @@ const Roles = () => {
         name: '',
         description: '',
         permission_ids: [],
+        parent_ids: [],
+        data_scope: 'all',
       });
     }
     setOpen(true);
@@ -86,14 +96,16 @@ const Roles = () => {
       name: '',
       description: '',
       permission_ids: [],
+      parent_ids: [],
+      data_scope: 'all',
     });
   };
 
   const handleSubmit = async () => {
     try {
       if (editRole) {
-        await updateRole(editRole.ID, formData);
-        await updateRolePermissions(editRole.ID, formData.permission_ids);
+        await updateRole(editRole.id, formData);
+        await updateRolePermissions(editRole.id, formData.permission_ids);
         setMessage({ open: true, type: 'success', text: '更新角色成功' });
       } else {
         await createRole(formData);
@@ -136,6 +148,14 @@ const Roles = () => {
     setFormData({ ...formData, permission_ids: newPermissionIds });
   };
 
+  const handleParentChange = (roleId) => {
+    const newParentIds = formData.parent_ids.includes(roleId)
+      ? formData.parent_ids.filter(id => id !== roleId)
+      : [...formData.parent_ids, roleId];
+
+    setFormData({ ...formData, parent_ids: newParentIds });
+  };
+
   return (
     <Box>
       <Box sx={{
@@ -157,26 +177,28 @@ const Roles = () => {
               <TableCell>ID</TableCell>
               <TableCell>角色名称</TableCell>
               <TableCell>描述</TableCell>
+              <TableCell>继承角色</TableCell>
               <TableCell>权限数量</TableCell>
               <TableCell>操作</TableCell>
             </TableRow>
           </TableHead>
           <TableBody>
             {Array.isArray(roles) && roles.map((role) => (
-              <TableRow key={role.ID}>
-                <TableCell>{role.ID}</TableCell>
+              <TableRow key={role.id}>
+                <TableCell>{role.id}</TableCell>
                 <TableCell>{role.name}</TableCell>
                 <TableCell>{role.description}</TableCell>
+                <TableCell>{role.parents?.map(r => r.name).join('、') || '-'}</TableCell>
                 <TableCell>{role.permissions?.length || 0}</TableCell>
                 <TableCell>
                   <Button size="small" onClick={() => handleOpen(role)}>
                     编辑
                   </Button>
-                  {role.ID !== 1 && (
+                  {!role.is_system && (
                     <Button
                       size="small"
                       color="error"
-                      onClick={() => handleDelete(role.ID)}
+                      onClick={() => handleDelete(role.id)}
                     >
                       删除
                     </Button>
@@ -206,8 +228,40 @@ const Roles = () => {
               label="描述"
               value={formData.description}
               onChange={(e) => setFormData({ ...formData, description: e.target.value })}
-              sx={{ mb: 3 }}
+              sx={{ mb: 2 }}
             />
+            <FormControl fullWidth sx={{ mb: 3 }}>
+              <InputLabel>数据范围</InputLabel>
+              <Select
+                value={formData.data_scope}
+                label="数据范围"
+                onChange={(e) => setFormData({ ...formData, data_scope: e.target.value })}
+              >
+                <MenuItem value="all">全部数据</MenuItem>
+                <MenuItem value="own">仅本人数据</MenuItem>
+                <MenuItem value="department">本部门数据</MenuItem>
+                <MenuItem value="subtree">本部门及下级部门数据</MenuItem>
+                <MenuItem value="custom">自定义部门数据</MenuItem>
+              </Select>
+            </FormControl>
+            <Typography variant="subtitle1" gutterBottom>
+              继承角色
+            </Typography>
+            <Divider sx={{ mb: 2 }} />
+            <FormGroup row sx={{ mb: 3 }}>
+              {roles.filter(r => !editRole || r.id !== editRole.id).map((r) => (
+                <FormControlLabel
+                  key={r.id}
+                  control={
+                    <Checkbox
+                      checked={formData.parent_ids.includes(r.id)}
+                      onChange={() => handleParentChange(r.id)}
+                    />
+                  }
+                  label={r.name}
+                />
+              ))}
+            </FormGroup>
             <Typography variant="subtitle1" gutterBottom>
               权限设置
             </Typography>
@@ -220,11 +274,11 @@ const Roles = () => {
                 <FormGroup>
                   {perms.map((permission) => (
                     <FormControlLabel
-                      key={permission.ID}
+                      key={permission.id}
                       control={
                         <Checkbox
-                          checked={formData.permission_ids.includes(Number(permission.ID))}
-                          onChange={() => handlePermissionChange(permission.ID)}
+                          checked={formData.permission_ids.includes(Number(permission.id))}
+                          onChange={() => handlePermissionChange(permission.id)}
                         />
                       }
                       label={`${permission.name} (${permission.code})`}
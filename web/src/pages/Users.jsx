@@ -24,18 +24,20 @@ import {
   Edit as EditIcon,
   Delete as DeleteIcon,
 } from '@mui/icons-material';
-import { getUsers, getRoles, createUser, updateUser, deleteUser } from '../services/api';
+import { getUsers, getRoles, getDepartments, createUser, updateUser, deleteUser } from '../services/api';
 import Message from '../components/Message';
 
 const Users = () => {
   const [users, setUsers] = useState([]);
   const [roles, setRoles] = useState([]);
+  const [departments, setDepartments] = useState([]);
   const [open, setOpen] = useState(false);
   const [editUser, setEditUser] = useState(null);
   const [formData, setFormData] = useState({
     username: '',
     password: '',
     roleId: '',
+    departmentId: 0,
     status: 1
   });
   const [message, setMessage] = useState({ open: false, type: 'success', text: '' });
@@ -43,6 +45,7 @@ const Users = () => {
   useEffect(() => {
     fetchUsers();
     fetchRoles();
+    fetchDepartments();
   }, []);
 
   const fetchUsers = async () => {
@@ -65,6 +68,16 @@ const Users = () => {
     }
   };
 
+  const fetchDepartments = async () => {
+    try {
+      const response = await getDepartments({ flat: 1 });
+      setDepartments(response.data || []);
+    } catch (error) {
+      // 没有部门查看权限时只显示"无部门"
+      console.error('获取部门列表失败:', error);
+    }
+  };
+
   const handleOpen = (user = null) => {
     if (user) {
       setEditUser(user);
@@ -72,6 +85,7 @@ const Users = () => {
         username: user.username,
         password: '',
         roleId: user.role_id,
+        departmentId: user.department_id || 0,
         status: user.status,
         id: user.id
       });
@@ -81,6 +95,7 @@ const Users = () => {
         username: '',
         password: '',
         roleId: '',
+        departmentId: 0,
         status: 1,
         id: null
       });
@@ -95,6 +110,7 @@ const Users = () => {
       username: '',
       password: '',
       roleId: '',
+      departmentId: 0,
       status: 1
     });
   };
@@ -109,6 +125,7 @@ const Users = () => {
       const userData = {
         username: formData.username,
         role_id: Number(formData.roleId),
+        department_id: Number(formData.departmentId),
         status: Number(formData.status)
       };
 
@@ -180,6 +197,7 @@ const Users = () => {
               <TableCell sx={{ fontWeight: 'bold', bgcolor: 'background.paper' }}>ID</TableCell>
               <TableCell sx={{ fontWeight: 'bold', bgcolor: 'background.paper' }}>用户名</TableCell>
               <TableCell sx={{ fontWeight: 'bold', bgcolor: 'background.paper' }}>角色</TableCell>
+              <TableCell sx={{ fontWeight: 'bold', bgcolor: 'background.paper' }}>部门</TableCell>
               <TableCell sx={{ fontWeight: 'bold', bgcolor: 'background.paper' }}>状态</TableCell>
               <TableCell sx={{ fontWeight: 'bold', bgcolor: 'background.paper' }}>操作</TableCell>
             </TableRow>
@@ -190,6 +208,7 @@ const Users = () => {
                 <TableCell>{user.id}</TableCell>
                 <TableCell>{user.username}</TableCell>
                 <TableCell>{user.role?.name}</TableCell>
+                <TableCell>{user.department?.name || '-'}</TableCell>
                 <TableCell>
                   <Box
                     sx={{
@@ -257,12 +276,27 @@ const Users = () => {
               onChange={(e) => setFormData({ ...formData, roleId: e.target.value })}
             >
               {Array.isArray(roles) && roles.map((role) => (
-                <MenuItem key={role.ID} value={role.ID}>
+                <MenuItem key={role.id} value={role.id}>
                   {role.name}
                 </MenuItem>
               ))}
             </Select>
           </FormControl>
+          <FormControl fullWidth margin="dense">
+            <InputLabel>部门</InputLabel>
+            <Select
+              value={formData.departmentId}
+              label="部门"
+              onChange={(e) => setFormData({ ...formData, departmentId: e.target.value })}
+            >
+              <MenuItem value={0}>无部门</MenuItem>
+              {departments.map((dept) => (
+                <MenuItem key={dept.id} value={dept.id}>
+                  {dept.name}
+                </MenuItem>
+              ))}
+            </Select>
+          </FormControl>
           <FormControl fullWidth margin="dense">
             <InputLabel>状态</InputLabel>
             <Select
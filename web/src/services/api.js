@@ -22,27 +22,98 @@ api.interceptors.request.use((config) => {
   return Promise.reject(handleApiError(error));
 });
 
+// 刷新token，多个请求同时过期时只刷新一次
+let refreshPromise = null;
+
+const refreshAccessToken = () => {
+  if (!refreshPromise) {
+    const refreshToken = localStorage.getItem('refresh_token');
+    refreshPromise = axios
+      .post(`${API_BASE_URL}/token/refresh`, { refresh_token: refreshToken })
+      .then(({ data }) => {
+        localStorage.setItem('token', data.token);
+        localStorage.setItem('refresh_token', data.refresh_token);
+        return data.token;
+      })
+      .finally(() => {
+        refreshPromise = null;
+      });
+  }
+  return refreshPromise;
+};
+
 // 响应拦截器
 api.interceptors.response.use(
   (response) => response.data,
-  (error) => Promise.reject(handleApiError(error))
+  async (error) => {
+    const original = error.config;
+    // 访问token过期时使用刷新token重试一次
+    if (
+      error.response?.status === 401 &&
+      original &&
+      !original._retry &&
+      localStorage.getItem('refresh_token')
+    ) {
+      original._retry = true;
+      try {
+        const token = await refreshAccessToken();
+        original.headers.Authorization = `Bearer ${token}`;
+        return api(original);
+      } catch (refreshError) {
+        return Promise.reject(handleApiError(refreshError));
+      }
+    }
+    return Promise.reject(handleApiError(error));
+  }
 );
 
 // 用户相关接口
 export const login = async (username, password) => {
   try {
     const response = await api.post('/login', { username, password });
-    if (response.token) {
-      localStorage.setItem('token', response.token);
-      localStorage.setItem('user', JSON.stringify(response.user));
-    }
-    return response;
+    return saveLogin(response);
   } catch (error) {
     console.error('Login error:', error);
     throw error;
   }
 };
 
+const saveLogin = (response) => {
+  if (response.token) {
+    localStorage.setItem('token', response.token);
+    localStorage.setItem('refresh_token', response.refresh_token);
+    localStorage.setItem('user', JSON.stringify(response.user));
+  }
+  return response;
+};
+
+// 两步验证：使用临时token和验证码完成登录
+export const loginMfa = async (mfaToken, code) => {
+  const response = await api.post('/login/mfa', { mfa_token: mfaToken, code });
+  return saveLogin(response);
+};
+
+// 角色强制两步验证时，在登录过程中获取密钥
+export const loginMfaEnroll = async (mfaToken) => {
+  return await api.post('/login/mfa/enroll', { mfa_token: mfaToken });
+};
+
+// 角色强制两步验证时，输入验证码完成设置并登录
+export const loginMfaActivate = async (mfaToken, code) => {
+  const response = await api.post('/login/mfa/activate', { mfa_token: mfaToken, code });
+  return saveLogin(response);
+};
+
+export const logout = async () => {
+  try {
+    await api.post('/logout');
+  } finally {
+    localStorage.removeItem('token');
+    localStorage.removeItem('refresh_token');
+    localStorage.removeItem('user');
+  }
+};
+
 export const getUsers = async (params) => {
   try {
     return await api.get('/users', { params });
@@ -85,6 +156,15 @@ export const getRoles = async () => {
   }
 };
 
+export const getDepartments = async (params = {}) => {
+  try {
+    const response = await api.get('/departments', { params });
+    return response;
+  } catch (error) {
+    throw handleApiError(error);
+  }
+};
+
 export const createRole = async (roleData) => {
   try {
     const response = await api.post('/roles', roleData);
@@ -123,6 +203,36 @@ export const updateRolePermissions = async (roleId, permissionIds) => {
   }
 };
 
+// 临时授予角色一个权限，validUntil 为空表示长期有效
+export const grantRolePermission = async (roleId, permissionId, validFrom, validUntil) => {
+  try {
+    return await api.post(`/roles/${roleId}/permissions`, {
+      permission_id: permissionId,
+      valid_from: validFrom,
+      valid_until: validUntil
+    });
+  } catch (error) {
+    throw handleApiError(error);
+  }
+};
+
+export const revokeRolePermission = async (roleId, permissionId) => {
+  try {
+    return await api.delete(`/roles/${roleId}/permissions/${permissionId}`);
+  } catch (error) {
+    throw handleApiError(error);
+  }
+};
+
+// 即将到期的临时授权
+export const getExpiringGrants = async (hours = 168) => {
+  try {
+    return await api.get('/grants/expiring', { params: { hours } });
+  } catch (error) {
+    throw handleApiError(error);
+  }
+};
+
 // 权限相关接口
 export const getPermissions = async () => {
   try {
@@ -161,6 +271,9 @@ export const deletePermission = async (id) => {
 };
 
 // 日志相关接口
+// getLogs 的 params 支持 username、action、status（如 200,4xx,500-599）、path（路径前缀）、ip（IP 或 CIDR）、
+// user_agent、q（响应内容全文搜索）、start_time、end_time、sort（如 -created_at、status）；
+// 传 cursor（第一页传空字符串）时按游标翻页，返回 next_cursor，不返回 total
 export const getLogs = async (params) => {
   try {
     return await api.get('/logs', { params });
@@ -169,6 +282,41 @@ export const getLogs = async (params) => {
   }
 };
 
+// 按 getLogs 的筛选条件导出日志，format 为 csv、xlsx 或 ndjson，返回文件内容
+export const exportLogs = async (params) => {
+  try {
+    return await api.get('/logs/export', { params, responseType: 'blob' });
+  } catch (error) {
+    throw handleApiError(error);
+  }
+};
+
+// 审计记录，params 支持 type（如 user.*）、actor、target_type、target_id、request_id、start_time、end_time
+export const getAuditEvents = async (params) => {
+  try {
+    return await api.get('/audit', { params });
+  } catch (error) {
+    throw handleApiError(error);
+  }
+};
+
+// 日志归档清理任务：保留策略、执行记录和归档文件
+export const getLogRetention = async () => {
+  try {
+    return await api.get('/system/log-retention');
+  } catch (error) {
+    throw handleApiError(error);
+  }
+};
+
+export const runLogRetention = async () => {
+  try {
+    return await api.post('/system/log-retention/run');
+  } catch (error) {
+    throw handleApiError(error);
+  }
+};
+
 // 商品相关接口
 export const getProducts = async (params) => {
   try {
@@ -202,6 +350,14 @@ export const updateProduct = async (id, productData) => {
   }
 };
 
+export const updateProductStatus = async (id, status) => {
+  try {
+    return await api.put(`/products/${id}/status`, { status });
+  } catch (error) {
+    throw handleApiError(error);
+  }
+};
+
 export const deleteProduct = async (id) => {
   try {
     return await api.delete(`/products/${id}`);
@@ -16,6 +16,7 @@ export const hasPermission = (permission) => {
 
 export const logout = () => {
   localStorage.removeItem('token');
+  localStorage.removeItem('refresh_token');
   localStorage.removeItem('user');
   window.location.href = '/login';
 };